@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+// cacheControl is a set of parsed "Cache-Control" directives
+// with lowercase names and unquoted values.
+type cacheControl map[string]string
+
+func parseCacheControl(header *specs.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, has := cc[name]
+	return has
+}
+
+// duration returns the value of directive in delta-seconds.
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	value, has := cc[name]
+	if !has {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func parseHttpDate(header *specs.Header, name string) (time.Time, bool) {
+	value := header.Get(name)
+	if value == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(specs.TimeFormat, value)
+	return date, err == nil
+}
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+// DefaultDiskStoreSize default value of maximum size for [NewDiskStore]
+const DefaultDiskStoreSize int64 = 1 << 30 // 1 gb
+
+// NewDiskStore returns a new [Store] which keeps entries
+// as files in the directory, the directory is created if not exists.
+//
+// Least recently used entries are removed when the total size of the files exceeds maxSize,
+// entries already stored in the directory are ordered by their modification time.
+// If maxSize is zero or negative, [DefaultDiskStoreSize] is used.
+func NewDiskStore(dir string, maxSize int64) (*DiskStore, error) {
+	if dir == "" {
+		panic("plow: empty disk store directory")
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultDiskStoreSize
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	store := &DiskStore{
+		dir:     dir,
+		maxSize: maxSize,
+		items:   map[string]*list.Element{},
+		lru:     list.New(),
+	}
+	if err := store.scan(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// DiskStore is a [Store] which keeps each entry in a separate file
+// named by the hash of the key, with least recently used eviction.
+//
+// Errors of reading and writing files are ignored,
+// so the entry is considered as not stored.
+// Files which cannot be decoded are removed.
+type DiskStore struct {
+	dir     string
+	maxSize int64
+	size    int64
+	items   map[string]*list.Element
+	lru     *list.List
+
+	mu sync.Mutex
+}
+
+type diskItem struct {
+	name string
+	size int64
+}
+
+type diskEntry struct {
+	Key          string
+	StatusCode   specs.StatusCode
+	Header       [][2]string
+	Body         []byte
+	Vary         map[string]string
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// scan indexes the files of the directory from the oldest to the newest
+// and removes temporary files left by interrupted writes.
+func (store *DiskStore) scan() error {
+	files, err := os.ReadDir(store.dir)
+	if err != nil {
+		return err
+	}
+
+	type scanned struct {
+		diskItem
+		modTime time.Time
+	}
+	var items []scanned
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(file.Name(), ".entry-") {
+			os.Remove(filepath.Join(store.dir, file.Name()))
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		items = append(items, scanned{diskItem{file.Name(), info.Size()}, info.ModTime()})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].modTime.Before(items[j].modTime)
+	})
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, item := range items {
+		store.add(item.name, item.size)
+	}
+	return nil
+}
+
+// Get implements the [Store] interface.
+func (store *DiskStore) Get(key string) *Entry {
+	name := fileName(key)
+	file, err := os.Open(filepath.Join(store.dir, name))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var stored diskEntry
+	if err = gob.NewDecoder(file).Decode(&stored); err != nil {
+		store.remove(name)
+		return nil
+	}
+	if stored.Key != key {
+		return nil
+	}
+
+	store.mu.Lock()
+	if elem, has := store.items[name]; has {
+		store.lru.MoveToFront(elem)
+	}
+	store.mu.Unlock()
+
+	// Modification time keeps the order of usage between runs
+	now := time.Now()
+	os.Chtimes(file.Name(), now, now)
+
+	header := specs.NewHeader()
+	for _, pair := range stored.Header {
+		header.Add(pair[0], pair[1])
+	}
+	return &Entry{
+		StatusCode:   stored.StatusCode,
+		Header:       header,
+		Body:         stored.Body,
+		Vary:         stored.Vary,
+		RequestTime:  stored.RequestTime,
+		ResponseTime: stored.ResponseTime,
+	}
+}
+
+// Set implements the [Store] interface,
+// the file is replaced atomically.
+// The entry larger than maximum size of the store is not stored.
+func (store *DiskStore) Set(key string, entry *Entry) {
+	name := fileName(key)
+	if entry.Size()+int64(len(key)) > store.maxSize {
+		store.remove(name)
+		return
+	}
+
+	stored := diskEntry{
+		Key:          key,
+		StatusCode:   entry.StatusCode,
+		Body:         entry.Body,
+		Vary:         entry.Vary,
+		RequestTime:  entry.RequestTime,
+		ResponseTime: entry.ResponseTime,
+	}
+	for name, value := range entry.Header.All() {
+		stored.Header = append(stored.Header, [2]string{name, value})
+	}
+
+	file, err := os.CreateTemp(store.dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	err = gob.NewEncoder(file).Encode(&stored)
+	var size int64
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(store.dir, name))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.add(name, size)
+	for store.size > store.maxSize && store.lru.Len() > 0 {
+		oldest := store.lru.Back().Value.(*diskItem).name
+		store.drop(oldest)
+		os.Remove(filepath.Join(store.dir, oldest))
+	}
+}
+
+// Delete implements the [Store] interface.
+func (store *DiskStore) Delete(key string) {
+	store.remove(fileName(key))
+}
+
+// Len returns the number of stored entries.
+func (store *DiskStore) Len() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.lru.Len()
+}
+
+// Size returns the total size in bytes of the stored files.
+func (store *DiskStore) Size() int64 {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.size
+}
+
+func (store *DiskStore) remove(name string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.drop(name)
+	os.Remove(filepath.Join(store.dir, name))
+}
+
+// add indexes the file as the most recently used, replacing the existing one.
+func (store *DiskStore) add(name string, size int64) {
+	store.drop(name)
+	store.items[name] = store.lru.PushFront(&diskItem{name: name, size: size})
+	store.size += size
+}
+
+func (store *DiskStore) drop(name string) {
+	if elem, has := store.items[name]; has {
+		store.lru.Remove(elem)
+		delete(store.items, name)
+		store.size -= elem.Value.(*diskItem).size
+	}
+}
+
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
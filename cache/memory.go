@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMemoryStoreSize default value of maximum size for [NewMemoryStore]
+const DefaultMemoryStoreSize int64 = 64 << 20 // 64 mb
+
+// NewMemoryStore returns a new [Store] which keeps entries in memory
+// and evicts least recently used entries when the total size exceeds maxSize.
+//
+// If maxSize is zero or negative, [DefaultMemoryStoreSize] is used.
+func NewMemoryStore(maxSize int64) *MemoryStore {
+	if maxSize <= 0 {
+		maxSize = DefaultMemoryStoreSize
+	}
+	return &MemoryStore{
+		maxSize: maxSize,
+		items:   map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// MemoryStore is an in-memory [Store] with least recently used eviction.
+type MemoryStore struct {
+	maxSize int64
+	size    int64
+	items   map[string]*list.Element
+	lru     *list.List
+
+	mu sync.Mutex
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// Get implements the [Store] interface.
+func (store *MemoryStore) Get(key string) *Entry {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if elem, has := store.items[key]; has {
+		store.lru.MoveToFront(elem)
+		return elem.Value.(*memoryItem).entry
+	}
+	return nil
+}
+
+// Set implements the [Store] interface,
+// the entry larger than maximum size of the store is not stored.
+func (store *MemoryStore) Set(key string, entry *Entry) {
+	size := entry.Size() + int64(len(key))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.remove(key)
+	if size > store.maxSize {
+		return
+	}
+
+	store.items[key] = store.lru.PushFront(&memoryItem{key: key, entry: entry, size: size})
+	store.size += size
+	for store.size > store.maxSize {
+		store.remove(store.lru.Back().Value.(*memoryItem).key)
+	}
+}
+
+// Delete implements the [Store] interface.
+func (store *MemoryStore) Delete(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.remove(key)
+}
+
+// Len returns the number of stored entries.
+func (store *MemoryStore) Len() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.lru.Len()
+}
+
+func (store *MemoryStore) remove(key string) {
+	if elem, has := store.items[key]; has {
+		store.lru.Remove(elem)
+		delete(store.items, key)
+		store.size -= elem.Value.(*memoryItem).size
+	}
+}
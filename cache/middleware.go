@@ -0,0 +1,332 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/plain"
+	"github.com/oesand/plow/mux"
+	"github.com/oesand/plow/specs"
+)
+
+// NewMiddleware returns a new [Middleware] which keeps responses in the store.
+//
+// If store is nil, [NewMemoryStore] with default size is used.
+func NewMiddleware(store Store) *Middleware {
+	if store == nil {
+		store = NewMemoryStore(0)
+	}
+	return &Middleware{
+		Store:        store,
+		MaxEntrySize: DefaultMaxEntrySize,
+	}
+}
+
+// RoutePolicy is a route flag which configures caching
+// of the route responses by [Middleware], such as:
+//
+//	router.Route(specs.HttpMethodGet, "/items", handler).
+//		AddFlag(cache.RoutePolicy{TTL: time.Minute})
+type RoutePolicy struct {
+	// Disable disables caching of the route responses.
+	Disable bool
+
+	// TTL specifies the duration responses of the route are fresh,
+	// overrides the freshness lifetime of the response "Cache-Control" header.
+	//
+	// If zero, responses are stored only with explicit freshness
+	// by "s-maxage", "max-age" or "Expires" headers.
+	TTL time.Duration
+
+	// Vary specifies request headers which are part of the key
+	// in addition to headers nominated by "Vary" response header.
+	Vary []string
+}
+
+// Middleware is a [mux.Middleware] which implements shared server cache
+// of whole responses to GET and HEAD requests.
+//
+// Responses are stored by the key which consists of method, host, path, query
+// and values of request headers nominated by "Vary" response header.
+// Bodies are stored as written by the handler, responses with "Content-Encoding" header
+// are also varied by "Accept-Encoding" request header.
+// Responses with "no-store", "private" or "no-cache" directives of "Cache-Control" header,
+// with cookies or without explicit freshness are not stored,
+// responses to requests with "Authorization" header are stored only if "public" or "s-maxage" is present.
+//
+// Caching is configured for each route by [RoutePolicy] flag.
+type Middleware struct {
+	_ internal.NoCopy
+
+	// Store specifies the storage of cached responses.
+	Store Store
+
+	// MaxEntrySize maximum size in bytes of the response body to store,
+	// larger responses and responses of unknown size are passed without storing.
+	//
+	// If zero there is no limit
+	MaxEntrySize int64
+}
+
+// Intercept implements the [mux.Middleware] interface.
+func (m *Middleware) Intercept(ctx context.Context, request plow.Request, next mux.NextFunc) plow.Response {
+	if m.Store == nil {
+		panic("plow: cache middleware requires Store")
+	}
+
+	method := request.Method()
+	if method != specs.HttpMethodGet && method != specs.HttpMethodHead {
+		return next(ctx)
+	}
+
+	var policy RoutePolicy
+	if route := mux.MatchedRoute(ctx); route != nil {
+		for flag := range mux.FlagsOfType[RoutePolicy](route) {
+			policy = flag
+		}
+	}
+	if policy.Disable {
+		return next(ctx)
+	}
+
+	header := request.Header()
+	key := string(method) + " " + requestAuthority(request.Url(), header) + requestKey(request.Url())
+
+	now := time.Now()
+	if entry := m.lookup(key, header); entry != nil {
+		age := currentAge(entry, now)
+		if age < sharedLifetime(entry, policy) {
+			return newStoredResponse(entry, age, nil)
+		}
+	}
+
+	resp := next(ctx)
+	if resp == nil || !m.isStorable(resp, header, policy) {
+		return resp
+	}
+
+	entry := &Entry{
+		StatusCode:   resp.StatusCode(),
+		Header:       specs.NewHeader(),
+		RequestTime:  now,
+		ResponseTime: time.Now(),
+	}
+	for name, value := range resp.Header().All() {
+		if !slices.Contains(skipStoredHeaders, name) {
+			entry.Header.Add(name, value)
+		}
+	}
+
+	var writeErr error
+	if writer, ok := resp.(plow.BodyWriter); ok {
+		var buf bytes.Buffer
+		buf.Grow(int(writer.ContentLength()))
+		writeErr = writer.WriteBody(&buf)
+		entry.Body = buf.Bytes()
+	}
+
+	if writeErr == nil {
+		names := append(varyNames(resp.Header()), policy.Vary...)
+		if resp.Header().Has("Content-Encoding") {
+			// Body is stored as encoded by the handler
+			names = append(names, "Accept-Encoding")
+		}
+		if len(names) > 0 {
+			entry.Vary = make(map[string]string, len(names))
+			for _, name := range names {
+				name = plain.TitleCase(name)
+				entry.Vary[name] = strings.Join(header.Values(name), ", ")
+			}
+		}
+		m.Store.Set(m.storeKey(key, entry), entry)
+	}
+
+	return newStoredResponse(entry, 0, writeErr)
+}
+
+// skipStoredHeaders are not stored by [Middleware],
+// body is stored as written by the handler, so "Content-Encoding" is kept
+var skipStoredHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding",
+	"Content-Length", "Trailer", "Upgrade",
+}
+
+// lookup returns the entry stored by the key for the request header.
+//
+// The first variant of response with "Vary" header is stored by the key,
+// other variants are stored by variant keys with the values of the nominated headers.
+func (m *Middleware) lookup(key string, header *specs.Header) *Entry {
+	entry := m.Store.Get(key)
+	if entry == nil || entry.matchVary(header) {
+		return entry
+	}
+
+	vary := make(map[string]string, len(entry.Vary))
+	for name := range entry.Vary {
+		vary[name] = strings.Join(header.Values(name), ", ")
+	}
+	entry = m.Store.Get(variantKey(key, vary))
+	if entry != nil && !entry.matchVary(header) {
+		return nil
+	}
+	return entry
+}
+
+// storeKey returns the key to store the entry by,
+// which is the variant key if the key holds other variant with the same nominated headers.
+func (m *Middleware) storeKey(key string, entry *Entry) string {
+	stored := m.Store.Get(key)
+	if stored == nil || len(entry.Vary) == 0 || len(stored.Vary) != len(entry.Vary) {
+		return key
+	}
+	same := true
+	for name, value := range entry.Vary {
+		storedValue, has := stored.Vary[name]
+		if !has {
+			return key
+		}
+		same = same && storedValue == value
+	}
+	if same {
+		return key
+	}
+	return variantKey(key, entry.Vary)
+}
+
+// isStorable checks the response can be stored by shared cache.
+func (m *Middleware) isStorable(resp plow.Response, header *specs.Header, policy RoutePolicy) bool {
+	if !slices.Contains(heuristicStatusCodes, resp.StatusCode()) {
+		return false
+	}
+
+	respHeader := resp.Header()
+	if respHeader.AnyCookies() || respHeader.Get("Transfer-Encoding") == "chunked" ||
+		slices.Contains(varyNames(respHeader), "*") {
+		return false
+	}
+
+	cc := parseCacheControl(respHeader)
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") {
+		return false
+	}
+	if header.Has("Authorization") && !cc.has("public") && !cc.has("s-maxage") {
+		return false
+	}
+
+	if policy.TTL <= 0 && !cc.has("s-maxage") && !cc.has("max-age") && !respHeader.Has("Expires") {
+		return false
+	}
+
+	if writer, ok := resp.(plow.BodyWriter); ok {
+		size := writer.ContentLength()
+		if size < 0 || m.MaxEntrySize > 0 && size > m.MaxEntrySize {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedLifetime returns the duration the response is fresh for shared cache.
+func sharedLifetime(entry *Entry, policy RoutePolicy) time.Duration {
+	if policy.TTL > 0 {
+		return policy.TTL
+	}
+	cc := parseCacheControl(entry.Header)
+	if sMaxAge, ok := cc.duration("s-maxage"); ok {
+		return sMaxAge
+	}
+	if !cc.has("max-age") && !entry.Header.Has("Expires") {
+		return 0
+	}
+	return freshnessLifetime(entry, cc)
+}
+
+// requestAuthority returns the lower-cased host of the request,
+// taken from "Host" header or the url of the request in absolute form.
+func requestAuthority(url *specs.Url, header *specs.Header) string {
+	if host := header.Get("Host"); host != "" {
+		return strings.ToLower(host)
+	}
+	host := strings.ToLower(url.Host)
+	if url.Port > 0 {
+		host += ":" + strconv.Itoa(int(url.Port))
+	}
+	return host
+}
+
+// requestKey returns path and sorted query of the url.
+func requestKey(url *specs.Url) string {
+	key := url.EscapedPath()
+	if key == "" {
+		key = "/"
+	}
+	if len(url.Query) > 0 {
+		key += "?" + url.Query.String()
+	}
+	return key
+}
+
+// variantKey returns the key with the values of nominated headers.
+func variantKey(key string, vary map[string]string) string {
+	names := make([]string, 0, len(vary))
+	for name := range vary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key += "\n" + name + ": " + vary[name]
+	}
+	return key
+}
+
+func newStoredResponse(entry *Entry, age time.Duration, err error) plow.Response {
+	header := entry.Header.Clone()
+	if age > 0 {
+		header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	}
+	return &storedResponse{
+		code:   entry.StatusCode,
+		header: header,
+		body:   entry.Body,
+		err:    err,
+	}
+}
+
+// storedResponse replays the stored body, then returns the error
+// which occurred while the body of the original response was written.
+type storedResponse struct {
+	code   specs.StatusCode
+	header *specs.Header
+	body   []byte
+	err    error
+}
+
+func (resp *storedResponse) StatusCode() specs.StatusCode {
+	return resp.code
+}
+
+func (resp *storedResponse) Header() *specs.Header {
+	return resp.header
+}
+
+func (resp *storedResponse) WriteBody(writer io.Writer) error {
+	if len(resp.body) > 0 {
+		if _, err := writer.Write(resp.body); err != nil {
+			return err
+		}
+	}
+	return resp.err
+}
+
+func (resp *storedResponse) ContentLength() int64 {
+	return int64(len(resp.body))
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/mock"
+	"github.com/oesand/plow/mux"
+	"github.com/oesand/plow/specs"
+)
+
+func serveCached(t *testing.T, mx mux.Mux, url string, configure ...func(header *specs.Header)) (plow.Response, string) {
+	t.Helper()
+	req := mock.DefaultRequest().Url(specs.MustParseUrl(url))
+	for _, conf := range configure {
+		req.ConfHeader(conf)
+	}
+
+	resp := mx.Handle(context.Background(), req.Request())
+	var body bytes.Buffer
+	if writer, ok := resp.(plow.BodyWriter); ok {
+		if err := writer.WriteBody(&body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	var hits atomic.Int32
+	handler := func(cacheControl string) plow.Handler {
+		return plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+			count := hits.Add(1)
+			return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain,
+				request.Url().Path+" "+strconv.Itoa(int(count)), func(resp plow.Response) {
+					if cacheControl != "" {
+						resp.Header().Set("Cache-Control", cacheControl)
+					}
+					if cacheControl == "max-age=60, vary" {
+						resp.Header().Set("Vary", "Accept-Language")
+					}
+				})
+		})
+	}
+
+	mx := mux.New().
+		Use(NewMiddleware(nil)).
+		Route(specs.HttpMethodGet, "/max-age", handler("max-age=60")).
+		Route(specs.HttpMethodGet, "/s-maxage", handler("max-age=0, s-maxage=60")).
+		Route(specs.HttpMethodGet, "/private", handler("private, max-age=60")).
+		Route(specs.HttpMethodGet, "/no-store", handler("no-store")).
+		Route(specs.HttpMethodGet, "/implicit", handler("")).
+		Route(specs.HttpMethodGet, "/ttl", handler(""), RoutePolicy{TTL: time.Minute}).
+		Route(specs.HttpMethodGet, "/disabled", handler("max-age=60"), RoutePolicy{Disable: true}).
+		Route(specs.HttpMethodGet, "/vary", handler("max-age=60, vary")).
+		Route(specs.HttpMethodGet, "/policy-vary", handler("max-age=60"), RoutePolicy{Vary: []string{"X-Tenant"}})
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/max-age", true},
+		{"/s-maxage", true},
+		{"/private", false},
+		{"/no-store", false},
+		{"/implicit", false},
+		{"/ttl", true},
+		{"/disabled", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			hits.Store(0)
+			_, first := serveCached(t, mx, tt.path)
+			resp, second := serveCached(t, mx, tt.path)
+
+			if want := tt.path + " 1"; tt.cached && second != want {
+				t.Errorf("expected cached body %q, got %q", want, second)
+			} else if want := tt.path + " 2"; !tt.cached && second != want {
+				t.Errorf("expected handled body %q, got %q", want, second)
+			}
+			if first != tt.path+" 1" {
+				t.Errorf("unexpected first body %q", first)
+			}
+			if tt.cached && (!resp.Header().Has("Age") || resp.Header().Get("Content-Type") != specs.ContentTypePlain) {
+				t.Errorf("unexpected cached header %+v", resp.Header())
+			}
+		})
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		hits.Store(0)
+		serveCached(t, mx, "/max-age?b=2&a=1")
+		if _, body := serveCached(t, mx, "/max-age?a=1&b=2"); body != "/max-age 1" {
+			t.Errorf("expected cached body for reordered query, got %q", body)
+		}
+		if _, body := serveCached(t, mx, "/max-age?a=2"); body != "/max-age 2" {
+			t.Errorf("expected handled body for other query, got %q", body)
+		}
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		for _, path := range []string{"/vary", "/policy-vary"} {
+			hits.Store(0)
+			en := func(header *specs.Header) {
+				header.Set("Accept-Language", "en")
+				header.Set("X-Tenant", "one")
+			}
+			de := func(header *specs.Header) {
+				header.Set("Accept-Language", "de")
+				header.Set("X-Tenant", "two")
+			}
+
+			serveCached(t, mx, path, en)
+			serveCached(t, mx, path, de)
+			if _, body := serveCached(t, mx, path, en); body != path+" 1" {
+				t.Errorf("expected cached first variant of %s, got %q", path, body)
+			}
+			if _, body := serveCached(t, mx, path, de); body != path+" 2" {
+				t.Errorf("expected cached second variant of %s, got %q", path, body)
+			}
+		}
+	})
+
+	t.Run("Authorization", func(t *testing.T) {
+		hits.Store(0)
+		auth := func(header *specs.Header) {
+			header.Set("Authorization", "Bearer token")
+		}
+		serveCached(t, mx, "/max-age?user=1", auth)
+		if _, body := serveCached(t, mx, "/max-age?user=1", auth); body != "/max-age 2" {
+			t.Errorf("expected authorized response not stored, got %q", body)
+		}
+		serveCached(t, mx, "/s-maxage?user=1", auth)
+		if _, body := serveCached(t, mx, "/s-maxage?user=1", auth); body != "/s-maxage 3" {
+			t.Errorf("expected authorized response with s-maxage stored, got %q", body)
+		}
+	})
+}
+
+type recordingStore struct {
+	Store
+	keys []string
+}
+
+func (store *recordingStore) Set(key string, entry *Entry) {
+	store.keys = append(store.keys, key)
+	if entry.StatusCode == 0 {
+		panic("unexpected entry without status code")
+	}
+	store.Store.Set(key, entry)
+}
+
+func TestMiddleware_Keys(t *testing.T) {
+	var hits atomic.Int32
+	store := &recordingStore{Store: NewMemoryStore(0)}
+	mx := mux.New().
+		Use(NewMiddleware(store)).
+		Route(specs.HttpMethodGet, "/", plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+			body := request.Header().Get("Host") + " " + strconv.Itoa(int(hits.Add(1)))
+			return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, body, func(resp plow.Response) {
+				resp.Header().Set("Cache-Control", "max-age=60")
+				resp.Header().Set("Vary", "Accept-Language")
+			})
+		}))
+
+	request := func(host, language string) func(header *specs.Header) {
+		return func(header *specs.Header) {
+			header.Set("Host", host)
+			header.Set("Accept-Language", language)
+		}
+	}
+
+	serveCached(t, mx, "/", request("one.example", "en"))
+	serveCached(t, mx, "/", request("two.example", "en"))
+	serveCached(t, mx, "/", request("one.example", "de"))
+
+	tests := []struct {
+		host, language, want string
+	}{
+		{"one.example", "en", "one.example 1"},
+		{"two.example", "en", "two.example 2"},
+		{"ONE.example", "de", "one.example 3"},
+	}
+	for _, tt := range tests {
+		if _, body := serveCached(t, mx, "/", request(tt.host, tt.language)); body != tt.want {
+			t.Errorf("expected cached body %q for %s %s, got %q", tt.want, tt.host, tt.language, body)
+		}
+	}
+	if len(store.keys) != 3 {
+		t.Errorf("expected one entry per variant, got keys %q", store.keys)
+	}
+}
+
+func TestMiddleware_Precompressed(t *testing.T) {
+	fsrv := mux.DefaultFileServer(fstest.MapFS{
+		"app.js":    {Data: []byte("console.log('plain')")},
+		"app.js.gz": {Data: []byte("gzipped")},
+	})
+	mx := mux.New().
+		Use(NewMiddleware(nil)).
+		Route(specs.HttpMethodGet, "/static/{*}", fsrv, RoutePolicy{TTL: time.Minute})
+
+	acceptGzip := func(header *specs.Header) {
+		header.Set("Accept-Encoding", "gzip")
+	}
+
+	tests := []struct {
+		name      string
+		configure func(header *specs.Header)
+		encoding  string
+		body      string
+		cached    bool
+	}{
+		{"first gzip", acceptGzip, "gzip", "gzipped", false},
+		{"cached gzip", acceptGzip, "gzip", "gzipped", true},
+		{"identity", func(header *specs.Header) {}, "", "console.log('plain')", false},
+		{"cached identity", func(header *specs.Header) {}, "", "console.log('plain')", true},
+	}
+	for _, tt := range tests {
+		resp, body := serveCached(t, mx, "/static/app.js", tt.configure)
+		if got := resp.Header().Get("Content-Encoding"); got != tt.encoding || body != tt.body {
+			t.Errorf("%s: expected %q with encoding %q, got %q with %q", tt.name, tt.body, tt.encoding, body, got)
+		}
+		if cached := resp.Header().Has("Age"); cached != tt.cached {
+			t.Errorf("%s: expected cached %v, got %v", tt.name, tt.cached, cached)
+		}
+	}
+}
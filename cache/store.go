@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+// Entry is a response stored in the [Store].
+type Entry struct {
+	// StatusCode of the stored response.
+	StatusCode specs.StatusCode
+
+	// Header of the stored response without cookies.
+	Header *specs.Header
+
+	// Body of the stored response.
+	Body []byte
+
+	// Vary contains the values of request headers
+	// nominated by "Vary" response header.
+	Vary map[string]string
+
+	// RequestTime is the time when the request of the response was sent.
+	RequestTime time.Time
+
+	// ResponseTime is the time when the response was received.
+	ResponseTime time.Time
+}
+
+// Size returns approximate size of the entry in bytes.
+func (entry *Entry) Size() int64 {
+	size := int64(len(entry.Body))
+	for name, value := range entry.Header.All() {
+		size += int64(len(name) + len(value))
+	}
+	for name, value := range entry.Vary {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+// matchVary checks the request header has the same values
+// of the headers nominated by "Vary" as the entry.
+func (entry *Entry) matchVary(header *specs.Header) bool {
+	for name, value := range entry.Vary {
+		if strings.Join(header.Values(name), ", ") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Store is an interface representing storage of cached responses by keys.
+//
+// Store must be safe for concurrent use,
+// stored entries must not be modified.
+type Store interface {
+	// Get returns the entry stored by the key or nil.
+	Get(key string) *Entry
+
+	// Set stores the entry by the key, replacing existing one.
+	Set(key string, entry *Entry)
+
+	// Delete removes the entry stored by the key.
+	Delete(key string)
+}
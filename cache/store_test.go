@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+func newTestEntry(body string) *Entry {
+	return &Entry{
+		StatusCode:   specs.StatusCodeOK,
+		Header:       specs.NewHeader(func(header *specs.Header) { header.Set("Etag", `"1"`) }),
+		Body:         []byte(body),
+		Vary:         map[string]string{"Accept": "text/html"},
+		RequestTime:  time.Now().Add(-time.Second).UTC(),
+		ResponseTime: time.Now().UTC(),
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	size := newTestEntry("0123456789").Size() + 1
+	store := NewMemoryStore(2*size + size/2)
+	store.Set("a", newTestEntry("0123456789"))
+	store.Set("b", newTestEntry("0123456789"))
+
+	if store.Get("a") == nil {
+		t.Fatal("expected entry a")
+	}
+	store.Set("c", newTestEntry("0123456789"))
+	if store.Get("b") != nil || store.Get("a") == nil || store.Get("c") == nil {
+		t.Errorf("expected least recently used entry b evicted, len %d", store.Len())
+	}
+
+	store.Set("large", newTestEntry(string(make([]byte, 3*size))))
+	if store.Get("large") != nil || store.Len() != 2 {
+		t.Errorf("expected too large entry not stored, len %d", store.Len())
+	}
+
+	store.Delete("a")
+	if store.Get("a") != nil || store.Len() != 1 {
+		t.Error("expected entry deleted")
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := newTestEntry("content")
+	store.Set("https://example.com:443/", entry)
+
+	got := store.Get("https://example.com:443/")
+	if got == nil {
+		t.Fatal("expected stored entry")
+	}
+	if got.StatusCode != entry.StatusCode || string(got.Body) != "content" ||
+		got.Header.Get("ETag") != `"1"` || got.Vary["Accept"] != "text/html" ||
+		!got.ResponseTime.Equal(entry.ResponseTime) {
+		t.Errorf("unexpected entry %+v", got)
+	}
+
+	if store.Get("https://example.com:443/other") != nil {
+		t.Error("expected missing entry")
+	}
+
+	store.Delete("https://example.com:443/")
+	if store.Get("https://example.com:443/") != nil {
+		t.Error("expected entry deleted")
+	}
+}
+
+func TestDiskStore_Eviction(t *testing.T) {
+	dir := t.TempDir()
+	probe, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe.Set("a", newTestEntry("0123456789"))
+	size := probe.Size()
+
+	store, err := NewDiskStore(dir, 2*size+size/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", newTestEntry("0123456789"))
+	store.Set("b", newTestEntry("0123456789"))
+	if store.Get("a") == nil {
+		t.Fatal("expected entry a")
+	}
+	store.Set("c", newTestEntry("0123456789"))
+	if store.Get("b") != nil || store.Get("a") == nil || store.Get("c") == nil {
+		t.Errorf("expected least recently used entry b evicted, len %d", store.Len())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("expected evicted file removed, got %d files", len(files))
+	}
+
+	reopened, err := NewDiskStore(dir, 2*size+size/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 2 || reopened.Size() != store.Size() {
+		t.Errorf("expected stored files indexed, len %d size %d", reopened.Len(), reopened.Size())
+	}
+}
+
+func TestDiskStore_Corrupted(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("key", newTestEntry("content"))
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %d", len(files))
+	}
+	if err = os.WriteFile(filepath.Join(dir, files[0].Name()), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.Get("key") != nil {
+		t.Error("expected corrupted entry not returned")
+	}
+	if files, _ = os.ReadDir(dir); len(files) != 0 || store.Len() != 0 {
+		t.Errorf("expected corrupted file removed, got %d files", len(files))
+	}
+}
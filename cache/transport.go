@@ -0,0 +1,431 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/client_ops"
+	"github.com/oesand/plow/specs"
+)
+
+// DefaultMaxEntrySize default value for Transport.MaxEntrySize parameter
+const DefaultMaxEntrySize int64 = 10 << 20 // 10 mb
+
+// NewTransport returns a new caching Transport which sends requests
+// with the transport and keeps responses in the store.
+//
+// If transport is nil, [plow.DefaultTransport] is used,
+// if store is nil, [NewMemoryStore] with default size is used.
+func NewTransport(transport plow.RoundTripper, store Store) *Transport {
+	if transport == nil {
+		transport = plow.DefaultTransport()
+	}
+	if store == nil {
+		store = NewMemoryStore(0)
+	}
+	return &Transport{
+		Transport:    transport,
+		Store:        store,
+		MaxEntrySize: DefaultMaxEntrySize,
+	}
+}
+
+// Transport is a [plow.RoundTripper] which implements private HTTP cache of RFC 9111.
+//
+// Responses to GET requests are stored according to "Cache-Control",
+// "Expires" and "Vary" headers. Fresh responses are served from the cache,
+// stale responses are revalidated with conditional requests by "ETag" and "Last-Modified",
+// or served while revalidated in background within "stale-while-revalidate" window.
+//
+// The response is stored when its body is fully read.
+// Successful unsafe requests such as POST invalidate the stored response of the url.
+// Requests with "Range" or own conditional headers bypass the cache.
+type Transport struct {
+	_ internal.NoCopy
+
+	// Transport specifies the mechanism by which requests are sent.
+	Transport plow.RoundTripper
+
+	// Store specifies the storage of cached responses.
+	Store Store
+
+	// MaxEntrySize maximum size in bytes of the response body to store,
+	// larger responses are passed without storing.
+	//
+	// If zero there is no limit
+	MaxEntrySize int64
+
+	revalidating sync.Map
+}
+
+// RoundTrip implements the [plow.RoundTripper] interface.
+func (t *Transport) RoundTrip(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer plow.BodyWriter) (plow.ClientResponse, error) {
+	if t.Transport == nil || t.Store == nil {
+		panic("plow: cache transport requires Transport and Store")
+	}
+
+	if method != specs.HttpMethodGet {
+		resp, err := t.Transport.RoundTrip(ctx, method, url, header, writer)
+		if err == nil && !isSafeMethod(method) && resp.StatusCode() < 400 {
+			t.Store.Delete(Key(url))
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(header)
+	if reqCC.has("no-store") || header.Has("Range") ||
+		header.Has("If-None-Match") || header.Has("If-Modified-Since") {
+		return t.Transport.RoundTrip(ctx, method, url, header, writer)
+	}
+
+	key := Key(url)
+	entry := t.Store.Get(key)
+	if entry != nil && !entry.matchVary(header) {
+		entry = nil
+	}
+
+	if entry != nil {
+		respCC := parseCacheControl(entry.Header)
+		age := currentAge(entry, time.Now())
+		lifetime := freshnessLifetime(entry, respCC)
+
+		fresh := age < lifetime
+		if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+			fresh = false
+		}
+		if minFresh, ok := reqCC.duration("min-fresh"); ok && age+minFresh >= lifetime {
+			fresh = false
+		}
+
+		noCache := reqCC.has("no-cache") || respCC.has("no-cache") ||
+			strings.EqualFold(header.Get("Pragma"), "no-cache") && !header.Has("Cache-Control")
+		if !noCache {
+			if fresh {
+				return newCachedResponse(entry, age), nil
+			}
+
+			staleness := age - lifetime
+			if !respCC.has("must-revalidate") {
+				if window, ok := respCC.duration("stale-while-revalidate"); ok && staleness < window {
+					t.revalidate(ctx, url, header, key, entry)
+					return newCachedResponse(entry, age), nil
+				}
+				if value, ok := reqCC["max-stale"]; ok {
+					if maxStale, ok := reqCC.duration("max-stale"); value == "" || ok && staleness <= maxStale {
+						return newCachedResponse(entry, age), nil
+					}
+				}
+			}
+		}
+	}
+
+	if reqCC.has("only-if-cached") {
+		return newCachedResponse(&Entry{
+			StatusCode: specs.StatusCodeGatewayTimeout,
+			Header:     specs.NewHeader(),
+		}, 0), nil
+	}
+
+	return t.fetch(ctx, url, header, key, entry)
+}
+
+// fetch sends the request, conditional if the entry has validators,
+// and stores the response.
+func (t *Transport) fetch(ctx context.Context, url *specs.Url, header *specs.Header, key string, entry *Entry) (plow.ClientResponse, error) {
+	reqHeader := header.Clone()
+	if entry != nil {
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			reqHeader.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			reqHeader.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := t.Transport.RoundTrip(ctx, specs.HttpMethodGet, url, reqHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+
+	if entry != nil && resp.StatusCode() == specs.StatusCodeNotModified {
+		if body := resp.Body(); body != nil {
+			body.Close()
+		}
+
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for name := range resp.Header().All() {
+			if !slices.Contains(skipHeaders, name) {
+				updated.Header.Del(name)
+			}
+		}
+		for name, value := range resp.Header().All() {
+			if !slices.Contains(skipHeaders, name) {
+				updated.Header.Add(name, value)
+			}
+		}
+		updated.RequestTime, updated.ResponseTime = requestTime, responseTime
+		t.Store.Set(key, &updated)
+		return newCachedResponse(&updated, 0), nil
+	}
+
+	if !isStorable(resp) {
+		return resp, nil
+	}
+
+	stored := &Entry{
+		StatusCode:   resp.StatusCode(),
+		Header:       specs.NewHeader(),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	for name, value := range resp.Header().All() {
+		if !slices.Contains(skipHeaders, name) {
+			stored.Header.Add(name, value)
+		}
+	}
+	for _, name := range varyNames(resp.Header()) {
+		if stored.Vary == nil {
+			stored.Vary = map[string]string{}
+		}
+		stored.Vary[name] = strings.Join(header.Values(name), ", ")
+	}
+
+	store := func(body []byte) {
+		stored.Body = body
+		stored.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		t.Store.Set(key, stored)
+	}
+
+	body := resp.Body()
+	if body == nil {
+		store(nil)
+		return resp, nil
+	}
+	return &recordedResponse{
+		ClientResponse: resp,
+		body: &recordingBody{
+			ReadCloser: body,
+			limit:      t.MaxEntrySize,
+			done:       store,
+		},
+	}, nil
+}
+
+// revalidate fetches the response in background,
+// only one revalidation of the key is performed at a time.
+func (t *Transport) revalidate(ctx context.Context, url *specs.Url, header *specs.Header, key string, entry *Entry) {
+	if _, loaded := t.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	urlCopy := *url
+	header = header.Clone()
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer t.revalidating.Delete(key)
+
+		resp, err := t.fetch(ctx, &urlCopy, header, key, entry)
+		if err != nil {
+			return
+		}
+		if body := resp.Body(); body != nil {
+			io.Copy(io.Discard, body)
+			body.Close()
+		}
+	}()
+}
+
+// Key returns the key of the [Store] for the url,
+// which consists of scheme, host, port, path and query.
+func Key(url *specs.Url) string {
+	port := url.Port
+	if port == 0 {
+		port = defaultPorts[url.Scheme]
+	}
+
+	path := url.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	key := url.Scheme + "://" + client_ops.HostPort(strings.ToLower(url.Host), port) + path
+	if len(url.Query) > 0 {
+		key += "?" + url.Query.String()
+	}
+	return key
+}
+
+var (
+	defaultPorts = map[string]uint16{
+		"http":  80,
+		"https": 443,
+	}
+
+	// skipHeaders are not stored, body is stored decoded
+	skipHeaders = []string{
+		"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding",
+		"Content-Encoding", "Content-Length", "Trailer", "Upgrade",
+	}
+
+	// heuristicStatusCodes are cacheable by default of RFC 9110
+	heuristicStatusCodes = []specs.StatusCode{
+		specs.StatusCodeOK, specs.StatusCodeNonAuthoritativeInfo, specs.StatusCodeNoContent,
+		specs.StatusCodeMultipleChoices, specs.StatusCodeMovedPermanently, specs.StatusCodePermanentRedirect,
+		specs.StatusCodeNotFound, specs.StatusCodeMethodNotAllowed, specs.StatusCodeGone,
+		specs.StatusCodeRequestURITooLong, specs.StatusCodeNotImplemented,
+	}
+)
+
+func isSafeMethod(method specs.HttpMethod) bool {
+	return method == specs.HttpMethodGet || method == specs.HttpMethodHead ||
+		method == specs.HttpMethodOptions || method == specs.HttpMethodTrace
+}
+
+func varyNames(header *specs.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// isStorable checks the response can be stored by private cache.
+func isStorable(resp plow.ClientResponse) bool {
+	code := resp.StatusCode()
+	if code < 200 || code == specs.StatusCodePartialContent || code == specs.StatusCodeNotModified {
+		return false
+	}
+	if slices.Contains(varyNames(resp.Header()), "*") {
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header())
+	if cc.has("no-store") {
+		return false
+	}
+	return cc.has("max-age") || cc.has("public") || cc.has("private") ||
+		resp.Header().Has("Expires") || slices.Contains(heuristicStatusCodes, code)
+}
+
+// freshnessLifetime returns the duration the response is fresh.
+func freshnessLifetime(entry *Entry, cc cacheControl) time.Duration {
+	if maxAge, ok := cc.duration("max-age"); ok {
+		return maxAge
+	}
+
+	date, ok := parseHttpDate(entry.Header, "Date")
+	if !ok {
+		date = entry.ResponseTime
+	}
+	if entry.Header.Has("Expires") {
+		if expires, ok := parseHttpDate(entry.Header, "Expires"); ok {
+			return max(expires.Sub(date), 0)
+		}
+		return 0
+	}
+
+	if lastModified, ok := parseHttpDate(entry.Header, "Last-Modified"); ok &&
+		slices.Contains(heuristicStatusCodes, entry.StatusCode) {
+		return min(max(date.Sub(lastModified)/10, 0), 24*time.Hour)
+	}
+	return 0
+}
+
+// currentAge returns the age of the response by the calculation of RFC 9111.
+func currentAge(entry *Entry, now time.Time) time.Duration {
+	date, ok := parseHttpDate(entry.Header, "Date")
+	if !ok {
+		date = entry.ResponseTime
+	}
+
+	var ageValue time.Duration
+	if seconds, err := strconv.ParseInt(entry.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+
+	apparentAge := max(entry.ResponseTime.Sub(date), 0)
+	correctedAge := ageValue + entry.ResponseTime.Sub(entry.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(entry.ResponseTime)
+}
+
+func newCachedResponse(entry *Entry, age time.Duration) *cachedResponse {
+	header := entry.Header.Clone()
+	if age > 0 {
+		header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	}
+	return &cachedResponse{
+		entry:  entry,
+		header: header,
+	}
+}
+
+type cachedResponse struct {
+	entry  *Entry
+	header *specs.Header
+}
+
+func (resp *cachedResponse) StatusCode() specs.StatusCode {
+	return resp.entry.StatusCode
+}
+
+func (resp *cachedResponse) Header() *specs.Header {
+	return resp.header
+}
+
+func (resp *cachedResponse) Body() io.ReadCloser {
+	if len(resp.entry.Body) == 0 {
+		return nil
+	}
+	return io.NopCloser(bytes.NewReader(resp.entry.Body))
+}
+
+type recordedResponse struct {
+	plow.ClientResponse
+	body *recordingBody
+}
+
+func (resp *recordedResponse) Body() io.ReadCloser {
+	return resp.body
+}
+
+// recordingBody copies the read body and calls done
+// with the copy when the body is read to the end within the limit.
+type recordingBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+	done     func(body []byte)
+}
+
+func (body *recordingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if !body.exceeded && n > 0 {
+		if body.limit > 0 && int64(body.buf.Len()+n) > body.limit {
+			body.exceeded = true
+			body.buf = bytes.Buffer{}
+		} else {
+			body.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !body.exceeded && body.done != nil {
+		body.done(bytes.Clone(body.buf.Bytes()))
+		body.done = nil
+	}
+	return n, err
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+)
+
+func newTestTransport(t *testing.T, handler http.HandlerFunc) (*Transport, string, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	inner := plow.DefaultTransport()
+	t.Cleanup(inner.CloseIdleConnections)
+	return NewTransport(inner, nil), server.URL, &hits
+}
+
+func get(t *testing.T, transport plow.RoundTripper, url string, configure ...func(header *specs.Header)) (plow.ClientResponse, string) {
+	t.Helper()
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet, specs.MustParseUrl(url), specs.NewHeader(configure...), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	if reader := resp.Body(); reader != nil {
+		body, _ = io.ReadAll(reader)
+		reader.Close()
+	}
+	return resp, string(body)
+}
+
+func TestTransport_Fresh(t *testing.T) {
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh " + r.URL.RawQuery))
+	})
+
+	for range 3 {
+		resp, body := get(t, transport, url+"/item?id=1")
+		if resp.StatusCode() != specs.StatusCodeOK || body != "fresh id=1" {
+			t.Errorf("unexpected response %d %q", resp.StatusCode(), body)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected single request, got %d", hits.Load())
+	}
+
+	if _, body := get(t, transport, url+"/item?id=2"); body != "fresh id=2" || hits.Load() != 2 {
+		t.Errorf("expected different query to be fetched, got %q after %d", body, hits.Load())
+	}
+
+	if resp, _ := get(t, transport, url+"/item?id=1", func(header *specs.Header) {
+		header.Set("Cache-Control", "no-cache")
+	}); resp.Header().Get("Age") != "" || hits.Load() != 3 {
+		t.Errorf("expected request no-cache to bypass fresh response, got %d", hits.Load())
+	}
+}
+
+func TestTransport_Revalidate(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(specs.TimeFormat)
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.Header().Set("X-Revalidated", "1")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/modified":
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte("content"))
+	})
+
+	for _, path := range []string{"/etag", "/modified"} {
+		t.Run(path, func(t *testing.T) {
+			hits.Store(0)
+			get(t, transport, url+path)
+			resp, body := get(t, transport, url+path)
+			if resp.StatusCode() != specs.StatusCodeOK || body != "content" || hits.Load() != 2 {
+				t.Errorf("unexpected revalidated response %d %q after %d", resp.StatusCode(), body, hits.Load())
+			}
+		})
+	}
+
+	if resp, _ := get(t, transport, url+"/etag"); resp.Header().Get("X-Revalidated") != "1" {
+		t.Errorf("expected headers updated by 304 response, got %+v", resp.Header())
+	}
+}
+
+func TestTransport_StaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Write([]byte{byte('0' + version.Add(1))})
+	})
+
+	if _, body := get(t, transport, url); body != "1" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if _, body := get(t, transport, url); body != "1" {
+		t.Errorf("expected stale response, got %q", body)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hits.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for time.Now().Before(deadline) {
+		if _, body := get(t, transport, url); body == "2" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("expected response revalidated in background")
+}
+
+func TestTransport_Vary(t *testing.T) {
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	})
+
+	lang := func(value string) func(header *specs.Header) {
+		return func(header *specs.Header) {
+			header.Set("Accept-Language", value)
+		}
+	}
+
+	get(t, transport, url, lang("en"))
+	if _, body := get(t, transport, url, lang("en")); body != "en" || hits.Load() != 1 {
+		t.Errorf("expected cached variant, got %q after %d", body, hits.Load())
+	}
+	if _, body := get(t, transport, url, lang("de")); body != "de" || hits.Load() != 2 {
+		t.Errorf("expected other variant fetched, got %q after %d", body, hits.Load())
+	}
+}
+
+func TestTransport_NotStored(t *testing.T) {
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/expired":
+			w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(specs.TimeFormat))
+		case "/partial":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write([]byte("content"))
+	})
+
+	for _, path := range []string{"/no-store", "/expired", "/partial"} {
+		hits.Store(0)
+		get(t, transport, url+path)
+		get(t, transport, url+path)
+		if hits.Load() != 2 {
+			t.Errorf("expected %s not served from cache, got %d requests", path, hits.Load())
+		}
+	}
+
+	if resp, _ := get(t, transport, url+"/unknown", func(header *specs.Header) {
+		header.Set("Cache-Control", "only-if-cached")
+	}); resp.StatusCode() != specs.StatusCodeGatewayTimeout {
+		t.Errorf("expected 504 for only-if-cached, got %d", resp.StatusCode())
+	}
+}
+
+func TestTransport_Invalidate(t *testing.T) {
+	transport, url, hits := newTestTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("content"))
+	})
+
+	get(t, transport, url+"/item")
+	get(t, transport, url+"/item")
+
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodPost, specs.MustParseUrl(url+"/item"),
+		specs.NewHeader(), plow.TextRequest(specs.HttpMethodPost, specs.MustParseUrl(url), "", "data").(plow.BodyWriter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body())
+	resp.Body().Close()
+
+	get(t, transport, url+"/item")
+	if hits.Load() != 3 {
+		t.Errorf("expected response invalidated by POST, got %d requests", hits.Load())
+	}
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name   string
+		header map[string]string
+		status specs.StatusCode
+		want   time.Duration
+	}{
+		{"MaxAge", map[string]string{"Cache-Control": "public, max-age=120"}, 200, 2 * time.Minute},
+		{"Expires", map[string]string{"Date": now.Format(specs.TimeFormat), "Expires": now.Add(time.Hour).Format(specs.TimeFormat)}, 200, time.Hour},
+		{"InvalidExpires", map[string]string{"Expires": "0"}, 200, 0},
+		{"Heuristic", map[string]string{"Date": now.Format(specs.TimeFormat), "Last-Modified": now.Add(-10 * time.Hour).Format(specs.TimeFormat)}, 200, time.Hour},
+		{"HeuristicNotCacheable", map[string]string{"Last-Modified": now.Add(-10 * time.Hour).Format(specs.TimeFormat)}, 201, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &Entry{StatusCode: tt.status, Header: specs.NewHeader(), ResponseTime: now}
+			for name, value := range tt.header {
+				entry.Header.Set(name, value)
+			}
+			if got := freshnessLifetime(entry, parseCacheControl(entry.Header)); got != tt.want {
+				t.Errorf("freshnessLifetime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
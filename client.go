@@ -2,12 +2,35 @@ package plow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/oesand/plow/internal/catch"
 	"github.com/oesand/plow/specs"
+	"strings"
 	"sync"
 )
 
+// ErrUseLastResponse can be returned by [Client.CheckRedirect]
+// to stop following redirects and return the last response
+// with its body unclosed.
+var ErrUseLastResponse = errors.New("plow: use last response")
+
+// Redirect is a request of the redirect hop which is sent by the [Client].
+type Redirect struct {
+	// Method specifies the HTTP method of the request.
+	Method specs.HttpMethod
+
+	// Url specifies the URL of the request.
+	Url *specs.Url
+
+	// Header contains the request header fields and cookies.
+	Header *specs.Header
+
+	// Response is the redirect response which caused the request,
+	// nil for the initial request.
+	Response ClientResponse
+}
+
 // DefaultClient factory for creating [Client]
 // with optimal parameters for perfomance and safety
 //
@@ -35,6 +58,34 @@ type Client struct {
 	// if not specified is used [DefaultMaxRedirectCount]
 	MaxRedirectCount int
 
+	// CheckRedirect specifies the policy for handling redirects.
+	// If CheckRedirect is not nil, the client calls it before
+	// following the redirect with the upcoming request and the requests made already,
+	// oldest first. The function can modify method, url and header of the request.
+	//
+	// If CheckRedirect returns [ErrUseLastResponse], the most recent response
+	// is returned with its body unclosed, if it returns other error,
+	// the error is returned by [Client.Make].
+	//
+	// Before the call, sensitive headers such as "Authorization" and cookies
+	// are removed from the request on redirect to other scheme, host or port,
+	// cookies of the [Client.Jar] for the new host and [Client.Header] values are applied.
+	//
+	// Request body is sent again on 307 and 308 redirects only if it implements [Rewinder],
+	// otherwise the redirect response is returned.
+	CheckRedirect func(req *Redirect, via []*Redirect) error
+
+	// Interceptors specifies the chain of [Interceptor] that wraps
+	// each request sent by the [Transport], including retries and redirects.
+	// Interceptors are executed in the order they are provided.
+	Interceptors []Interceptor
+
+	// RetryPolicy specifies how failed requests are retried,
+	// each redirect is retried independently.
+	//
+	// If nil, requests are not retried.
+	RetryPolicy *RetryPolicy
+
 	// Header specifies independent request header and cookies
 	//
 	// The Header is used to insert headers and cookies
@@ -116,30 +167,7 @@ func (cln *Client) MakeContext(ctx context.Context, request ClientRequest) (Clie
 		maxRedirectCount = cln.MaxRedirectCount
 	}
 
-	if cln.Jar != nil {
-		cln.mu.RLock()
-		for cookie := range cln.Jar.Cookies(url.Host) {
-			if !header.HasCookie(cookie.Name) {
-				header.SetCookie(cookie)
-			}
-		}
-		cln.mu.RUnlock()
-	}
-
-	if cln.Header != nil {
-		cln.mu.RLock()
-		for name, value := range cln.Header.All() {
-			if !header.Has(name) {
-				header.Set(name, value)
-			}
-		}
-		for cookie := range cln.Header.Cookies() {
-			if !header.HasCookie(cookie.Name) {
-				header.SetCookie(cookie)
-			}
-		}
-		cln.mu.RUnlock()
-	}
+	cln.applyHeader(url, header)
 
 	writer, _ := request.(BodyWriter)
 	transport := cln.Transport
@@ -151,68 +179,183 @@ func (cln *Client) MakeContext(ctx context.Context, request ClientRequest) (Clie
 		transport = cln.Transport
 		cln.mu.Unlock()
 	}
+	if len(cln.Interceptors) > 0 {
+		transport = Intercept(transport, cln.Interceptors...)
+	}
 
 	var redirectCount int
+	var via []*Redirect
 	for {
 		if err := ctx.Err(); err != nil {
 			return nil, catch.CatchCommonErr(err)
 		}
 
-		resp, err := transport.RoundTrip(ctx, method, url, header, writer)
-
-		if err == nil {
-			err = ctx.Err()
-		}
+		resp, err := cln.RetryPolicy.roundTrip(ctx, transport, method, url, header, writer)
 		if err != nil {
 			return nil, catch.CatchCommonErr(err)
 		}
 
 		if cln.Jar != nil {
 			cln.mu.Lock()
-			cln.Jar.SetCookiesIter(url.Host, resp.Header().Cookies())
+			cln.Jar.SetCookiesForUrl(url, resp.Header().Cookies())
 			cln.mu.Unlock()
 		}
 
 		code := resp.StatusCode()
-		if code.IsRedirect() {
-			if redirectCount >= maxRedirectCount {
-				return nil, specs.NewOpError("redirect", "too many redirects")
+		if !code.IsRedirect() {
+			return resp, nil
+		}
+
+		if redirectCount >= maxRedirectCount {
+			return nil, specs.NewOpError("redirect", "too many redirects")
+		}
+		redirectCount++
+
+		location := resp.Header().Get("Location")
+		if location == "" {
+			return nil, specs.NewOpError("redirect", "empty Location header")
+		}
+
+		redirectUrl, err := specs.ParseUrl(location)
+		if err != nil {
+			return nil, specs.NewOpError("redirect", "cannot parse location header url")
+		}
+
+		if redirectUrl.Scheme == "" {
+			redirectUrl.Scheme = url.Scheme
+		} else if !(redirectUrl.Scheme == "http" || redirectUrl.Scheme == "https") {
+			return nil, specs.NewOpError("redirect", "invalid request url '%s' scheme", redirectUrl.Scheme)
+		}
+		if redirectUrl.Host == "" {
+			redirectUrl.Host = url.Host
+			redirectUrl.Port = url.Port
+		}
+
+		redirectMethod := method
+		redirectWriter := writer
+		redirectHeader := header.Clone()
+		if code == specs.StatusCodeTemporaryRedirect || code == specs.StatusCodePermanentRedirect {
+			// The body must be sent again with the same method
+			if redirectWriter != nil && method.IsPostable() {
+				rewinder, ok := redirectWriter.(Rewinder)
+				if !ok {
+					return resp, nil
+				}
+				if err = rewinder.Rewind(); err != nil {
+					return nil, err
+				}
 			}
-			redirectCount++
-
-			if (code == specs.StatusCodeMovedPermanently ||
-				code == specs.StatusCodeSeeOther ||
-				code == specs.StatusCodeFound) &&
-				(method != specs.HttpMethodGet &&
-					method != specs.HttpMethodHead) {
-				method = specs.HttpMethodGet
+		} else if method != specs.HttpMethodGet && method != specs.HttpMethodHead {
+			redirectMethod = specs.HttpMethodGet
+			redirectWriter = nil
+			for _, name := range bodyHeaders {
+				redirectHeader.Del(name)
 			}
+		}
 
-			location := resp.Header().Get("Location")
-			if location == "" {
-				return nil, specs.NewOpError("redirect", "empty Location header")
-			}
+		if !sameOrigin(url, redirectUrl) {
+			stripSensitiveHeaders(redirectHeader)
+		}
+		cln.applyHeader(redirectUrl, redirectHeader)
 
-			var redirectUrl *specs.Url
-			redirectUrl, err = specs.ParseUrl(location)
-			if err != nil {
-				return nil, specs.NewOpError("redirect", "cannot parse location header url")
-			}
+		if via == nil {
+			via = append(via, &Redirect{
+				Method: method,
+				Url:    url,
+				Header: header,
+			})
+		}
+		redirect := &Redirect{
+			Method:   redirectMethod,
+			Url:      redirectUrl,
+			Header:   redirectHeader,
+			Response: resp,
+		}
 
-			if !(redirectUrl.Scheme == "" || redirectUrl.Scheme == "http" || redirectUrl.Scheme == "https") {
-				return nil, specs.NewOpError("redirect", "invalid request url '%s' scheme", url.Scheme)
+		if cln.CheckRedirect != nil {
+			if err = cln.CheckRedirect(redirect, via); err != nil {
+				if errors.Is(err, ErrUseLastResponse) {
+					return resp, nil
+				}
+				return nil, err
+			}
+			if redirect.Header == nil || redirect.Url == nil || !redirect.Method.IsValid() {
+				panic("plow: invalid redirect after Client.CheckRedirect")
 			}
+		}
 
-			redirectUrl.Scheme = url.Scheme
-			if redirectUrl.Host == "" {
-				redirectUrl.Host = url.Host
-				redirectUrl.Port = url.Port
+		if body := resp.Body(); body != nil {
+			body.Close()
+		}
+
+		via = append(via, redirect)
+		method, url, header, writer = redirect.Method, redirect.Url, redirect.Header, redirectWriter
+	}
+}
+
+// applyHeader adds the cookies of the [Client.Jar] for the url
+// and the [Client.Header] values which are not set in the header.
+func (cln *Client) applyHeader(url *specs.Url, header *specs.Header) {
+	if cln.Jar != nil {
+		cln.mu.RLock()
+		for cookie := range cln.Jar.CookiesForUrl(url) {
+			if !header.HasCookie(cookie.Name) {
+				header.SetCookie(cookie)
 			}
-			url = redirectUrl
+		}
+		cln.mu.RUnlock()
+	}
 
-			continue
+	if cln.Header != nil {
+		cln.mu.RLock()
+		for name := range cln.Header.All() {
+			if !header.Has(name) {
+				for _, value := range cln.Header.Values(name) {
+					header.Add(name, value)
+				}
+			}
+		}
+		for cookie := range cln.Header.Cookies() {
+			if !header.HasCookie(cookie.Name) {
+				header.SetCookie(cookie)
+			}
 		}
+		cln.mu.RUnlock()
+	}
+}
+
+var (
+	bodyHeaders      = []string{"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding"}
+	sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Www-Authenticate"}
+)
 
-		return resp, nil
+// stripSensitiveHeaders removes credentials and cookies from the header.
+func stripSensitiveHeaders(header *specs.Header) {
+	for _, name := range sensitiveHeaders {
+		header.Del(name)
+	}
+	var cookies []string
+	for cookie := range header.Cookies() {
+		cookies = append(cookies, cookie.Name)
+	}
+	for _, name := range cookies {
+		header.DelCookie(name)
+	}
+}
+
+// sameOrigin checks urls have the same scheme, host and port.
+func sameOrigin(a, b *specs.Url) bool {
+	return a.Scheme == b.Scheme &&
+		strings.EqualFold(a.Host, b.Host) &&
+		originPort(a) == originPort(b)
+}
+
+func originPort(url *specs.Url) uint16 {
+	if url.Port != 0 {
+		return url.Port
+	}
+	if url.Scheme == "https" {
+		return 443
 	}
+	return 80
 }
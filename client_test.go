@@ -2,6 +2,7 @@ package plow
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -138,6 +139,149 @@ func TestClient_RedirectInvalidLocation(t *testing.T) {
 	}
 }
 
+func TestClient_RedirectCrossOrigin(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			t.Errorf("sensitive headers forwarded to other origin: %+v", r.Header)
+		}
+		if r.Header.Get("X-Custom") != "keep" {
+			t.Errorf("expected custom header, got %+v", r.Header)
+		}
+		fmt.Fprint(w, "Final Destination")
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Cookie") != "session=secret" {
+			t.Errorf("expected sensitive headers on same origin: %+v", r.Header)
+		}
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/next", http.StatusFound)
+		} else {
+			http.Redirect(w, r, target.URL+"/final", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	req := EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL))
+	req.Header().Set("Authorization", "Bearer token")
+	req.Header().Set("X-Custom", "keep")
+	req.Header().SetCookieValue("session", "secret")
+
+	resp, err := DefaultClient().Make(req)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	checkResponseBody(t, resp, []byte("Final Destination"))
+}
+
+func TestClient_CheckRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/step", http.StatusFound)
+		case "/step":
+			if r.Header.Get("X-Hop") != "1" {
+				t.Errorf("expected rewritten header, got %+v", r.Header)
+			}
+			http.Redirect(w, r, "https://secure.example.com/login", http.StatusMovedPermanently)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+	defer server.Close()
+
+	var hops []string
+	client := DefaultClient()
+	client.CheckRedirect = func(req *Redirect, via []*Redirect) error {
+		hops = append(hops, req.Url.String())
+		if len(via) == 0 || via[0].Response != nil || req.Response == nil {
+			t.Errorf("unexpected redirect chain %+v", via)
+		}
+		if req.Url.Scheme == "https" {
+			return ErrUseLastResponse
+		}
+		req.Header.Set("X-Hop", strconv.Itoa(len(via)))
+		return nil
+	}
+
+	resp, err := client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL)))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	if resp.StatusCode() != specs.StatusCodeMovedPermanently || len(hops) != 2 || hops[1] != "https://secure.example.com/login" {
+		t.Errorf("unexpected last response %d after hops %v", resp.StatusCode(), hops)
+	}
+	if body := resp.Body(); body != nil {
+		body.Close()
+	}
+
+	errStop := errors.New("stop")
+	client.CheckRedirect = func(req *Redirect, via []*Redirect) error {
+		return errStop
+	}
+	if _, err = client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL))); !errors.Is(err, errStop) {
+		t.Errorf("expected stop error, got %v", err)
+	}
+}
+
+func TestClient_RedirectBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/temporary":
+			http.Redirect(w, r, "/final", http.StatusTemporaryRedirect)
+		case "/see-other":
+			http.Redirect(w, r, "/final", http.StatusSeeOther)
+		case "/final":
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), body)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		request  ClientRequest
+		wantCode specs.StatusCode
+		wantBody string
+	}{
+		{
+			name:     "Replay Buffer",
+			request:  TextRequest(specs.HttpMethodPost, specs.MustParseUrl(server.URL+"/temporary"), "", "payload"),
+			wantCode: specs.StatusCodeOK,
+			wantBody: "POST text/plain payload",
+		},
+		{
+			name: "Not Rewindable Stream",
+			request: StreamRequest(specs.HttpMethodPost, specs.MustParseUrl(server.URL+"/temporary"), "",
+				io.MultiReader(strings.NewReader("payload")), 7),
+			wantCode: specs.StatusCodeTemporaryRedirect,
+		},
+		{
+			name:     "See Other",
+			request:  TextRequest(specs.HttpMethodPost, specs.MustParseUrl(server.URL+"/see-other"), "", "payload"),
+			wantCode: specs.StatusCodeOK,
+			wantBody: "GET  ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := DefaultClient().Make(tt.request)
+			if err != nil {
+				t.Fatal("req:", err)
+			}
+			var body []byte
+			if reader := resp.Body(); reader != nil {
+				defer reader.Close()
+				body, _ = io.ReadAll(reader)
+			}
+			if resp.StatusCode() != tt.wantCode || tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("unexpected response %d %q", resp.StatusCode(), body)
+			}
+		})
+	}
+}
+
 // Test all Requests
 
 func TestClient_PostAnyRequest(t *testing.T) {
@@ -15,6 +15,9 @@ const (
 	// DefaultMaxRedirectCount default value for Client.MaxRedirectCount parameter
 	DefaultMaxRedirectCount int = 10
 
+	// DefaultMaxIdleConnsPerHost default value for Transport.MaxIdleConnsPerHost parameter
+	DefaultMaxIdleConnsPerHost int = 2
+
 	// DefaultMaxEncodingSize default value for Server.MaxEncodingSize parameter
 	DefaultMaxEncodingSize int64 = 5 << 20 // 5 mb
 )
@@ -46,6 +49,10 @@ var (
 		Code: specs.StatusCodeLengthRequired,
 		Text: "http: invalid Content-Length header",
 	}
+	responseConflictingContentLength = &server_ops.ErrorResponse{
+		Code: specs.StatusCodeBadRequest,
+		Text: "http: conflicting Content-Length headers",
+	}
 	responseUnsupportedTransferEncoding = &server_ops.ErrorResponse{
 		Code: specs.StatusCodeNotImplemented,
 		Text: "http: unsupported transfer encoding",
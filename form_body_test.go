@@ -33,8 +33,8 @@ func TestPostFormRequest(t *testing.T) {
 
 	url := specs.MustParseUrl(server.URL)
 	req := FormRequest(specs.HttpMethodPut, url, specs.Query{
-		"hello": "world",
-		"key":   "value",
+		"hello": {"world"},
+		"key":   {"value"},
 	})
 
 	resp, err := DefaultClient().Make(req)
@@ -53,9 +53,10 @@ func TestReadForm(t *testing.T) {
 		}
 
 		expectedForm := specs.Query{
-			"hello": "world",
-			"host":  "port",
-			"key":   "value",
+			"hello": {"world"},
+			"host":  {"port"},
+			"key":   {"value"},
+			"tag":   {"a", "b"},
 		}
 		if !reflect.DeepEqual(form, expectedForm) {
 			t.Errorf("read invalid form = %v, want %v", form, expectedForm)
@@ -78,6 +79,8 @@ func TestReadForm(t *testing.T) {
 	form.Set("hello", "world")
 	form.Set("host", "port")
 	form.Set("key", "value")
+	form.Add("tag", "a")
+	form.Add("tag", "b")
 	resp, err := http.PostForm(url, form)
 	if err != nil {
 		t.Fatal("req:", err)
@@ -84,6 +84,9 @@ type ClientResponse interface {
 // to send bytes of the HTTP server response body or client request body.
 type BodyWriter interface {
 	// WriteBody function allows you to send a content body to a specific node.
+	//
+	// The writer of the [Server] response body implements [Flusher]
+	// when written data may be buffered before it is sent to the client.
 	WriteBody(io.Writer) error
 
 	// ContentLength specifies size of the body
@@ -91,6 +94,21 @@ type BodyWriter interface {
 	ContentLength() int64
 }
 
+// Rewinder is an interface implemented by the [BodyWriter] of the [ClientRequest]
+// which can be written more than once, such as buffered or seekable bodies.
+//
+// Rewind resets the body to the beginning before it is written again
+// by the [Client] on retries and redirects.
+type Rewinder interface {
+	Rewind() error
+}
+
+// Flusher is an interface implemented by writers which buffer data,
+// Flush sends any buffered data to the underlying node.
+type Flusher interface {
+	Flush() error
+}
+
 // MarshallResponse is an interface that combines [Response] and [BodyWriter] capabilities
 // with the ability to provide an instance of the underlying response type.
 type MarshallResponse interface {
@@ -0,0 +1,50 @@
+package plow
+
+import (
+	"context"
+
+	"github.com/oesand/plow/specs"
+)
+
+// ClientNextFunc represents a function that continues the interceptor chain execution.
+// It sends the request to the next interceptor or the [RoundTripper]
+// and returns the received response or error.
+type ClientNextFunc func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error)
+
+// Interceptor is an interface that can intercept and process outbound HTTP requests
+// of the [Client] before they reach the [RoundTripper]. It can modify the request,
+// inspect the response or error, execute additional logic, or short-circuit the request.
+type Interceptor interface {
+	Intercept(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter, next ClientNextFunc) (ClientResponse, error)
+}
+
+// InterceptorFunc shorthand implementation for [Interceptor]
+type InterceptorFunc func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter, next ClientNextFunc) (ClientResponse, error)
+
+// Intercept triggers top level function [InterceptorFunc]
+func (f InterceptorFunc) Intercept(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter, next ClientNextFunc) (ClientResponse, error) {
+	return f(ctx, method, url, header, writer, next)
+}
+
+// Intercept returns a [RoundTripper] that passes each request
+// through the interceptors before the transport.
+//
+// Interceptors are executed in the order they are provided,
+// the first interceptor is the outermost.
+func Intercept(transport RoundTripper, interceptors ...Interceptor) RoundTripper {
+	if transport == nil {
+		panic("plow: nil transport")
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		if interceptor == nil {
+			panic("plow: nil Interceptor")
+		}
+
+		next := transport.RoundTrip
+		transport = RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+			return interceptor.Intercept(ctx, method, url, header, writer, next)
+		})
+	}
+	return transport
+}
@@ -0,0 +1,70 @@
+package plow
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oesand/plow/specs"
+)
+
+func TestClient_Interceptors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Order", r.Header.Get("X-Order"))
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	var calls []string
+	trace := func(name string) Interceptor {
+		return InterceptorFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter, next ClientNextFunc) (ClientResponse, error) {
+			calls = append(calls, "before "+name)
+			header.Set("X-Order", strings.TrimPrefix(header.Get("X-Order")+","+name, ","))
+
+			resp, err := next(ctx, method, url, header, writer)
+
+			calls = append(calls, "after "+name)
+			if err == nil && resp.Header().Get("X-Order") != "first,second" {
+				t.Errorf("unexpected response header %+v", resp.Header())
+			}
+			return resp, err
+		})
+	}
+
+	client := DefaultClient()
+	client.Interceptors = []Interceptor{trace("first"), trace("second")}
+
+	resp, err := client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponseBody(t, resp, []byte("OK"))
+
+	if strings.Join(calls, ";") != "before first;before second;after second;after first" {
+		t.Errorf("unexpected interceptors order %v", calls)
+	}
+}
+
+func TestIntercept_ShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+	transport := Intercept(
+		RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+			t.Error("transport must not be called")
+			return nil, nil
+		}),
+		InterceptorFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter, next ClientNextFunc) (ClientResponse, error) {
+			if url.Host == "blocked.org" {
+				return nil, errDenied
+			}
+			return next(ctx, method, url, header, writer)
+		}),
+	)
+
+	_, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet, specs.MustParseUrl("http://blocked.org"), specs.NewHeader(), nil)
+	if !errors.Is(err, errDenied) {
+		t.Errorf("expected denied error, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package client_ops
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/oesand/plow/internal/encoding"
+)
+
+// NewBodyReader creates reader of message body framed
+// by chunked transfer encoding or Content-Length.
+func NewBodyReader(reader *bufio.Reader, isChunked bool, contentLength int64) *BodyReader {
+	br := &BodyReader{
+		remaining: contentLength,
+		chunked:   isChunked,
+	}
+	if isChunked {
+		br.reader = encoding.NewChunkedReader(reader)
+	} else {
+		br.reader = reader
+	}
+	return br
+}
+
+// BodyReader reads message body without going beyond its bounds
+// and tracks whether the body was fully consumed.
+type BodyReader struct {
+	reader    io.Reader
+	remaining int64
+	chunked   bool
+	drained   bool
+}
+
+func (br *BodyReader) Read(p []byte) (int, error) {
+	if br.drained {
+		return 0, io.EOF
+	}
+
+	if br.chunked {
+		n, err := br.reader.Read(p)
+		if err == io.EOF {
+			br.drained = true
+		}
+		return n, err
+	}
+
+	if br.remaining <= 0 {
+		br.drained = true
+		return 0, io.EOF
+	}
+	if int64(len(p)) > br.remaining {
+		p = p[:br.remaining]
+	}
+
+	n, err := br.reader.Read(p)
+	br.remaining -= int64(n)
+	if br.remaining <= 0 {
+		br.drained = true
+		if err == nil {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// Drained reports whether the body was read to the end.
+func (br *BodyReader) Drained() bool {
+	return br.drained || (!br.chunked && br.remaining <= 0)
+}
+
+// Drain discards at most limit bytes of unread body.
+//
+// Returns true if the body was read to the end.
+func (br *BodyReader) Drain(limit int64) bool {
+	if br.Drained() {
+		return true
+	}
+	_, err := io.CopyN(io.Discard, br, limit+1)
+	return err == io.EOF && br.Drained()
+}
@@ -0,0 +1,196 @@
+package client_ops
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow/internal/stream"
+)
+
+// ConnKey identifies connections which can be shared between requests.
+// Proxy contains the full proxy url or empty string for direct connections.
+type ConnKey struct {
+	Scheme string
+	Host   string
+	Port   uint16
+	Proxy  string
+}
+
+// PersistConn is a connection with its buffered reader
+// which can be kept in ConnPool between requests.
+type PersistConn struct {
+	Key    ConnKey
+	Conn   net.Conn
+	Reader *bufio.Reader
+
+	idleAt    time.Time
+	idleTimer *time.Timer
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Close closes underlying connection and release buffered reader,
+// subsequent calls return the result of the first one.
+func (pc *PersistConn) Close() error {
+	pc.closeOnce.Do(func() {
+		if pc.Reader != nil {
+			stream.DefaultBufioReaderPool.Put(pc.Reader)
+			pc.Reader = nil
+		}
+		pc.closeErr = pc.Conn.Close()
+	})
+	return pc.closeErr
+}
+
+// ConnPool stores idle keep-alive connections grouped by ConnKey.
+//
+// The zero value is ready to use.
+type ConnPool struct {
+	idle  map[ConnKey][]*PersistConn
+	count int
+
+	mu sync.Mutex
+}
+
+// Get returns the most recently used idle connection for the key
+// or nil if there is none.
+func (pool *ConnPool) Get(key ConnKey) *PersistConn {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	conns := pool.idle[key]
+	for len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		pool.count--
+
+		if pc.idleTimer != nil && !pc.idleTimer.Stop() {
+			// Idle timeout already fired and will close connection
+			continue
+		}
+		pc.idleTimer = nil
+
+		pool.setIdle(key, conns)
+		return pc
+	}
+
+	pool.setIdle(key, conns)
+	return nil
+}
+
+// Put returns connection to the pool for reuse.
+//
+// The maxIdle limits the count of idle connections in total and maxIdlePerHost for one key,
+// the oldest idle connection is evicted when maxIdle is reached.
+// Zero or negative maxIdle means no limit.
+// The idleTimeout specifies how long connection can stay idle, zero means no timeout.
+//
+// Returns false if connection was not pooled and must be closed by the caller.
+func (pool *ConnPool) Put(pc *PersistConn, maxIdle, maxIdlePerHost int, idleTimeout time.Duration) bool {
+	if maxIdlePerHost <= 0 {
+		return false
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	conns := pool.idle[pc.Key]
+	if len(conns) >= maxIdlePerHost {
+		return false
+	}
+
+	if maxIdle > 0 && pool.count >= maxIdle {
+		pool.evictOldest()
+		conns = pool.idle[pc.Key]
+	}
+
+	if pool.idle == nil {
+		pool.idle = map[ConnKey][]*PersistConn{}
+	}
+
+	pc.idleAt = time.Now()
+	if idleTimeout > 0 {
+		pc.idleTimer = time.AfterFunc(idleTimeout, func() {
+			pool.remove(pc)
+			pc.Close()
+		})
+	}
+
+	pool.idle[pc.Key] = append(conns, pc)
+	pool.count++
+	return true
+}
+
+// IdleCount returns count of idle connections in the pool.
+func (pool *ConnPool) IdleCount() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.count
+}
+
+// CloseIdle closes all idle connections in the pool.
+func (pool *ConnPool) CloseIdle() {
+	pool.mu.Lock()
+	idle := pool.idle
+	pool.idle = nil
+	pool.count = 0
+	pool.mu.Unlock()
+
+	for _, conns := range idle {
+		for _, pc := range conns {
+			if pc.idleTimer != nil && !pc.idleTimer.Stop() {
+				// Already closing by idle timeout
+				continue
+			}
+			pc.Close()
+		}
+	}
+}
+
+func (pool *ConnPool) remove(pc *PersistConn) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	conns := pool.idle[pc.Key]
+	for i, other := range conns {
+		if other == pc {
+			conns = append(conns[:i], conns[i+1:]...)
+			pool.count--
+			break
+		}
+	}
+	pool.setIdle(pc.Key, conns)
+}
+
+func (pool *ConnPool) evictOldest() {
+	var oldest *PersistConn
+	for _, conns := range pool.idle {
+		// Connections ordered by idle time, first one is the oldest for the key
+		if len(conns) > 0 && (oldest == nil || conns[0].idleAt.Before(oldest.idleAt)) {
+			oldest = conns[0]
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	pool.setIdle(oldest.Key, pool.idle[oldest.Key][1:])
+	pool.count--
+
+	if oldest.idleTimer != nil && !oldest.idleTimer.Stop() {
+		// Already closing by idle timeout
+		return
+	}
+	go oldest.Close()
+}
+
+func (pool *ConnPool) setIdle(key ConnKey, conns []*PersistConn) {
+	if len(conns) == 0 {
+		delete(pool.idle, key)
+	} else {
+		pool.idle[key] = conns
+	}
+}
@@ -0,0 +1,173 @@
+package client_ops
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPersistConn(key ConnKey) (*PersistConn, net.Conn) {
+	client, server := net.Pipe()
+	return &PersistConn{Key: key, Conn: client}, server
+}
+
+func TestConnPool_GetPut(t *testing.T) {
+	var pool ConnPool
+	key := ConnKey{Scheme: "http", Host: "example.com", Port: 80}
+
+	if pc := pool.Get(key); pc != nil {
+		t.Fatal("empty pool returns connection")
+	}
+
+	first, _ := newTestPersistConn(key)
+	second, _ := newTestPersistConn(key)
+	if !pool.Put(first, 0, 2, 0) || !pool.Put(second, 0, 2, 0) {
+		t.Fatal("connection not pooled")
+	}
+
+	if pool.IdleCount() != 2 {
+		t.Errorf("expected 2 idle connections, got %d", pool.IdleCount())
+	}
+
+	if pc := pool.Get(key); pc != second {
+		t.Error("expected most recently used connection")
+	}
+	if pc := pool.Get(key); pc != first {
+		t.Error("expected first connection")
+	}
+	if pc := pool.Get(key); pc != nil {
+		t.Error("expected empty pool")
+	}
+}
+
+func TestConnPool_KeyIsolation(t *testing.T) {
+	var pool ConnPool
+	direct := ConnKey{Scheme: "https", Host: "example.com", Port: 443}
+	proxied := ConnKey{Scheme: "https", Host: "example.com", Port: 443, Proxy: "socks5://127.0.0.1:1080"}
+
+	pc, _ := newTestPersistConn(direct)
+	pool.Put(pc, 0, 2, 0)
+
+	if got := pool.Get(proxied); got != nil {
+		t.Error("connection reused through another proxy")
+	}
+	if got := pool.Get(direct); got != pc {
+		t.Error("expected pooled connection")
+	}
+}
+
+func TestConnPool_PerHostLimit(t *testing.T) {
+	var pool ConnPool
+	key := ConnKey{Scheme: "http", Host: "example.com", Port: 80}
+
+	first, _ := newTestPersistConn(key)
+	second, _ := newTestPersistConn(key)
+	if !pool.Put(first, 0, 1, 0) {
+		t.Fatal("connection not pooled")
+	}
+	if pool.Put(second, 0, 1, 0) {
+		t.Error("per host limit exceeded")
+	}
+
+	third, _ := newTestPersistConn(key)
+	if pool.Put(third, 0, -1, 0) {
+		t.Error("negative per host limit must disable pooling")
+	}
+}
+
+func TestConnPool_TotalLimitEvictsOldest(t *testing.T) {
+	var pool ConnPool
+	oldKey := ConnKey{Scheme: "http", Host: "old.com", Port: 80}
+	newKey := ConnKey{Scheme: "http", Host: "new.com", Port: 80}
+
+	old, oldServer := newTestPersistConn(oldKey)
+	pool.Put(old, 1, 2, 0)
+
+	time.Sleep(time.Millisecond)
+
+	fresh, _ := newTestPersistConn(newKey)
+	if !pool.Put(fresh, 1, 2, 0) {
+		t.Fatal("connection not pooled")
+	}
+
+	if pool.IdleCount() != 1 {
+		t.Errorf("expected 1 idle connection, got %d", pool.IdleCount())
+	}
+	if pc := pool.Get(oldKey); pc != nil {
+		t.Error("oldest connection not evicted")
+	}
+
+	oldServer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := oldServer.Read(make([]byte, 1)); err == nil {
+		t.Error("evicted connection not closed")
+	}
+}
+
+func TestConnPool_IdleTimeout(t *testing.T) {
+	var pool ConnPool
+	key := ConnKey{Scheme: "http", Host: "example.com", Port: 80}
+
+	pc, server := newTestPersistConn(key)
+	pool.Put(pc, 0, 2, 10*time.Millisecond)
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Error("idle connection not closed")
+	}
+
+	if got := pool.Get(key); got != nil {
+		t.Error("expired connection returned")
+	}
+	if pool.IdleCount() != 0 {
+		t.Errorf("expected empty pool, got %d", pool.IdleCount())
+	}
+}
+
+func TestConnPool_CloseIdle(t *testing.T) {
+	var pool ConnPool
+	key := ConnKey{Scheme: "http", Host: "example.com", Port: 80}
+
+	pc, server := newTestPersistConn(key)
+	pool.Put(pc, 0, 2, time.Minute)
+	pool.CloseIdle()
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Error("idle connection not closed")
+	}
+	if got := pool.Get(key); got != nil {
+		t.Error("closed connection returned")
+	}
+}
+
+func TestPersistConn_CloseOnce(t *testing.T) {
+	var pool ConnPool
+	key := ConnKey{Scheme: "http", Host: "example.com", Port: 80}
+
+	for range 100 {
+		pc, _ := newTestPersistConn(key)
+		pc.Reader = bufio.NewReader(pc.Conn)
+		pool.Put(pc, 0, 2, time.Microsecond)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			pool.CloseIdle()
+		}()
+		go func() {
+			defer wg.Done()
+			pc.Close()
+		}()
+		wg.Wait()
+
+		if err := pc.Close(); err != nil {
+			t.Fatalf("expected result of the first close, got %v", err)
+		}
+		if pc.Reader != nil {
+			t.Fatal("buffered reader not released")
+		}
+	}
+}
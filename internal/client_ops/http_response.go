@@ -13,12 +13,18 @@ func NewHttpClientResponse(status specs.StatusCode, header *specs.Header) *HttpC
 }
 
 type HttpClientResponse struct {
+	protoMajor, protoMinor uint16
+
 	status specs.StatusCode
 	header *specs.Header
 
 	Reader io.ReadCloser
 }
 
+func (resp *HttpClientResponse) ProtoVersion() (major, minor uint16) {
+	return resp.protoMajor, resp.protoMinor
+}
+
 func (resp *HttpClientResponse) StatusCode() specs.StatusCode {
 	return resp.status
 }
@@ -30,3 +36,7 @@ func (resp *HttpClientResponse) Header() *specs.Header {
 func (resp *HttpClientResponse) Body() io.ReadCloser {
 	return resp.Reader
 }
+
+func (resp *HttpClientResponse) SetProtoVersion(major, minor uint16) {
+	resp.protoMajor, resp.protoMinor = major, minor
+}
@@ -32,5 +32,6 @@ func ReadResponse(ctx context.Context, reader *bufio.Reader, lineLimit int64, to
 	}
 
 	resp := NewHttpClientResponse(status, header)
+	resp.protoMajor, resp.protoMinor = protoMajor, protoMinor
 	return resp, nil
 }
@@ -60,6 +60,24 @@ func TestWriteRequestHead(t *testing.T) {
 				"Content-Type: application/json",
 			}, "\r\n") + "\r\n\r\n",
 		},
+		{
+			name:   "Multi-valued headers",
+			method: specs.HttpMethodGet,
+			url:    specs.MustParseUrl("/multi"),
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Add("Accept", "text/html")
+				header.Add("Via", "1.1 first")
+				header.Add("Accept", "application/json")
+				header.Add("Via", "1.1 second")
+			}),
+			expected: strings.Join([]string{
+				"GET /multi HTTP/1.1",
+				"Accept: text/html",
+				"Accept: application/json",
+				"Via: 1.1 first",
+				"Via: 1.1 second",
+			}, "\r\n") + "\r\n\r\n",
+		},
 		{
 			name:   "Empty URL path",
 			method: specs.HttpMethodGet,
@@ -18,6 +18,10 @@ func NewReader(isChunked bool, contentEncoding string, bufio *bufio.Reader) (io.
 		reader = bufio
 	}
 
+	return NewDecodingReader(contentEncoding, reader)
+}
+
+func NewDecodingReader(contentEncoding string, reader io.Reader) (io.ReadCloser, error) {
 	switch contentEncoding {
 	case "":
 		return io.NopCloser(reader), nil
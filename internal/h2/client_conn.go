@@ -0,0 +1,742 @@
+package h2
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Maximum stream identifier which can be opened by the client
+const maxClientStreamID uint32 = 1<<31 - 1
+
+// Request is a client request sent as HTTP/2 stream.
+type Request struct {
+	Method    specs.HttpMethod
+	Scheme    string
+	Authority string
+	Path      string
+	Header    *specs.Header
+
+	// Body writes request body, nil if request has no body.
+	Body func(w io.Writer) error
+
+	// Timeout limits the entire exchange including reading of response body.
+	//
+	// If zero there is no timeout
+	Timeout time.Duration
+}
+
+// Response is a client response received from HTTP/2 stream.
+type Response struct {
+	StatusCode specs.StatusCode
+	Header     *specs.Header
+
+	// Body is nil if the response has no body.
+	Body io.ReadCloser
+}
+
+// ClientConn is a single HTTP/2 client connection
+// which multiplexes concurrent requests as streams.
+type ClientConn struct {
+	*conn
+
+	idleTimeout time.Duration
+	readDone    chan struct{}
+
+	// guarded by conn.mu
+	streams       map[uint32]*clientStream
+	pending       int
+	nextStreamID  uint32
+	maxConcurrent uint32
+	closing       bool
+	goAway        *GoAwayError
+	idleTimer     *time.Timer
+}
+
+// NewClientConn writes the client preface and settings to the connection
+// and starts reading frames of the server.
+//
+// Reader is an optional reader of the connection with already buffered data.
+func NewClientConn(nc net.Conn, reader io.Reader, maxHeaderListSize uint32, idleTimeout time.Duration) (*ClientConn, error) {
+	cc := &ClientConn{
+		conn:          newConn(nc, reader, maxHeaderListSize),
+		idleTimeout:   idleTimeout,
+		readDone:      make(chan struct{}),
+		streams:       make(map[uint32]*clientStream),
+		nextStreamID:  1,
+		maxConcurrent: defaultMaxConcurrentStreams,
+	}
+
+	err := cc.write(func(*http2.Framer) error {
+		_, err := cc.bw.WriteString(ClientPreface)
+		return err
+	})
+	if err == nil {
+		err = cc.writeInitialFrames(cc.localSettings(
+			http2.Setting{ID: http2.SettingEnablePush, Val: 0},
+		))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if idleTimeout > 0 {
+		cc.idleTimer = time.AfterFunc(idleTimeout, cc.closeIfIdle)
+	}
+
+	go cc.readLoop()
+	return cc, nil
+}
+
+// CanTakeNewRequest reports whether the connection can take a new request.
+func (cc *ClientConn) CanTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.canOpenStreamLocked() == nil
+}
+
+// IsIdle reports whether the connection has no active streams.
+func (cc *ClientConn) IsIdle() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.streams) == 0 && cc.pending == 0
+}
+
+// Close sends GOAWAY frame and closes the connection,
+// active streams are aborted.
+func (cc *ClientConn) Close() error {
+	cc.mu.Lock()
+	cc.closing = true
+	cc.mu.Unlock()
+
+	cc.writeGoAway(0, http2.ErrCodeNo, nil)
+	err := cc.nc.Close()
+	<-cc.readDone
+	return err
+}
+
+// CloseIfIdle closes the connection if it has no active streams.
+func (cc *ClientConn) CloseIfIdle() bool {
+	cc.mu.Lock()
+	if len(cc.streams) > 0 || cc.pending > 0 {
+		cc.mu.Unlock()
+		return false
+	}
+	cc.closing = true
+	cc.mu.Unlock()
+
+	cc.Close()
+	return true
+}
+
+func (cc *ClientConn) closeIfIdle() {
+	cc.CloseIfIdle()
+}
+
+func (cc *ClientConn) canOpenStreamLocked() error {
+	if cc.err != nil {
+		return retryable(cc.err)
+	}
+	if cc.goAway != nil {
+		return retryable(cc.goAway)
+	}
+	if cc.closing || cc.nextStreamID >= maxClientStreamID {
+		return retryable(ErrConnClosed)
+	}
+	return nil
+}
+
+// RoundTrip sends the request as a new stream and waits for response headers.
+//
+// If the error wraps [ErrRetryable] the request was not processed by the server.
+func (cc *ClientConn) RoundTrip(ctx context.Context, req *Request) (*Response, error) {
+	cs := &clientStream{
+		cc:        cc,
+		respReady: make(chan struct{}),
+		sendEnded: req.Body == nil,
+	}
+
+	if err := cc.openStream(ctx, cs, req); err != nil {
+		return nil, err
+	}
+
+	stopCtx := context.AfterFunc(ctx, func() {
+		cc.resetStream(cs, http2.ErrCodeCancel, ctx.Err())
+	})
+	var timer *time.Timer
+	if req.Timeout > 0 {
+		timer = time.AfterFunc(req.Timeout, func() {
+			cc.resetStream(cs, http2.ErrCodeCancel, specs.ErrTimeout)
+		})
+	}
+	stop := func() {
+		stopCtx()
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	cc.mu.Lock()
+	removed := cs.removed
+	if !removed {
+		cs.stop = stop
+	}
+	cc.mu.Unlock()
+	if removed {
+		stop()
+	}
+
+	if req.Body != nil {
+		go cs.writeBody(req.Body)
+	}
+
+	<-cs.respReady
+	if cs.respErr != nil {
+		return nil, cs.respErr
+	}
+
+	resp := &Response{
+		StatusCode: cs.status,
+		Header:     cs.header,
+	}
+	if cs.body != nil {
+		resp.Body = &clientBody{cs: cs}
+	}
+	return resp, nil
+}
+
+// openStream waits for available concurrency slot, allocates stream identifier
+// and writes request headers.
+func (cc *ClientConn) openStream(ctx context.Context, cs *clientStream, req *Request) error {
+	stopWait := context.AfterFunc(ctx, func() {
+		cc.mu.Lock()
+		cc.cond.Broadcast()
+		cc.mu.Unlock()
+	})
+
+	cc.mu.Lock()
+	for {
+		if err := cc.canOpenStreamLocked(); err != nil {
+			cc.mu.Unlock()
+			stopWait()
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			cc.mu.Unlock()
+			stopWait()
+			return err
+		}
+		if uint32(len(cc.streams)+cc.pending) < cc.maxConcurrent {
+			break
+		}
+		cc.cond.Wait()
+	}
+	cc.pending++
+	cc.mu.Unlock()
+	stopWait()
+
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+
+	cc.mu.Lock()
+	cc.pending--
+	if err := cc.canOpenStreamLocked(); err != nil {
+		cc.mu.Unlock()
+		return err
+	}
+	cs.id = cc.nextStreamID
+	cc.nextStreamID += 2
+	cs.flow = flow{
+		sendWindow: int64(cc.peerInitialWindow),
+		recvWindow: int64(initialStreamWindowSize),
+	}
+	cc.streams[cs.id] = cs
+	if cc.idleTimer != nil {
+		cc.idleTimer.Stop()
+	}
+	cc.mu.Unlock()
+
+	err := cc.writeHeadersLocked(cs.id, req.Body == nil, func(enc *hpack.Encoder) error {
+		enc.WriteField(hpack.HeaderField{Name: ":method", Value: string(req.Method)})
+		if req.Method != specs.HttpMethodConnect {
+			enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: req.Scheme})
+			enc.WriteField(hpack.HeaderField{Name: ":path", Value: req.Path})
+		}
+		enc.WriteField(hpack.HeaderField{Name: ":authority", Value: req.Authority})
+		return encodeHeader(enc, req.Header, false)
+	})
+	if err != nil {
+		cc.mu.Lock()
+		cc.removeStreamLocked(cs)
+		cc.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// resetStream aborts the stream with err and sends RST_STREAM frame to the server.
+func (cc *ClientConn) resetStream(cs *clientStream, code http2.ErrCode, err error) {
+	cc.mu.Lock()
+	if cs.removed {
+		cc.mu.Unlock()
+		return
+	}
+	discarded := cc.abortStreamLocked(cs, err)
+	cc.mu.Unlock()
+
+	cc.creditRecvWindow(0, nil, discarded)
+	cc.writeRSTStream(cs.id, code)
+}
+
+// abortStreamLocked fails waiting of response, reading of unfinished body
+// and writing of request body with err.
+//
+// Returns count of discarded received bytes.
+func (cc *ClientConn) abortStreamLocked(cs *clientStream, err error) int {
+	if !cs.gotResponse {
+		cs.gotResponse = true
+		cs.respErr = err
+		close(cs.respReady)
+	}
+
+	var discarded int
+	if cs.body != nil && !cs.recvEnded {
+		discarded = cs.body.BreakWithError(err)
+	}
+	if cs.sendErr == nil {
+		cs.sendErr = err
+	}
+	cc.removeStreamLocked(cs)
+	return discarded
+}
+
+func (cc *ClientConn) removeStreamLocked(cs *clientStream) {
+	if cs.removed {
+		return
+	}
+	cs.removed = true
+	delete(cc.streams, cs.id)
+	if cs.stop != nil {
+		cs.stop()
+	}
+	cc.cond.Broadcast()
+
+	if len(cc.streams) == 0 {
+		if cc.closing {
+			go cc.nc.Close()
+		} else if cc.idleTimer != nil {
+			cc.idleTimer.Reset(cc.idleTimeout)
+		}
+	}
+}
+
+func (cc *ClientConn) endRecvLocked(cs *clientStream) {
+	cs.recvEnded = true
+	if cs.body != nil {
+		cs.body.CloseWithError(io.EOF)
+	}
+	if cs.sendEnded {
+		cc.removeStreamLocked(cs)
+	}
+}
+
+func (cc *ClientConn) readLoop() {
+	var err error
+	for {
+		var f http2.Frame
+		f, err = cc.framer.ReadFrame()
+		if err != nil {
+			var se http2.StreamError
+			if errors.As(err, &se) {
+				cc.mu.Lock()
+				cs := cc.streams[se.StreamID]
+				cc.mu.Unlock()
+				if cs != nil {
+					cc.resetStream(cs, se.Code, &StreamError{StreamID: se.StreamID, Code: se.Code})
+				} else {
+					cc.writeRSTStream(se.StreamID, se.Code)
+				}
+				continue
+			}
+			var ce http2.ConnectionError
+			if errors.As(err, &ce) {
+				err = &ConnectionError{Code: http2.ErrCode(ce)}
+			}
+			break
+		}
+
+		switch f := f.(type) {
+		case *http2.MetaHeadersFrame:
+			err = cc.handleHeaders(f)
+		case *http2.DataFrame:
+			err = cc.handleData(f)
+		case *http2.RSTStreamFrame:
+			err = cc.handleRSTStream(f)
+		case *http2.SettingsFrame:
+			err = cc.applySettings(f, cc.streamFlows, cc.handleSetting)
+		case *http2.WindowUpdateFrame:
+			err = cc.handleWindowUpdate(f)
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				data := f.Data
+				err = cc.write(func(fr *http2.Framer) error {
+					return fr.WritePing(true, data)
+				})
+			}
+		case *http2.GoAwayFrame:
+			cc.handleGoAway(f)
+		case *http2.PushPromiseFrame:
+			err = &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "push is disabled"}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	var ce *ConnectionError
+	if errors.As(err, &ce) {
+		cc.writeGoAway(0, ce.Code, nil)
+	}
+	cc.fail(err)
+
+	cc.mu.Lock()
+	cc.closing = true
+	streamErr := error(ErrConnClosed)
+	if cc.goAway != nil {
+		streamErr = cc.goAway
+	} else if ce != nil {
+		streamErr = ce
+	}
+	for _, cs := range cc.streams {
+		cc.abortStreamLocked(cs, streamErr)
+	}
+	if cc.idleTimer != nil {
+		cc.idleTimer.Stop()
+	}
+	cc.mu.Unlock()
+
+	close(cc.readDone)
+}
+
+// streamFlows iterates over flow control states of active streams, called with mu held.
+func (cc *ClientConn) streamFlows(yield func(*flow) bool) {
+	for _, cs := range cc.streams {
+		if !yield(&cs.flow) {
+			return
+		}
+	}
+}
+
+func (cc *ClientConn) handleSetting(s http2.Setting) error {
+	if s.ID == http2.SettingMaxConcurrentStreams {
+		cc.mu.Lock()
+		cc.maxConcurrent = s.Val
+		cc.cond.Broadcast()
+		cc.mu.Unlock()
+	}
+	return nil
+}
+
+// streamByID returns an active stream or an error
+// when the identifier was never opened.
+func (cc *ClientConn) streamByID(id uint32) (*clientStream, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cs := cc.streams[id]
+	if cs == nil && (id >= cc.nextStreamID || id%2 == 0) {
+		return nil, &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "frame on idle stream"}
+	}
+	return cs, nil
+}
+
+func (cc *ClientConn) handleHeaders(f *http2.MetaHeadersFrame) error {
+	cs, err := cc.streamByID(f.StreamID)
+	if cs == nil {
+		return err
+	}
+
+	if f.Truncated {
+		cc.resetStream(cs, http2.ErrCodeProtocol, ErrHeaderTooLong)
+		return nil
+	}
+
+	cc.mu.Lock()
+	if cs.gotResponse {
+		// Trailers are not exposed
+		if !f.StreamEnded() {
+			cc.mu.Unlock()
+			cc.resetStream(cs, http2.ErrCodeProtocol, &StreamError{StreamID: cs.id, Code: http2.ErrCodeProtocol})
+			return nil
+		}
+		cc.endRecvLocked(cs)
+		cc.mu.Unlock()
+		return nil
+	}
+
+	status, err := strconv.Atoi(f.PseudoValue("status"))
+	if err != nil || status < 100 || status > 999 ||
+		status < 200 && (status == 101 || f.StreamEnded()) {
+		cc.mu.Unlock()
+		cc.resetStream(cs, http2.ErrCodeProtocol, &StreamError{StreamID: cs.id, Code: http2.ErrCodeProtocol})
+		return nil
+	}
+	if status < 200 {
+		// Skip informational responses, such as 100 Continue
+		cc.mu.Unlock()
+		return nil
+	}
+
+	cs.status = specs.StatusCode(status)
+	cs.header = decodeHeader(f.RegularFields())
+	if !f.StreamEnded() {
+		cs.body = newPipe()
+	}
+	cs.gotResponse = true
+	close(cs.respReady)
+	if f.StreamEnded() {
+		cc.endRecvLocked(cs)
+	}
+	cc.mu.Unlock()
+	return nil
+}
+
+func (cc *ClientConn) handleData(f *http2.DataFrame) error {
+	cs, err := cc.streamByID(f.StreamID)
+	if err != nil {
+		return err
+	}
+
+	size := int(f.Length)
+	var body *pipe
+	var fl *flow
+	if cs != nil {
+		cc.mu.Lock()
+		if !cs.recvEnded && !cs.removed {
+			body = cs.body
+			fl = &cs.flow
+		}
+		cc.mu.Unlock()
+	}
+	if body == nil {
+		fl = nil
+	}
+
+	connViolated, streamViolated := cc.consumeRecvWindow(fl, size)
+	if connViolated {
+		return &ConnectionError{Code: http2.ErrCodeFlowControl, Reason: "connection window exceeded"}
+	}
+
+	if body == nil {
+		// Stream is closed or response headers are not received yet
+		cc.creditRecvWindow(0, nil, size)
+		if cs != nil {
+			cc.resetStream(cs, http2.ErrCodeStreamClosed, &StreamError{StreamID: cs.id, Code: http2.ErrCodeStreamClosed})
+		}
+		return nil
+	}
+	if streamViolated {
+		cc.creditRecvWindow(0, nil, size)
+		cc.resetStream(cs, http2.ErrCodeFlowControl, &StreamError{StreamID: cs.id, Code: http2.ErrCodeFlowControl})
+		return nil
+	}
+
+	data := f.Data()
+	if padding := size - len(data); padding > 0 {
+		cc.creditRecvWindow(cs.id, fl, padding)
+	}
+	if len(data) > 0 {
+		if _, err := body.Write(data); err != nil {
+			// Body is already closed by the reader
+			cc.creditRecvWindow(0, nil, len(data))
+		}
+	}
+
+	if f.StreamEnded() {
+		cc.mu.Lock()
+		cc.endRecvLocked(cs)
+		cc.mu.Unlock()
+	}
+	return nil
+}
+
+func (cc *ClientConn) handleRSTStream(f *http2.RSTStreamFrame) error {
+	cs, err := cc.streamByID(f.StreamID)
+	if cs == nil {
+		return err
+	}
+
+	err = &StreamError{StreamID: f.StreamID, Code: f.ErrCode}
+	if f.ErrCode == http2.ErrCodeRefusedStream {
+		err = retryable(err)
+	}
+
+	cc.mu.Lock()
+	discarded := cc.abortStreamLocked(cs, err)
+	cc.mu.Unlock()
+
+	cc.creditRecvWindow(0, nil, discarded)
+	return nil
+}
+
+func (cc *ClientConn) handleWindowUpdate(f *http2.WindowUpdateFrame) error {
+	if f.StreamID == 0 {
+		if !cc.addSendWindow(nil, f.Increment) {
+			return &ConnectionError{Code: http2.ErrCodeFlowControl, Reason: "window overflow"}
+		}
+		return nil
+	}
+
+	cs, err := cc.streamByID(f.StreamID)
+	if cs == nil {
+		return err
+	}
+	if !cc.addSendWindow(&cs.flow, f.Increment) {
+		cc.resetStream(cs, http2.ErrCodeFlowControl, &StreamError{StreamID: cs.id, Code: http2.ErrCodeFlowControl})
+	}
+	return nil
+}
+
+func (cc *ClientConn) handleGoAway(f *http2.GoAwayFrame) {
+	goAway := &GoAwayError{
+		LastStreamID: f.LastStreamID,
+		Code:         f.ErrCode,
+		DebugData:    string(f.DebugData()),
+	}
+
+	var discarded int
+	cc.mu.Lock()
+	cc.closing = true
+	cc.goAway = goAway
+	for id, cs := range cc.streams {
+		if id > f.LastStreamID {
+			// Streams above the last one were not processed by the server
+			discarded += cc.abortStreamLocked(cs, retryable(goAway))
+		}
+	}
+	if len(cc.streams) == 0 && cc.pending == 0 {
+		go cc.nc.Close()
+	}
+	cc.mu.Unlock()
+
+	cc.creditRecvWindow(0, nil, discarded)
+}
+
+// clientStream is a state of single request, guarded by conn.mu
+type clientStream struct {
+	cc   *ClientConn
+	id   uint32
+	flow flow
+	stop func()
+
+	respReady chan struct{}
+	respErr   error
+	status    specs.StatusCode
+	header    *specs.Header
+	body      *pipe
+
+	gotResponse bool
+	recvEnded   bool
+	sendEnded   bool
+	sendErr     error
+	removed     bool
+}
+
+func (cs *clientStream) sendAbortedLocked() error {
+	return cs.sendErr
+}
+
+// writeBody writes request body as DATA frames and ends the stream.
+func (cs *clientStream) writeBody(body func(w io.Writer) error) {
+	cc := cs.cc
+	bw := bufio.NewWriterSize(&dataWriter{
+		c:        cc.conn,
+		streamID: cs.id,
+		flow:     &cs.flow,
+		aborted:  cs.sendAbortedLocked,
+	}, int(defaultMaxFrameSize))
+
+	err := body(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+
+	cc.mu.Lock()
+	if cs.sendErr != nil {
+		// Stream is already aborted
+		cc.mu.Unlock()
+		return
+	}
+	cc.mu.Unlock()
+
+	if err == nil {
+		err = cc.writeData(cs.id, true, nil)
+	}
+	if err != nil {
+		cc.resetStream(cs, http2.ErrCodeCancel, err)
+		return
+	}
+
+	cc.mu.Lock()
+	cs.sendEnded = true
+	if cs.recvEnded {
+		cc.removeStreamLocked(cs)
+	}
+	cc.mu.Unlock()
+}
+
+// clientBody is a response body of the stream.
+type clientBody struct {
+	cs *clientStream
+}
+
+func (b *clientBody) Read(p []byte) (int, error) {
+	cs := b.cs
+	cc := cs.cc
+
+	n, err := cs.body.Read(p)
+	if n > 0 {
+		var fl *flow
+		cc.mu.Lock()
+		if !cs.recvEnded && !cs.removed {
+			fl = &cs.flow
+		}
+		cc.mu.Unlock()
+		cc.creditRecvWindow(cs.id, fl, n)
+	}
+	return n, err
+}
+
+func (b *clientBody) Close() error {
+	cs := b.cs
+	cc := cs.cc
+
+	cc.resetStream(cs, http2.ErrCodeCancel, ErrStreamClosed)
+	discarded := cs.body.BreakWithError(ErrStreamClosed)
+	cc.creditRecvWindow(0, nil, discarded)
+	return nil
+}
+
+type retryableError struct {
+	err error
+}
+
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() []error {
+	return []error{e.err, ErrRetryable}
+}
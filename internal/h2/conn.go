@@ -0,0 +1,402 @@
+package h2
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// flow is a flow control state of single stream, guarded by conn.mu
+type flow struct {
+	sendWindow  int64
+	recvWindow  int64
+	recvUnacked int64
+}
+
+// conn is a state shared by client and server HTTP/2 connections:
+// frames writing, header compression, flow control and peer settings.
+type conn struct {
+	nc     net.Conn
+	bw     *bufio.Writer
+	framer *http2.Framer
+
+	// wmu guards writing of frames and header encoder state
+	wmu  sync.Mutex
+	henc *hpack.Encoder
+	hbuf bytes.Buffer
+
+	// mu guards fields below
+	mu   sync.Mutex
+	cond sync.Cond
+
+	peerMaxFrameSize  uint32
+	peerInitialWindow int32
+	sendWindow        int64
+	recvWindow        int64
+	recvUnacked       int64
+	err               error
+}
+
+func newConn(nc net.Conn, reader io.Reader, maxHeaderListSize uint32) *conn {
+	c := &conn{
+		nc:                nc,
+		bw:                bufio.NewWriterSize(nc, 4<<10),
+		peerMaxFrameSize:  defaultMaxFrameSize,
+		peerInitialWindow: defaultWindowSize,
+		sendWindow:        int64(defaultWindowSize),
+		recvWindow:        int64(defaultWindowSize),
+	}
+	c.cond.L = &c.mu
+	c.henc = hpack.NewEncoder(&c.hbuf)
+
+	if reader == nil {
+		reader = bufio.NewReaderSize(nc, 4<<10)
+	}
+	c.framer = http2.NewFramer(c.bw, reader)
+	c.framer.ReadMetaHeaders = hpack.NewDecoder(defaultHeaderTableSize, nil)
+	c.framer.MaxHeaderListSize = maxHeaderListSize
+	c.framer.SetMaxReadFrameSize(maxReadFrameSize)
+	c.framer.SetReuseFrames()
+	return c
+}
+
+// localSettings returns settings which are sent to the peer
+// in the first SETTINGS frame.
+func (c *conn) localSettings(extra ...http2.Setting) []http2.Setting {
+	settings := []http2.Setting{
+		{ID: http2.SettingInitialWindowSize, Val: uint32(initialStreamWindowSize)},
+		{ID: http2.SettingMaxFrameSize, Val: maxReadFrameSize},
+	}
+	if c.framer.MaxHeaderListSize > 0 {
+		settings = append(settings, http2.Setting{ID: http2.SettingMaxHeaderListSize, Val: c.framer.MaxHeaderListSize})
+	}
+	return append(settings, extra...)
+}
+
+// writeInitialFrames writes SETTINGS frame and enlarges connection receive window.
+func (c *conn) writeInitialFrames(settings []http2.Setting) error {
+	c.mu.Lock()
+	c.recvWindow = int64(initialConnWindowSize)
+	c.mu.Unlock()
+
+	return c.write(func(fr *http2.Framer) error {
+		if err := fr.WriteSettings(settings...); err != nil {
+			return err
+		}
+		return fr.WriteWindowUpdate(0, uint32(initialConnWindowSize-defaultWindowSize))
+	})
+}
+
+// write calls fn with exclusive access to the framer and flushes written frames.
+func (c *conn) write(fn func(fr *http2.Framer) error) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeLocked(fn)
+}
+
+func (c *conn) writeLocked(fn func(fr *http2.Framer) error) error {
+	if err := c.brokenErr(); err != nil {
+		return err
+	}
+	err := fn(c.framer)
+	if err == nil {
+		err = c.bw.Flush()
+	}
+	if err != nil {
+		c.fail(err)
+	}
+	return err
+}
+
+// writeHeadersLocked encodes header block and writes it as HEADERS
+// and CONTINUATION frames, must be called with wmu held.
+func (c *conn) writeHeadersLocked(streamID uint32, endStream bool, encode func(enc *hpack.Encoder) error) error {
+	c.hbuf.Reset()
+	if err := encode(c.henc); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	maxFrameSize := int(c.peerMaxFrameSize)
+	c.mu.Unlock()
+
+	block := c.hbuf.Bytes()
+	return c.writeLocked(func(fr *http2.Framer) error {
+		first := true
+		for first || len(block) > 0 {
+			chunk := block
+			if len(chunk) > maxFrameSize {
+				chunk = chunk[:maxFrameSize]
+			}
+			block = block[len(chunk):]
+			endHeaders := len(block) == 0
+
+			var err error
+			if first {
+				first = false
+				err = fr.WriteHeaders(http2.HeadersFrameParam{
+					StreamID:      streamID,
+					BlockFragment: chunk,
+					EndStream:     endStream,
+					EndHeaders:    endHeaders,
+				})
+			} else {
+				err = fr.WriteContinuation(streamID, endHeaders, chunk)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) writeData(streamID uint32, endStream bool, data []byte) error {
+	return c.write(func(fr *http2.Framer) error {
+		return fr.WriteData(streamID, endStream, data)
+	})
+}
+
+func (c *conn) writeRSTStream(streamID uint32, code http2.ErrCode) error {
+	return c.write(func(fr *http2.Framer) error {
+		return fr.WriteRSTStream(streamID, code)
+	})
+}
+
+func (c *conn) writeGoAway(lastStreamID uint32, code http2.ErrCode, debugData []byte) error {
+	return c.write(func(fr *http2.Framer) error {
+		return fr.WriteGoAway(lastStreamID, code, debugData)
+	})
+}
+
+// takeSendWindow blocks until any part of want bytes can be sent on the stream
+// according to flow control windows and returns allowed size.
+//
+// The aborted function is checked under the lock before each wait.
+func (c *conn) takeSendWindow(fl *flow, want int, aborted func() error) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		if err := aborted(); err != nil {
+			return 0, err
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+
+		n := int64(want)
+		n = min(n, int64(c.peerMaxFrameSize), fl.sendWindow, c.sendWindow)
+		if n > 0 {
+			fl.sendWindow -= n
+			c.sendWindow -= n
+			return int(n), nil
+		}
+		c.cond.Wait()
+	}
+}
+
+// consumeRecvWindow accounts received DATA frame payload.
+// Returns connection-level or stream-level flow control violation.
+func (c *conn) consumeRecvWindow(fl *flow, size int) (connViolated, streamViolated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := int64(size)
+	if n > c.recvWindow {
+		return true, false
+	}
+	c.recvWindow -= n
+	if fl == nil {
+		return false, false
+	}
+	if n > fl.recvWindow {
+		return false, true
+	}
+	fl.recvWindow -= n
+	return false, false
+}
+
+// creditRecvWindow returns n consumed bytes back to receive windows
+// and sends WINDOW_UPDATE frames when enough is accumulated.
+//
+// If fl is nil only connection window is updated.
+func (c *conn) creditRecvWindow(streamID uint32, fl *flow, n int) {
+	if n <= 0 {
+		return
+	}
+
+	var connIncr, streamIncr int64
+	c.mu.Lock()
+	c.recvUnacked += int64(n)
+	if c.recvUnacked >= int64(initialConnWindowSize)/4 {
+		connIncr = c.recvUnacked
+		c.recvWindow += connIncr
+		c.recvUnacked = 0
+	}
+	if fl != nil {
+		fl.recvUnacked += int64(n)
+		if fl.recvUnacked >= int64(initialStreamWindowSize)/4 {
+			streamIncr = fl.recvUnacked
+			fl.recvWindow += streamIncr
+			fl.recvUnacked = 0
+		}
+	}
+	c.mu.Unlock()
+
+	if connIncr == 0 && streamIncr == 0 {
+		return
+	}
+
+	c.write(func(fr *http2.Framer) error {
+		if connIncr > 0 {
+			if err := fr.WriteWindowUpdate(0, uint32(connIncr)); err != nil {
+				return err
+			}
+		}
+		if streamIncr > 0 {
+			return fr.WriteWindowUpdate(streamID, uint32(streamIncr))
+		}
+		return nil
+	})
+}
+
+// addSendWindow applies WINDOW_UPDATE frame of the peer.
+// Returns false if the window overflowed.
+func (c *conn) addSendWindow(fl *flow, incr uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if fl == nil {
+		if c.sendWindow+int64(incr) > maxWindowSize {
+			return false
+		}
+		c.sendWindow += int64(incr)
+	} else {
+		if fl.sendWindow+int64(incr) > maxWindowSize {
+			return false
+		}
+		fl.sendWindow += int64(incr)
+	}
+	c.cond.Broadcast()
+	return true
+}
+
+// applySettings applies peer SETTINGS frame and acknowledges it.
+func (c *conn) applySettings(f *http2.SettingsFrame, streams func(yield func(*flow) bool), onSetting func(s http2.Setting) error) error {
+	if f.IsAck() {
+		return nil
+	}
+
+	var settings []http2.Setting
+	f.ForeachSetting(func(s http2.Setting) error {
+		settings = append(settings, s)
+		return nil
+	})
+
+	if err := c.applySettingsList(settings, streams, onSetting); err != nil {
+		return err
+	}
+
+	return c.write(func(fr *http2.Framer) error {
+		return fr.WriteSettingsAck()
+	})
+}
+
+// applySettingsList applies peer settings
+// and adjusts send windows of active streams.
+func (c *conn) applySettingsList(settings []http2.Setting, streams func(yield func(*flow) bool), onSetting func(s http2.Setting) error) error {
+	for _, s := range settings {
+		if err := s.Valid(); err != nil {
+			return err
+		}
+
+		switch s.ID {
+		case http2.SettingMaxFrameSize:
+			c.mu.Lock()
+			c.peerMaxFrameSize = s.Val
+			c.mu.Unlock()
+		case http2.SettingInitialWindowSize:
+			c.mu.Lock()
+			delta := int64(s.Val) - int64(c.peerInitialWindow)
+			c.peerInitialWindow = int32(s.Val)
+			for fl := range streams {
+				fl.sendWindow += delta
+				if fl.sendWindow > maxWindowSize {
+					c.mu.Unlock()
+					return &ConnectionError{Code: http2.ErrCodeFlowControl, Reason: "window overflow by settings"}
+				}
+			}
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		case http2.SettingHeaderTableSize:
+			c.wmu.Lock()
+			c.henc.SetMaxDynamicTableSize(s.Val)
+			c.wmu.Unlock()
+		}
+
+		if onSetting != nil {
+			if err := onSetting(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newFlow creates flow control state for a new stream.
+func (c *conn) newFlow() flow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return flow{
+		sendWindow: int64(c.peerInitialWindow),
+		recvWindow: int64(initialStreamWindowSize),
+	}
+}
+
+func (c *conn) brokenErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// fail marks connection as broken and closes it.
+func (c *conn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	c.nc.Close()
+}
+
+// dataWriter writes stream body as DATA frames with respect to flow control.
+type dataWriter struct {
+	c        *conn
+	streamID uint32
+	flow     *flow
+	aborted  func() error
+}
+
+func (w *dataWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		size, err := w.c.takeSendWindow(w.flow, len(p), w.aborted)
+		if err != nil {
+			return written, err
+		}
+		if err = w.c.writeData(w.streamID, false, p[:size]); err != nil {
+			return written, err
+		}
+		written += size
+		p = p[size:]
+	}
+	return written, nil
+}
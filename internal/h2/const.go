@@ -0,0 +1,88 @@
+package h2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http2"
+)
+
+// NextProtoTLS is the NPN/ALPN protocol negotiated during
+// HTTP/2's TLS setup.
+const NextProtoTLS = "h2"
+
+// ClientPreface is the string that must be sent by new
+// connections from clients.
+const ClientPreface = http2.ClientPreface
+
+const (
+	// Minimal size of frame payload which must be supported by every peer
+	defaultMaxFrameSize uint32 = 16 << 10 // 16 kb
+
+	// Maximum size of frame payload which we are ready to read
+	maxReadFrameSize uint32 = 1 << 20 // 1 mb
+
+	// Default initial window size by RFC 9113 for streams and connection
+	defaultWindowSize int32 = 65535
+
+	// Initial window sizes advertised to the peer
+	initialStreamWindowSize int32 = 1 << 20 // 1 mb
+	initialConnWindowSize   int32 = 1 << 24 // 16 mb
+
+	// Maximum allowed window size by RFC 9113
+	maxWindowSize int64 = 1<<31 - 1
+
+	// Default dynamic table size of HPACK
+	defaultHeaderTableSize uint32 = 4 << 10 // 4 kb
+
+	// Default limit of concurrent streams until peer sends settings
+	defaultMaxConcurrentStreams uint32 = 100
+)
+
+var (
+	// ErrRetryable reports that request was not processed by the peer
+	// and may be retried safely on a new connection.
+	ErrRetryable = errors.New("http2: request was not processed")
+
+	ErrConnClosed    = specs.NewOpError("http2", "connection closed")
+	ErrStreamClosed  = specs.NewOpError("http2", "stream closed")
+	ErrHeaderTooLong = specs.NewOpError("http2", "header list too long")
+)
+
+// StreamError is an error that only affects one stream
+// within an HTTP/2 connection.
+type StreamError struct {
+	StreamID uint32
+	Code     http2.ErrCode
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("plow/http2: stream error: stream ID %d; %v", e.StreamID, e.Code)
+}
+
+// ConnectionError is an error that results in the termination
+// of the entire connection.
+type ConnectionError struct {
+	Code   http2.ErrCode
+	Reason string
+}
+
+func (e *ConnectionError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("plow/http2: connection error: %v: %s", e.Code, e.Reason)
+	}
+	return fmt.Sprintf("plow/http2: connection error: %v", e.Code)
+}
+
+// GoAwayError is returned when the peer closed connection with GOAWAY frame.
+type GoAwayError struct {
+	LastStreamID uint32
+	Code         http2.ErrCode
+	DebugData    string
+}
+
+func (e *GoAwayError) Error() string {
+	return fmt.Sprintf("plow/http2: peer sent GOAWAY and closed the connection; LastStreamID=%d, ErrCode=%v, debug=%q",
+		e.LastStreamID, e.Code, e.DebugData)
+}
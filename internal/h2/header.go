@@ -0,0 +1,86 @@
+package h2
+
+import (
+	"strings"
+
+	"github.com/oesand/plow/internal/parsing"
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Connection-specific header fields which are prohibited in HTTP/2 by RFC 9113, section 8.2.2.
+func isConnectionHeader(name string) bool {
+	switch name {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade", "host", "http2-settings":
+		return true
+	}
+	return false
+}
+
+// encodeHeader writes regular header fields of header into the encoder.
+//
+// Cookies are written as 'cookie' field for requests
+// and as 'set-cookie' fields for responses.
+func encodeHeader(enc *hpack.Encoder, header *specs.Header, isResponse bool) error {
+	for name, value := range header.All() {
+		name = strings.ToLower(name)
+		if isConnectionHeader(name) {
+			continue
+		}
+		if name == "te" && value != "trailers" {
+			continue
+		}
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			continue
+		}
+		if err := enc.WriteField(hpack.HeaderField{Name: name, Value: value}); err != nil {
+			return err
+		}
+	}
+
+	if !header.AnyCookies() {
+		return nil
+	}
+
+	if isResponse {
+		for cookie := range header.Cookies() {
+			err := enc.WriteField(hpack.HeaderField{Name: "set-cookie", Value: string(parsing.SetCookieBytes(&cookie))})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var buf strings.Builder
+	for cookie := range header.Cookies() {
+		if buf.Len() > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(cookie.Name)
+		buf.WriteByte('=')
+		buf.WriteString(cookie.Value)
+	}
+	return enc.WriteField(hpack.HeaderField{Name: "cookie", Value: buf.String()})
+}
+
+// decodeHeader converts regular header fields into [specs.Header].
+func decodeHeader(fields []hpack.HeaderField) *specs.Header {
+	header := specs.NewHeader()
+	for _, field := range fields {
+		switch field.Name {
+		case "cookie":
+			for key, value := range parsing.ParseCookieHeader(field.Value) {
+				header.SetCookieValue(key, value)
+			}
+		case "set-cookie":
+			if cookie := parsing.ParseSetCookieHeader(field.Value); cookie != nil {
+				header.SetCookie(*cookie)
+			}
+		default:
+			header.Add(field.Name, field.Value)
+		}
+	}
+	return header
+}
@@ -0,0 +1,92 @@
+package h2
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// pipe is a goroutine-safe buffer of stream data
+// which is filled by the connection read loop and consumed by the body reader.
+type pipe struct {
+	mu       sync.Mutex
+	cond     sync.Cond
+	buf      bytes.Buffer
+	err      error // returned when buffer is drained
+	breakErr error // returned immediately
+}
+
+func newPipe() *pipe {
+	p := &pipe{}
+	p.cond.L = &p.mu
+	return p
+}
+
+// Len returns count of unread buffered bytes.
+func (p *pipe) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.Len()
+}
+
+func (p *pipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.breakErr != nil {
+		// Reader is gone, data must be discarded
+		return 0, p.breakErr
+	}
+	if p.err != nil {
+		return 0, io.ErrClosedPipe
+	}
+	defer p.cond.Broadcast()
+	return p.buf.Write(b)
+}
+
+func (p *pipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.breakErr != nil {
+			return 0, p.breakErr
+		}
+		if p.buf.Len() > 0 {
+			return p.buf.Read(b)
+		}
+		if p.err != nil {
+			return 0, p.err
+		}
+		p.cond.Wait()
+	}
+}
+
+// CloseWithError makes reads return err after buffered data is consumed.
+func (p *pipe) CloseWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err == nil {
+		p.err = err
+		p.cond.Broadcast()
+	}
+}
+
+// BreakWithError discards buffered data and makes reads return err immediately.
+//
+// Returns count of discarded bytes.
+func (p *pipe) BreakWithError(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.breakErr != nil {
+		return 0
+	}
+
+	p.breakErr = err
+	discarded := p.buf.Len()
+	p.buf = bytes.Buffer{}
+	p.cond.Broadcast()
+	return discarded
+}
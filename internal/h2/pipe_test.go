@@ -0,0 +1,57 @@
+package h2
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPipe_CloseWithError(t *testing.T) {
+	p := newPipe()
+	p.Write([]byte("hello"))
+	p.CloseWithError(io.EOF)
+
+	data, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatal("read:", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected hello, got %s", data)
+	}
+
+	if _, err = p.Write([]byte("x")); err == nil {
+		t.Error("expected write error after close")
+	}
+}
+
+func TestPipe_BreakWithError(t *testing.T) {
+	p := newPipe()
+	p.Write([]byte("hello"))
+
+	breakErr := errors.New("break")
+	if discarded := p.BreakWithError(breakErr); discarded != 5 {
+		t.Errorf("expected 5 discarded bytes, got %d", discarded)
+	}
+
+	if _, err := p.Read(make([]byte, 8)); !errors.Is(err, breakErr) {
+		t.Errorf("expected break error, got %v", err)
+	}
+	if _, err := p.Write([]byte("x")); !errors.Is(err, breakErr) {
+		t.Errorf("expected break error, got %v", err)
+	}
+}
+
+func TestPipe_BlockingRead(t *testing.T) {
+	p := newPipe()
+	done := make(chan string)
+	go func() {
+		buf := make([]byte, 8)
+		n, _ := p.Read(buf)
+		done <- string(buf[:n])
+	}()
+
+	p.Write([]byte("data"))
+	if got := <-done; got != "data" {
+		t.Errorf("expected data, got %s", got)
+	}
+}
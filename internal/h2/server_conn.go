@@ -0,0 +1,702 @@
+package h2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Default limit of concurrent streams opened by the client
+const defaultServerMaxConcurrentStreams uint32 = 250
+
+// ServerConfig specifies limits and timeouts of HTTP/2 server connection.
+type ServerConfig struct {
+	// MaxHeaderListSize limits size of request header list.
+	//
+	// If zero there is no limit
+	MaxHeaderListSize uint32
+
+	// MaxConcurrentStreams limits count of concurrently handled streams.
+	//
+	// If zero, 250 is used.
+	MaxConcurrentStreams uint32
+
+	// IdleTimeout closes the connection when it has no active streams for a duration.
+	//
+	// If zero there is no timeout
+	IdleTimeout time.Duration
+
+	// ReadTimeout limits reading of the request body of each stream.
+	//
+	// If zero there is no timeout
+	ReadTimeout time.Duration
+
+	// WriteTimeout limits handling and writing of the response of each stream.
+	//
+	// If zero there is no timeout
+	WriteTimeout time.Duration
+}
+
+// StreamHandler handles a single request stream, it is called in a separate goroutine.
+//
+// The stream is closed after the handler returns.
+type StreamHandler func(st *ServerStream)
+
+// serverConn is a single HTTP/2 server connection.
+type serverConn struct {
+	*conn
+
+	ctx     context.Context
+	config  ServerConfig
+	handler StreamHandler
+	wg      sync.WaitGroup
+
+	// guarded by conn.mu
+	streams      map[uint32]*ServerStream
+	lastStreamID uint32
+	shutdown     bool
+	idleTimer    *time.Timer
+}
+
+// ServeConn serves HTTP/2 connection until it is closed by the peer,
+// idle timeout is exceeded or context is done, then waits for active handlers.
+//
+// Reader is an optional reader of the connection with already buffered data,
+// the client preface must not be consumed yet.
+//
+// Upgrade is an optional request received with h2c upgrade handshake,
+// which is served as the stream 1.
+func ServeConn(ctx context.Context, nc net.Conn, reader io.Reader, config ServerConfig, upgrade *UpgradeRequest, handler StreamHandler) error {
+	if reader == nil {
+		reader = bufio.NewReaderSize(nc, 4<<10)
+	}
+
+	if config.MaxConcurrentStreams == 0 {
+		config.MaxConcurrentStreams = defaultServerMaxConcurrentStreams
+	}
+
+	sc := &serverConn{
+		conn:    newConn(nc, reader, config.MaxHeaderListSize),
+		ctx:     ctx,
+		config:  config,
+		handler: handler,
+		streams: make(map[uint32]*ServerStream),
+	}
+
+	// Server preface is sent first, the client may wait for it after upgrade
+	err := sc.writeInitialFrames(sc.localSettings(
+		http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: config.MaxConcurrentStreams},
+	))
+	if err != nil {
+		return err
+	}
+
+	if config.ReadTimeout > 0 {
+		nc.SetReadDeadline(time.Now().Add(config.ReadTimeout))
+	}
+	preface := make([]byte, len(ClientPreface))
+	if _, err = io.ReadFull(reader, preface); err != nil {
+		return err
+	}
+	if string(preface) != ClientPreface {
+		return &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "invalid client preface"}
+	}
+	nc.SetReadDeadline(time.Time{})
+
+	if upgrade != nil {
+		if err = sc.applySettingsList(upgrade.Settings, sc.streamFlows, nil); err != nil {
+			return err
+		}
+		sc.startUpgradeStream(upgrade)
+	}
+
+	if config.IdleTimeout > 0 {
+		sc.idleTimer = time.AfterFunc(config.IdleTimeout, sc.closeIfIdle)
+	}
+	stopShutdown := context.AfterFunc(ctx, sc.startShutdown)
+	defer stopShutdown()
+
+	err = sc.readLoop()
+
+	var ce *ConnectionError
+	if errors.As(err, &ce) {
+		sc.writeGoAway(sc.currentLastStreamID(), ce.Code, nil)
+	}
+	sc.fail(err)
+
+	sc.mu.Lock()
+	shutdown := sc.shutdown
+	for _, st := range sc.streams {
+		sc.abortStreamLocked(st, ErrConnClosed)
+	}
+	if sc.idleTimer != nil {
+		sc.idleTimer.Stop()
+	}
+	sc.mu.Unlock()
+
+	sc.wg.Wait()
+
+	if ce != nil {
+		return ce
+	}
+	if shutdown || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (sc *serverConn) currentLastStreamID() uint32 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.lastStreamID
+}
+
+// startShutdown sends GOAWAY frame and closes the connection
+// after active streams are done.
+func (sc *serverConn) startShutdown() {
+	sc.mu.Lock()
+	if sc.shutdown {
+		sc.mu.Unlock()
+		return
+	}
+	sc.shutdown = true
+	lastStreamID := sc.lastStreamID
+	idle := len(sc.streams) == 0
+	sc.mu.Unlock()
+
+	sc.writeGoAway(lastStreamID, http2.ErrCodeNo, nil)
+	if idle {
+		sc.nc.Close()
+	}
+}
+
+func (sc *serverConn) closeIfIdle() {
+	sc.mu.Lock()
+	idle := len(sc.streams) == 0
+	sc.mu.Unlock()
+
+	if idle {
+		sc.startShutdown()
+	}
+}
+
+func (sc *serverConn) readLoop() error {
+	for {
+		f, err := sc.framer.ReadFrame()
+		if err != nil {
+			var se http2.StreamError
+			if errors.As(err, &se) {
+				sc.resetStreamByID(se.StreamID, se.Code)
+				continue
+			}
+			var ce http2.ConnectionError
+			if errors.As(err, &ce) {
+				return &ConnectionError{Code: http2.ErrCode(ce)}
+			}
+			return err
+		}
+
+		switch f := f.(type) {
+		case *http2.MetaHeadersFrame:
+			err = sc.handleHeaders(f)
+		case *http2.DataFrame:
+			err = sc.handleData(f)
+		case *http2.RSTStreamFrame:
+			sc.mu.Lock()
+			var discarded int
+			if st := sc.streams[f.StreamID]; st != nil {
+				discarded = sc.abortStreamLocked(st, &StreamError{StreamID: f.StreamID, Code: f.ErrCode})
+			} else if f.StreamID > sc.lastStreamID {
+				err = &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "reset of idle stream"}
+			}
+			sc.mu.Unlock()
+			sc.creditRecvWindow(0, nil, discarded)
+		case *http2.SettingsFrame:
+			err = sc.applySettings(f, sc.streamFlows, nil)
+		case *http2.WindowUpdateFrame:
+			err = sc.handleWindowUpdate(f)
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				data := f.Data
+				err = sc.write(func(fr *http2.Framer) error {
+					return fr.WritePing(true, data)
+				})
+			}
+		case *http2.GoAwayFrame:
+			// Client will not open new streams
+			sc.startShutdown()
+		case *http2.PushPromiseFrame:
+			err = &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "push from client"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// streamFlows iterates over flow control states of active streams, called with mu held.
+func (sc *serverConn) streamFlows(yield func(*flow) bool) {
+	for _, st := range sc.streams {
+		if !yield(&st.flow) {
+			return
+		}
+	}
+}
+
+func (sc *serverConn) resetStreamByID(id uint32, code http2.ErrCode) {
+	sc.mu.Lock()
+	st := sc.streams[id]
+	sc.mu.Unlock()
+
+	if st != nil {
+		st.reset(code, &StreamError{StreamID: id, Code: code})
+	} else {
+		sc.writeRSTStream(id, code)
+	}
+}
+
+func (sc *serverConn) handleHeaders(f *http2.MetaHeadersFrame) error {
+	id := f.StreamID
+	if id%2 == 0 {
+		return &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "even stream id from client"}
+	}
+
+	sc.mu.Lock()
+	if st := sc.streams[id]; st != nil {
+		// Trailers ends the request body, they are not exposed
+		if !f.StreamEnded() || st.recvEnded {
+			sc.mu.Unlock()
+			st.reset(http2.ErrCodeProtocol, &StreamError{StreamID: id, Code: http2.ErrCodeProtocol})
+			return nil
+		}
+		sc.endRecvLocked(st)
+		sc.mu.Unlock()
+		return nil
+	}
+	if id <= sc.lastStreamID {
+		sc.mu.Unlock()
+		return &ConnectionError{Code: http2.ErrCodeStreamClosed, Reason: "headers on closed stream"}
+	}
+	sc.lastStreamID = id
+
+	if sc.shutdown {
+		sc.mu.Unlock()
+		sc.writeRSTStream(id, http2.ErrCodeRefusedStream)
+		return nil
+	}
+	if uint32(len(sc.streams)) >= sc.config.MaxConcurrentStreams {
+		sc.mu.Unlock()
+		sc.writeRSTStream(id, http2.ErrCodeRefusedStream)
+		return nil
+	}
+	sc.mu.Unlock()
+
+	if f.Truncated {
+		sc.writeRSTStream(id, http2.ErrCodeProtocol)
+		return nil
+	}
+
+	method := specs.HttpMethod(f.PseudoValue("method"))
+	path := f.PseudoValue("path")
+	scheme := f.PseudoValue("scheme")
+	if !method.IsValid() || method != specs.HttpMethodConnect && (path == "" || scheme == "") {
+		sc.writeRSTStream(id, http2.ErrCodeProtocol)
+		return nil
+	}
+
+	st := &ServerStream{
+		sc:        sc,
+		id:        id,
+		Method:    method,
+		Scheme:    scheme,
+		Authority: f.PseudoValue("authority"),
+		Path:      path,
+		Header:    decodeHeader(f.RegularFields()),
+	}
+	if !f.StreamEnded() {
+		st.body = newPipe()
+	}
+	sc.startStream(st)
+	return nil
+}
+
+// startStream registers the stream and runs the handler,
+// the stream without body is half-closed by the client.
+func (sc *serverConn) startStream(st *ServerStream) {
+	st.ctx, st.cancel = context.WithCancel(sc.ctx)
+
+	sc.mu.Lock()
+	st.flow = flow{
+		sendWindow: int64(sc.peerInitialWindow),
+		recvWindow: int64(initialStreamWindowSize),
+	}
+	sc.streams[st.id] = st
+	if sc.idleTimer != nil {
+		sc.idleTimer.Stop()
+	}
+	if st.body == nil {
+		st.recvEnded = true
+	} else if sc.config.ReadTimeout > 0 {
+		st.readTimer = time.AfterFunc(sc.config.ReadTimeout, func() {
+			sc.mu.Lock()
+			ended := st.recvEnded
+			sc.mu.Unlock()
+			if !ended {
+				st.reset(http2.ErrCodeCancel, specs.ErrTimeout)
+			}
+		})
+	}
+	if sc.config.WriteTimeout > 0 {
+		st.writeTimer = time.AfterFunc(sc.config.WriteTimeout, func() {
+			st.reset(http2.ErrCodeCancel, specs.ErrTimeout)
+		})
+	}
+	sc.mu.Unlock()
+
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+		defer st.finish()
+		sc.handler(st)
+	}()
+}
+
+func (sc *serverConn) handleData(f *http2.DataFrame) error {
+	size := int(f.Length)
+
+	sc.mu.Lock()
+	st := sc.streams[f.StreamID]
+	idle := st == nil && f.StreamID > sc.lastStreamID
+	var fl *flow
+	if st != nil && !st.recvEnded && !st.removed {
+		fl = &st.flow
+	}
+	sc.mu.Unlock()
+
+	if idle {
+		return &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "data on idle stream"}
+	}
+
+	connViolated, streamViolated := sc.consumeRecvWindow(fl, size)
+	if connViolated {
+		return &ConnectionError{Code: http2.ErrCodeFlowControl, Reason: "connection window exceeded"}
+	}
+
+	if fl == nil {
+		sc.creditRecvWindow(0, nil, size)
+		if st != nil {
+			st.reset(http2.ErrCodeStreamClosed, &StreamError{StreamID: st.id, Code: http2.ErrCodeStreamClosed})
+		} else {
+			sc.writeRSTStream(f.StreamID, http2.ErrCodeStreamClosed)
+		}
+		return nil
+	}
+	if streamViolated {
+		sc.creditRecvWindow(0, nil, size)
+		st.reset(http2.ErrCodeFlowControl, &StreamError{StreamID: st.id, Code: http2.ErrCodeFlowControl})
+		return nil
+	}
+
+	data := f.Data()
+	if padding := size - len(data); padding > 0 {
+		sc.creditRecvWindow(st.id, fl, padding)
+	}
+	if len(data) > 0 {
+		if _, err := st.body.Write(data); err != nil {
+			// Body is no more read by the handler
+			sc.creditRecvWindow(0, nil, len(data))
+		}
+	}
+
+	if f.StreamEnded() {
+		sc.mu.Lock()
+		sc.endRecvLocked(st)
+		sc.mu.Unlock()
+	}
+	return nil
+}
+
+func (sc *serverConn) handleWindowUpdate(f *http2.WindowUpdateFrame) error {
+	if f.StreamID == 0 {
+		if !sc.addSendWindow(nil, f.Increment) {
+			return &ConnectionError{Code: http2.ErrCodeFlowControl, Reason: "window overflow"}
+		}
+		return nil
+	}
+
+	sc.mu.Lock()
+	st := sc.streams[f.StreamID]
+	idle := st == nil && f.StreamID > sc.lastStreamID
+	sc.mu.Unlock()
+
+	if idle {
+		return &ConnectionError{Code: http2.ErrCodeProtocol, Reason: "window update on idle stream"}
+	}
+	if st != nil && !sc.addSendWindow(&st.flow, f.Increment) {
+		st.reset(http2.ErrCodeFlowControl, &StreamError{StreamID: st.id, Code: http2.ErrCodeFlowControl})
+	}
+	return nil
+}
+
+func (sc *serverConn) endRecvLocked(st *ServerStream) {
+	st.recvEnded = true
+	if st.body != nil {
+		st.body.CloseWithError(io.EOF)
+	}
+	if st.readTimer != nil {
+		st.readTimer.Stop()
+	}
+	if st.sendEnded {
+		sc.removeStreamLocked(st)
+	}
+}
+
+// abortStreamLocked fails reading of request body and writing of response with err.
+//
+// Returns count of discarded received bytes.
+func (sc *serverConn) abortStreamLocked(st *ServerStream, err error) int {
+	var discarded int
+	if st.body != nil && !st.recvEnded {
+		discarded = st.body.BreakWithError(err)
+	}
+	if st.resetErr == nil {
+		st.resetErr = err
+	}
+	sc.removeStreamLocked(st)
+	return discarded
+}
+
+func (sc *serverConn) removeStreamLocked(st *ServerStream) {
+	if st.removed {
+		return
+	}
+	st.removed = true
+	delete(sc.streams, st.id)
+	st.cancel()
+	if st.readTimer != nil {
+		st.readTimer.Stop()
+	}
+	if st.writeTimer != nil {
+		st.writeTimer.Stop()
+	}
+	sc.cond.Broadcast()
+
+	if len(sc.streams) == 0 {
+		if sc.shutdown {
+			go sc.nc.Close()
+		} else if sc.idleTimer != nil {
+			sc.idleTimer.Reset(sc.config.IdleTimeout)
+		}
+	}
+}
+
+// ServerStream is a single request stream of HTTP/2 server connection.
+type ServerStream struct {
+	sc     *serverConn
+	id     uint32
+	flow   flow
+	ctx    context.Context
+	cancel context.CancelFunc
+	body   *pipe
+
+	// upgradeBody is a body of the h2c upgrade request
+	upgradeBody []byte
+
+	readTimer  *time.Timer
+	writeTimer *time.Timer
+
+	Method    specs.HttpMethod
+	Scheme    string
+	Authority string
+	Path      string
+	Header    *specs.Header
+
+	// guarded by conn.mu
+	recvEnded   bool
+	headersSent bool
+	sendEnded   bool
+	resetErr    error
+	removed     bool
+}
+
+// Context returns context of the stream which is cancelled
+// when the stream is closed or reset.
+func (st *ServerStream) Context() context.Context {
+	return st.ctx
+}
+
+// RemoteAddr returns address of the client.
+func (st *ServerStream) RemoteAddr() net.Addr {
+	return st.sc.nc.RemoteAddr()
+}
+
+// Body returns reader of the request body, nil if request has no body.
+func (st *ServerStream) Body() io.Reader {
+	if st.upgradeBody != nil {
+		return bytes.NewReader(st.upgradeBody)
+	}
+	if st.body == nil {
+		return nil
+	}
+	return &serverBody{st: st}
+}
+
+// WriteHeaders writes response headers,
+// if endStream is set the response has no body.
+func (st *ServerStream) WriteHeaders(code specs.StatusCode, header *specs.Header, endStream bool) error {
+	sc := st.sc
+
+	sc.wmu.Lock()
+	sc.mu.Lock()
+	if st.resetErr != nil {
+		sc.mu.Unlock()
+		sc.wmu.Unlock()
+		return st.resetErr
+	}
+	if st.headersSent {
+		sc.mu.Unlock()
+		sc.wmu.Unlock()
+		return ErrStreamClosed
+	}
+	st.headersSent = true
+	sc.mu.Unlock()
+
+	err := sc.writeHeadersLocked(st.id, endStream, func(enc *hpack.Encoder) error {
+		enc.WriteField(hpack.HeaderField{Name: ":status", Value: strconv.Itoa(int(code))})
+		return encodeHeader(enc, header, true)
+	})
+	sc.wmu.Unlock()
+
+	if err == nil && endStream {
+		st.endSend()
+	}
+	return err
+}
+
+// Write writes response body as DATA frames,
+// headers must be written before.
+func (st *ServerStream) Write(p []byte) (int, error) {
+	sc := st.sc
+	sc.mu.Lock()
+	if !st.headersSent || st.sendEnded {
+		sc.mu.Unlock()
+		return 0, ErrStreamClosed
+	}
+	sc.mu.Unlock()
+
+	w := &dataWriter{
+		c:        sc.conn,
+		streamID: st.id,
+		flow:     &st.flow,
+		aborted:  st.resetErrLocked,
+	}
+	return w.Write(p)
+}
+
+// Close ends the response,
+// if headers were not written the stream is reset.
+func (st *ServerStream) Close() error {
+	sc := st.sc
+	sc.mu.Lock()
+	if st.sendEnded || st.resetErr != nil {
+		sc.mu.Unlock()
+		return st.resetErr
+	}
+	if !st.headersSent {
+		sc.mu.Unlock()
+		st.reset(http2.ErrCodeInternal, ErrStreamClosed)
+		return nil
+	}
+	sc.mu.Unlock()
+
+	if err := sc.writeData(st.id, true, nil); err != nil {
+		return err
+	}
+	st.endSend()
+	return nil
+}
+
+// Reset aborts the stream with INTERNAL_ERROR code.
+func (st *ServerStream) Reset() {
+	st.reset(http2.ErrCodeInternal, ErrStreamClosed)
+}
+
+func (st *ServerStream) resetErrLocked() error {
+	return st.resetErr
+}
+
+// endSend marks response as written, if the request body was not read to the end
+// the client is asked to stop sending it.
+func (st *ServerStream) endSend() {
+	sc := st.sc
+	sc.mu.Lock()
+	st.sendEnded = true
+	recvEnded := st.recvEnded
+	if recvEnded {
+		sc.removeStreamLocked(st)
+	}
+	sc.mu.Unlock()
+
+	if !recvEnded {
+		st.reset(http2.ErrCodeNo, ErrStreamClosed)
+	}
+}
+
+// finish closes the stream after the handler returned.
+func (st *ServerStream) finish() {
+	sc := st.sc
+	sc.mu.Lock()
+	done := st.sendEnded || st.resetErr != nil
+	sc.mu.Unlock()
+
+	if !done {
+		st.Close()
+	}
+}
+
+// reset aborts the stream and sends RST_STREAM frame to the client.
+func (st *ServerStream) reset(code http2.ErrCode, err error) {
+	sc := st.sc
+	sc.mu.Lock()
+	if st.removed {
+		sc.mu.Unlock()
+		return
+	}
+	discarded := sc.abortStreamLocked(st, err)
+	sc.mu.Unlock()
+
+	sc.creditRecvWindow(0, nil, discarded)
+	sc.writeRSTStream(st.id, code)
+}
+
+// serverBody is a request body of the stream.
+type serverBody struct {
+	st *ServerStream
+}
+
+func (b *serverBody) Read(p []byte) (int, error) {
+	st := b.st
+	sc := st.sc
+
+	n, err := st.body.Read(p)
+	if n > 0 {
+		var fl *flow
+		sc.mu.Lock()
+		if !st.recvEnded && !st.removed {
+			fl = &st.flow
+		}
+		sc.mu.Unlock()
+		sc.creditRecvWindow(st.id, fl, n)
+	}
+	return n, err
+}
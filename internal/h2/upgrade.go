@@ -0,0 +1,73 @@
+package h2
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http2"
+)
+
+// UpgradeRequest is a request received with HTTP/1.1 "Upgrade: h2c" handshake,
+// it is served as the stream 1 of the connection.
+type UpgradeRequest struct {
+	// Settings decoded from "HTTP2-Settings" header by ParseSettingsHeader
+	Settings []http2.Setting
+
+	Method    specs.HttpMethod
+	Scheme    string
+	Authority string
+	Path      string
+	Header    *specs.Header
+
+	// Body is already read request body, nil if request has no body.
+	Body []byte
+}
+
+var errInvalidSettingsHeader = errors.New("http2: invalid HTTP2-Settings header")
+
+// ParseSettingsHeader decodes base64url encoded SETTINGS frame payload
+// of "HTTP2-Settings" header of h2c upgrade request.
+func ParseSettingsHeader(value string) ([]http2.Setting, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(data)%6 != 0 {
+		return nil, errInvalidSettingsHeader
+	}
+
+	settings := make([]http2.Setting, 0, len(data)/6)
+	for ; len(data) > 0; data = data[6:] {
+		setting := http2.Setting{
+			ID:  http2.SettingID(binary.BigEndian.Uint16(data[:2])),
+			Val: binary.BigEndian.Uint32(data[2:6]),
+		}
+		if err = setting.Valid(); err != nil {
+			return nil, errInvalidSettingsHeader
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// startUpgradeStream serves upgrade request as the stream 1
+// which is half-closed by the client.
+func (sc *serverConn) startUpgradeStream(upgrade *UpgradeRequest) {
+	st := &ServerStream{
+		sc:        sc,
+		id:        1,
+		Method:    upgrade.Method,
+		Scheme:    upgrade.Scheme,
+		Authority: upgrade.Authority,
+		Path:      upgrade.Path,
+		Header:    upgrade.Header,
+	}
+	// Body is already received, the stream is half-closed
+	st.upgradeBody = upgrade.Body
+
+	sc.mu.Lock()
+	sc.lastStreamID = 1
+	sc.mu.Unlock()
+
+	sc.startStream(st)
+}
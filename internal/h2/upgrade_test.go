@@ -0,0 +1,35 @@
+package h2
+
+import (
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func TestParseSettingsHeader(t *testing.T) {
+	settings, err := ParseSettingsHeader("AAMAAABkAAQAAP__")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []http2.Setting{
+		{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+		{ID: http2.SettingInitialWindowSize, Val: 65535},
+	}
+	if len(settings) != len(expected) {
+		t.Fatalf("expected %d settings, got %v", len(expected), settings)
+	}
+	for i, setting := range settings {
+		if setting != expected[i] {
+			t.Errorf("expected setting %v, got %v", expected[i], setting)
+		}
+	}
+}
+
+func TestParseSettingsHeader_Invalid(t *testing.T) {
+	for _, value := range []string{"AAMAAABk!", "AAMAAA", "AAQAgAAAAA"} {
+		if _, err := ParseSettingsHeader(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"errors"
 	"github.com/oesand/plow/specs"
 	"strconv"
+	"strings"
 )
 
 var ErrParsing = errors.New("cannot parse value")
 
+// ErrConflictingContentLength is returned when "Content-Length" header has different values.
+var ErrConflictingContentLength = errors.New("conflicting Content-Length values")
+
 func ParseContentLength(header *specs.Header) (isChunked bool, size int64, err error) {
 	if te, has := header.TryGet("Transfer-Encoding"); has {
 		switch te {
@@ -17,10 +21,27 @@ func ParseContentLength(header *specs.Header) (isChunked bool, size int64, err e
 			err = specs.ErrUnknownTransferEncoding
 			return
 		}
-	} else if cl := header.Get("Content-Length"); cl != "" {
+	} else if values := header.Values("Content-Length"); len(values) > 0 {
+		// Repeated or listed values must be identical, as defined in RFC 9110 section 8.6,
+		// otherwise the message is rejected to prevent request smuggling
+		var cl string
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				item = strings.TrimSpace(item)
+				if cl == "" {
+					cl = item
+				} else if item != cl {
+					err = ErrConflictingContentLength
+					return
+				}
+			}
+		}
+		if cl == "" {
+			return
+		}
 		size, err = strconv.ParseInt(cl, 10, 64)
-		if err != nil {
-			err = ErrParsing
+		if err != nil || size < 0 {
+			size, err = 0, ErrParsing
 		}
 	}
 	return
@@ -37,7 +37,38 @@ func TestParseContentLength(t *testing.T) {
 			wantSize: 100,
 		},
 
+		{
+			name: "Identical repeated Content-Length",
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Add("Content-Length", "5")
+				header.Add("Content-Length", "5, 5")
+			}),
+			wantSize: 5,
+		},
+
 		// Invalid cases
+		{
+			name: "Conflicting repeated Content-Length",
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Add("Content-Length", "5")
+				header.Add("Content-Length", "10")
+			}),
+			wantErr: true,
+		},
+		{
+			name: "Conflicting listed Content-Length",
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Set("Content-Length", "5, 10")
+			}),
+			wantErr: true,
+		},
+		{
+			name: "Negative Content-Length",
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Set("Content-Length", "-5")
+			}),
+			wantErr: true,
+		},
 		{
 			name: "Invalid Transfer-Encoding",
 			header: specs.NewHeader(func(header *specs.Header) {
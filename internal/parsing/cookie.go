@@ -99,7 +99,15 @@ func ParseSetCookieHeader(cookieValue string) *specs.Cookie {
 						cookie.Expires, _ = time.Parse(specs.TimeFormat, value)
 					case strings.EqualFold(key, "Max-Age"):
 						cookie.Expires = time.Time{}
-						cookie.MaxAge, _ = strconv.ParseUint(value, 10, 64)
+						cookie.MaxAge = 0
+						if maxAge, err := strconv.ParseInt(value, 10, 64); err == nil {
+							if maxAge > 0 {
+								cookie.MaxAge = uint64(maxAge)
+							} else {
+								// Zero or negative Max-Age expires the cookie immediately
+								cookie.Expires = time.Unix(0, 0)
+							}
+						}
 					case strings.EqualFold(key, "Domain"):
 						cookie.Domain = value
 					case strings.EqualFold(key, "Path"):
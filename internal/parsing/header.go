@@ -85,7 +85,7 @@ func applyKVHeader(header *specs.Header, key, value string) {
 			header.SetCookie(*cookie)
 		}
 	} else {
-		header.Set(key, value)
+		header.Add(key, value)
 	}
 }
 
@@ -55,7 +55,8 @@ func TestParseHeaders(t *testing.T) {
 			name: "Duplicate keys",
 			text: "X-Test: 1\nX-Test: 2\n",
 			want: specs.NewHeader(func(h *specs.Header) {
-				h.Set("X-Test", "2") // assuming Set overrides
+				h.Add("X-Test", "1")
+				h.Add("X-Test", "2")
 			}),
 		},
 		{
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HostPatterns is a list of destination host patterns
+// in the format of "NO_PROXY" environment variable.
+type HostPatterns []hostPattern
+
+type hostPattern struct {
+	any    bool
+	cidr   *net.IPNet
+	ip     net.IP
+	domain string
+	// suffixOnly matches subdomains of the domain, but not the domain itself
+	suffixOnly bool
+	port       uint16
+}
+
+// ParseHostPatterns parses the list of host patterns, invalid patterns are skipped.
+//
+// A pattern is "*" for any host, an IP address, a CIDR such as "10.0.0.0/8",
+// a domain name which matches the domain and its subdomains,
+// or a domain with leading "." or "*." which matches only subdomains.
+// Domains and IP addresses may be followed by ":port" to match only the port.
+func ParseHostPatterns(patterns []string) HostPatterns {
+	var result HostPatterns
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+
+		if pattern == "*" {
+			result = append(result, hostPattern{any: true})
+			continue
+		}
+
+		if strings.Contains(pattern, "/") {
+			if _, cidr, err := net.ParseCIDR(pattern); err == nil {
+				result = append(result, hostPattern{cidr: cidr})
+			}
+			continue
+		}
+
+		var p hostPattern
+		host := pattern
+		if h, portStr, err := net.SplitHostPort(pattern); err == nil {
+			port, err := strconv.ParseUint(portStr, 10, 16)
+			if err != nil {
+				continue
+			}
+			host, p.port = h, uint16(port)
+		}
+		host = strings.Trim(host, "[]")
+
+		if ip := net.ParseIP(host); ip != nil {
+			p.ip = ip
+		} else {
+			if strings.HasPrefix(host, "*.") {
+				host = host[1:]
+			}
+			if strings.HasPrefix(host, ".") {
+				host, p.suffixOnly = host[1:], true
+			}
+			if host == "" {
+				continue
+			}
+			p.domain = host
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// Match reports whether any pattern matches the host and port,
+// port 0 matches only patterns without port.
+func (patterns HostPatterns) Match(host string, port uint16) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	ip := net.ParseIP(host)
+	for _, p := range patterns {
+		if p.port != 0 && p.port != port {
+			continue
+		}
+
+		switch {
+		case p.any:
+			return true
+		case p.cidr != nil:
+			if ip != nil && p.cidr.Contains(ip) {
+				return true
+			}
+		case p.ip != nil:
+			if ip != nil && p.ip.Equal(ip) {
+				return true
+			}
+		case ip == nil:
+			if strings.HasSuffix(host, "."+p.domain) || !p.suffixOnly && host == p.domain {
+				return true
+			}
+		}
+	}
+	return false
+}
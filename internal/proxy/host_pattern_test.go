@@ -0,0 +1,51 @@
+package proxy
+
+import "testing"
+
+func TestHostPatterns_Match(t *testing.T) {
+	patterns := ParseHostPatterns([]string{
+		"example.com",
+		".internal.org",
+		"*.svc.local",
+		"api.test:8443",
+		"10.0.0.0/8",
+		"192.168.1.1",
+		"[::1]:443",
+		"invalid/cidr",
+		"",
+	})
+
+	tests := []struct {
+		host  string
+		port  uint16
+		match bool
+	}{
+		{"example.com", 80, true},
+		{"EXAMPLE.com.", 80, true},
+		{"www.example.com", 443, true},
+		{"notexample.com", 80, false},
+		{"internal.org", 80, false},
+		{"db.internal.org", 80, true},
+		{"svc.local", 80, false},
+		{"a.svc.local", 80, true},
+		{"api.test", 8443, true},
+		{"api.test", 443, false},
+		{"10.1.2.3", 80, true},
+		{"11.1.2.3", 80, false},
+		{"192.168.1.1", 80, true},
+		{"[::1]", 443, true},
+		{"::1", 80, false},
+	}
+	for _, tt := range tests {
+		if got := patterns.Match(tt.host, tt.port); got != tt.match {
+			t.Errorf("Match(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.match)
+		}
+	}
+
+	if !ParseHostPatterns([]string{"*"}).Match("anything", 1) {
+		t.Error("expected wildcard to match any host")
+	}
+	if HostPatterns(nil).Match("example.com", 80) {
+		t.Error("expected empty patterns to match nothing")
+	}
+}
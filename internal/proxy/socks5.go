@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 )
 
 const (
@@ -83,25 +84,9 @@ func DialSocks5(conn net.Conn, host string, port uint16, creds *Creds) (net.Addr
 
 	buf = buf[:0]
 	buf = append(buf, socksVersion5, socksCmdConnect, 0)
-	if ip := net.ParseIP(host); ip != nil {
-		if ip4 := ip.To4(); ip4 != nil {
-			buf = append(buf, socksAddrTypeIPv4)
-			buf = append(buf, ip4...)
-		} else if ip6 := ip.To16(); ip6 != nil {
-			buf = append(buf, socksAddrTypeIPv6)
-			buf = append(buf, ip6...)
-		} else {
-			return nil, errors.New("socks5: unknown address type")
-		}
-	} else {
-		if len(host) > 255 {
-			return nil, errors.New("socks5: FQDN too long")
-		}
-		buf = append(buf, socksAddrTypeFQDN)
-		buf = append(buf, byte(len(host)))
-		buf = append(buf, host...)
+	if buf, err = appendSocks5Addr(buf, host, port); err != nil {
+		return nil, err
 	}
-	buf = append(buf, byte(port>>8), byte(port))
 
 	if _, err = conn.Write(buf); err != nil {
 		return nil, err
@@ -157,6 +142,29 @@ func DialSocks5(conn net.Conn, host string, port uint16, creds *Creds) (net.Addr
 	return &addr, nil
 }
 
+// appendSocks5Addr appends the address type, address and port of the host to the buffer.
+func appendSocks5Addr(buf []byte, host string, port uint16) ([]byte, error) {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, socksAddrTypeIPv4)
+			buf = append(buf, ip4...)
+		} else if ip6 := ip.To16(); ip6 != nil {
+			buf = append(buf, socksAddrTypeIPv6)
+			buf = append(buf, ip6...)
+		} else {
+			return nil, errors.New("socks5: unknown address type")
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("socks5: FQDN too long")
+		}
+		buf = append(buf, socksAddrTypeFQDN)
+		buf = append(buf, byte(len(host)))
+		buf = append(buf, host...)
+	}
+	return append(buf, byte(port>>8), byte(port)), nil
+}
+
 func socksReplyCodeToError(code byte) string {
 	switch code {
 	case socksAuthSucceeded:
@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+const (
+	Socks5CmdConnect      byte = socksCmdConnect
+	Socks5CmdUDPAssociate byte = 0x03
+
+	Socks5ReplySucceeded           byte = socksAuthSucceeded
+	Socks5ReplyGeneralFailure      byte = 0x01
+	Socks5ReplyNotAllowed          byte = 0x02
+	Socks5ReplyNetworkUnreachable  byte = 0x03
+	Socks5ReplyHostUnreachable     byte = 0x04
+	Socks5ReplyConnectionRefused   byte = 0x05
+	Socks5ReplyCommandNotSupported byte = 0x07
+	Socks5ReplyAddrNotSupported    byte = 0x08
+
+	socksNoAcceptableFlag byte = 0xff
+	socksAuthFailed       byte = 0x01
+)
+
+// Socks5Request is a command of the client accepted by [AcceptSocks5].
+type Socks5Request struct {
+	Command byte
+	Host    string
+	Port    uint16
+}
+
+// Address returns the destination address in the "host:port" format.
+func (r *Socks5Request) Address() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(int(r.Port)))
+}
+
+// AcceptSocks5 performs the server side of RFC 1928 handshake
+// and reads the command of the client.
+//
+// If authenticate is nil, "no authentication" method is selected,
+// otherwise the client must authenticate with username and password of RFC 1929.
+func AcceptSocks5(conn io.ReadWriter, authenticate func(username, password string) bool) (*Socks5Request, error) {
+	buf := make([]byte, 0, 256)
+	buf = buf[:2]
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	if ver := buf[0]; ver != socksVersion5 {
+		return nil, fmt.Errorf("socks5: unexpected protocol version: %d", int(ver))
+	}
+
+	methods := buf[:buf[1]]
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+
+	method := socksNoAuthFlag
+	if authenticate != nil {
+		method = socksAuthByCredsFlag
+	}
+	if bytes.IndexByte(methods, method) < 0 {
+		conn.Write([]byte{socksVersion5, socksNoAcceptableFlag})
+		return nil, errors.New("socks5: no acceptable authentication methods")
+	}
+	if _, err := conn.Write([]byte{socksVersion5, method}); err != nil {
+		return nil, err
+	}
+
+	if method == socksAuthByCredsFlag {
+		username, password, err := readSocks5Creds(conn)
+		if err != nil {
+			return nil, err
+		}
+		if !authenticate(username, password) {
+			conn.Write([]byte{socksAuthCredsVersion, socksAuthFailed})
+			return nil, errors.New("socks5: username/password authentication failed")
+		}
+		if _, err = conn.Write([]byte{socksAuthCredsVersion, socksAuthSucceeded}); err != nil {
+			return nil, err
+		}
+	}
+
+	buf = buf[:3]
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	if ver := buf[0]; ver != socksVersion5 {
+		return nil, fmt.Errorf("socks5: unexpected protocol version: %d", int(ver))
+	}
+
+	host, port, err := readSocks5Addr(conn)
+	if err != nil {
+		if errors.Is(err, errSocks5AddrType) {
+			WriteSocks5Reply(conn, Socks5ReplyAddrNotSupported, nil)
+		}
+		return nil, err
+	}
+
+	return &Socks5Request{
+		Command: buf[1],
+		Host:    host,
+		Port:    port,
+	}, nil
+}
+
+// WriteSocks5Reply writes the reply to the command of the client with the bound address,
+// if the address is nil or not TCP/UDP address, zero IPv4 address is written.
+func WriteSocks5Reply(writer io.Writer, code byte, addr net.Addr) error {
+	host, port := "0.0.0.0", uint16(0)
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		host, port = addr.IP.String(), uint16(addr.Port)
+	case *net.UDPAddr:
+		host, port = addr.IP.String(), uint16(addr.Port)
+	}
+
+	buf, err := appendSocks5Addr([]byte{socksVersion5, code, 0}, host, port)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(buf)
+	return err
+}
+
+// Socks5ReplyCode returns the reply code to the client for the error of connecting to the destination.
+func Socks5ReplyCode(err error) byte {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return Socks5ReplySucceeded
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return Socks5ReplyConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return Socks5ReplyNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.As(err, &dnsErr):
+		return Socks5ReplyHostUnreachable
+	default:
+		return Socks5ReplyGeneralFailure
+	}
+}
+
+// ParseSocks5Datagram parses the UDP datagram of the client
+// with the header of the destination address.
+func ParseSocks5Datagram(packet []byte) (host string, port uint16, payload []byte, err error) {
+	if len(packet) < 4 {
+		return "", 0, nil, errors.New("socks5: short datagram")
+	}
+	if packet[2] != 0 {
+		return "", 0, nil, errors.New("socks5: fragmented datagrams not supported")
+	}
+
+	reader := bytes.NewReader(packet[3:])
+	if host, port, err = readSocks5Addr(reader); err != nil {
+		return "", 0, nil, err
+	}
+	return host, port, packet[len(packet)-reader.Len():], nil
+}
+
+// AppendSocks5Datagram appends the UDP datagram with the header
+// of the source address and the payload to the buffer.
+func AppendSocks5Datagram(buf []byte, host string, port uint16, payload []byte) ([]byte, error) {
+	buf, err := appendSocks5Addr(append(buf, 0, 0, 0), host, port)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, payload...), nil
+}
+
+var errSocks5AddrType = errors.New("socks5: unknown address type")
+
+// readSocks5Addr reads the address type, address and port.
+func readSocks5Addr(reader io.Reader) (string, uint16, error) {
+	buf := make([]byte, 1, 256)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", 0, err
+	}
+
+	var host string
+	switch buf[0] {
+	case socksAddrTypeIPv4, socksAddrTypeIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if buf[0] == socksAddrTypeIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return "", 0, err
+		}
+		host = ip.String()
+	case socksAddrTypeFQDN:
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", 0, err
+		}
+		buf = buf[:buf[0]]
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", 0, err
+		}
+		host = string(buf)
+	default:
+		return "", 0, errSocks5AddrType
+	}
+
+	buf = buf[:2]
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", 0, err
+	}
+	return host, uint16(buf[0])<<8 | uint16(buf[1]), nil
+}
+
+func readSocks5Creds(reader io.Reader) (string, string, error) {
+	buf := make([]byte, 2, 256)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", "", err
+	}
+	if buf[0] != socksAuthCredsVersion {
+		return "", "", errors.New("socks5: invalid username/password version")
+	}
+
+	buf = buf[:buf[1]]
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", "", err
+	}
+	username := string(buf)
+
+	buf = buf[:1]
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", "", err
+	}
+	buf = buf[:buf[0]]
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", "", err
+	}
+	return username, string(buf), nil
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAcceptSocks5(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   *Creds
+		host    string
+		wantErr string
+	}{
+		{name: "No Auth", host: "example.com"},
+		{name: "With Password", creds: &Creds{"username", "password"}, host: "10.0.0.1"},
+		{name: "IPv6", creds: &Creds{"username", "password"}, host: "::1"},
+		{name: "Invalid Password", creds: &Creds{"username", "invalid"}, host: "example.com",
+			wantErr: "socks5: username/password authentication failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authenticate func(username, password string) bool
+			if tt.creds != nil {
+				authenticate = func(username, password string) bool {
+					return username == "username" && password == "password"
+				}
+			}
+
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			accepted := make(chan *Socks5Request, 1)
+			go func() {
+				defer close(accepted)
+				req, err := AcceptSocks5(server, authenticate)
+				if err != nil {
+					if err.Error() != tt.wantErr {
+						t.Errorf("unexpected accept error %v", err)
+					}
+					server.Close()
+					return
+				}
+				WriteSocks5Reply(server, Socks5ReplySucceeded, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234})
+				accepted <- req
+			}()
+
+			addr, err := DialSocks5(client, tt.host, 8080, tt.creds)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got unexpected error '%v', expected '%v'", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr.String() != "127.0.0.1:1234" {
+				t.Errorf("unexpected bound address %s", addr)
+			}
+
+			req := <-accepted
+			if req.Command != Socks5CmdConnect || req.Host != tt.host || req.Port != 8080 {
+				t.Errorf("unexpected request %+v", req)
+			}
+		})
+	}
+}
+
+func TestSocks5Datagram(t *testing.T) {
+	packet, err := AppendSocks5Datagram(nil, "example.com", 53, []byte("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, payload, err := ParseSocks5Datagram(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "example.com" || port != 53 || !bytes.Equal(payload, []byte("query")) {
+		t.Errorf("unexpected datagram %s:%d %q", host, port, payload)
+	}
+
+	packet[2] = 1
+	if _, _, _, err = ParseSocks5Datagram(packet); err == nil {
+		t.Error("expected error for fragmented datagram")
+	}
+}
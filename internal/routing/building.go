@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Build substitutes the parameters into the pattern and returns the raw unescaped path.
+//
+// Every parameter of the pattern is required and must match its regex,
+// parameters not defined by the pattern are rejected.
+func (rp *RoutePattern) Build(params map[string]string) (string, error) {
+	for name := range params {
+		if !slices.Contains(rp.ParamNames, name) {
+			return "", fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+
+	original := strings.TrimSuffix(rp.Original, "/")
+	var b strings.Builder
+	last := 0
+	for i, span := range findPlaceholders(original) {
+		name := rp.ParamNames[i]
+		value, has := params[name]
+		if !has {
+			return "", fmt.Errorf("missing parameter: %s", name)
+		}
+		if !rp.ParamRegex[i].MatchString(value) {
+			return "", fmt.Errorf("parameter %s does not match %s: %q", name, rp.ParamRegex[i], value)
+		}
+
+		b.WriteString(original[last:span[0]])
+		b.WriteString(value)
+		last = span[1]
+	}
+	b.WriteString(original[last:])
+
+	path := b.String()
+	if path == "" {
+		path = "/"
+	}
+	if !rp.Regex.MatchString(path) {
+		return "", fmt.Errorf("path %q does not match pattern %s", path, rp.Original)
+	}
+	return path, nil
+}
@@ -0,0 +1,42 @@
+package routing
+
+import "testing"
+
+func TestRoutePattern_Build(t *testing.T) {
+	tests := []struct {
+		pattern string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"/", nil, "/", false},
+		{"/users", nil, "/users", false},
+		{"/users/{id}", map[string]string{"id": "42"}, "/users/42", false},
+		{"/users/{id:\\d+}", map[string]string{"id": "42"}, "/users/42", false},
+		{"/users/{id:\\d+}", map[string]string{"id": "bob"}, "", true},
+		{"/users/{id}", map[string]string{"id": "a/b"}, "", true},
+		{"/users/{id}", map[string]string{"id": ""}, "", true},
+		{"/users/{id}", nil, "", true},
+		{"/users/{id}", map[string]string{"id": "1", "other": "2"}, "", true},
+		{"/files/{name}.txt", map[string]string{"name": "readme"}, "/files/readme.txt", false},
+		{"/static/{*}", map[string]string{"*": "css/app.css"}, "/static/css/app.css", false},
+		{"/static/{*}", map[string]string{"*": ""}, "/static/", false},
+		{"/posts/{year:\\d{4}}/{slug}", map[string]string{"year": "2024", "slug": "hello world"}, "/posts/2024/hello world", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			rp, err := ParseRoutePattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := rp.Build(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build(%v) error = %v, wantErr %v", tt.params, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Build(%v) = %q, want %q", tt.params, got, tt.want)
+			}
+		})
+	}
+}
@@ -13,6 +13,9 @@ type RoutePattern struct {
 	Regex      *regexp.Regexp
 	ParamNames []string
 	Depth      int
+
+	// ParamRegex contains anchored regex of every parameter in order of ParamNames
+	ParamRegex []*regexp.Regexp
 }
 
 // ParseRoutePattern converts a route template into a regex pattern and parameter names
@@ -37,6 +40,7 @@ func ParseRoutePattern(pattern string) (*RoutePattern, error) {
 	spans := findPlaceholders(normalized)
 
 	var paramNames []string
+	var paramExprs []string
 	var b strings.Builder
 	last := 0
 
@@ -69,6 +73,8 @@ func ParseRoutePattern(pattern string) (*RoutePattern, error) {
 			pattern = "[^/]+"
 		}
 
+		paramExprs = append(paramExprs, pattern)
+
 		b.WriteByte('(')
 		b.WriteString(pattern)
 		b.WriteByte(')')
@@ -94,11 +100,20 @@ func ParseRoutePattern(pattern string) (*RoutePattern, error) {
 	}
 	depth := strings.Count(pattern, "/")
 
+	paramRegex := make([]*regexp.Regexp, len(paramExprs))
+	for i, expr := range paramExprs {
+		paramRegex[i], err = regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex pattern of parameter %s: %w", paramNames[i], err)
+		}
+	}
+
 	return &RoutePattern{
 		Original:   pattern,
 		Regex:      compiledRegex,
 		ParamNames: paramNames,
 		Depth:      depth,
+		ParamRegex: paramRegex,
 	}, nil
 }
 
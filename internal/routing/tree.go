@@ -0,0 +1,296 @@
+package routing
+
+import (
+	"iter"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// Tree is a prefix tree of route patterns split by path segments.
+//
+// Static segments take priority over segments with regex constraints,
+// which take priority over plain parameters and wildcards.
+// Regex of the route pattern is checked only at the leaves.
+type Tree[T any] struct {
+	root node[T]
+}
+
+type leaf[T any] struct {
+	pattern *RoutePattern
+	value   T
+}
+
+type node[T any] struct {
+	static map[string]*node[T]
+
+	// patterned contains segments with regex constraints or partial placeholders
+	// such as "{id:\d+}" or "{name}.txt", in order of addition
+	patterned     map[string]*node[T]
+	patternedKeys []string
+
+	param *node[T]
+
+	// wildcard leaves match the rest of the path which can contain slashes
+	wildcard []leaf[T]
+
+	leaves []leaf[T]
+}
+
+// Add adds the value by the route pattern to the tree.
+func (t *Tree[T]) Add(pattern *RoutePattern, value T) {
+	n := &t.root
+	for _, segment := range splitPatternSegments(pattern.Original) {
+		switch segmentKind(segment) {
+		case segmentStatic:
+			if n.static == nil {
+				n.static = map[string]*node[T]{}
+			}
+			child, has := n.static[segment]
+			if !has {
+				child = &node[T]{}
+				n.static[segment] = child
+			}
+			n = child
+		case segmentPatterned:
+			if n.patterned == nil {
+				n.patterned = map[string]*node[T]{}
+			}
+			child, has := n.patterned[segment]
+			if !has {
+				child = &node[T]{}
+				n.patterned[segment] = child
+				n.patternedKeys = append(n.patternedKeys, segment)
+			}
+			n = child
+		case segmentParam:
+			if n.param == nil {
+				n.param = &node[T]{}
+			}
+			n = n.param
+		case segmentWildcard:
+			n.wildcard = append(n.wildcard, leaf[T]{pattern: pattern, value: value})
+			return
+		}
+	}
+	n.leaves = append(n.leaves, leaf[T]{pattern: pattern, value: value})
+}
+
+// Match finds the most specific value matching the path
+// and returns it with path parameters.
+func (t *Tree[T]) Match(path string) (T, iter.Seq2[string, string], bool) {
+	segments := splitPathSegments(path)
+	if found, params, ok := t.root.match(path, segments); ok {
+		return found.value, params, true
+	}
+	var zero T
+	return zero, nil, false
+}
+
+// MatchAll returns an iterator over all values matching the path
+// from the most specific one, path parameters are not collected.
+func (t *Tree[T]) MatchAll(path string) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.root.matchAll(path, splitPathSegments(path), yield)
+	}
+}
+
+// Values returns an iterator over all values of the tree.
+func (t *Tree[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.root.walk(yield)
+	}
+}
+
+func (n *node[T]) walk(yield func(T) bool) bool {
+	for _, leaves := range [][]leaf[T]{n.leaves, n.wildcard} {
+		for _, found := range leaves {
+			if !yield(found.value) {
+				return false
+			}
+		}
+	}
+	for _, child := range n.static {
+		if !child.walk(yield) {
+			return false
+		}
+	}
+	for _, key := range n.patternedKeys {
+		if !n.patterned[key].walk(yield) {
+			return false
+		}
+	}
+	return n.param == nil || n.param.walk(yield)
+}
+
+func (n *node[T]) match(path string, segments []string) (*leaf[T], iter.Seq2[string, string], bool) {
+	if len(segments) == 0 {
+		if found, params, ok := matchLeaves(n.leaves, path); ok {
+			return found, params, true
+		}
+	} else {
+		segment, rest := segments[0], segments[1:]
+		if child, has := n.static[segment]; has {
+			if found, params, ok := child.match(path, rest); ok {
+				return found, params, true
+			}
+		}
+		for _, key := range n.patternedKeys {
+			if found, params, ok := n.patterned[key].match(path, rest); ok {
+				return found, params, true
+			}
+		}
+		if n.param != nil && segment != "" {
+			if found, params, ok := n.param.match(path, rest); ok {
+				return found, params, true
+			}
+		}
+	}
+	return matchLeaves(n.wildcard, path)
+}
+
+func (n *node[T]) matchAll(path string, segments []string, yield func(T) bool) bool {
+	if len(segments) == 0 {
+		if !matchAllLeaves(n.leaves, path, yield) {
+			return false
+		}
+	} else {
+		segment, rest := segments[0], segments[1:]
+		if child, has := n.static[segment]; has && !child.matchAll(path, rest, yield) {
+			return false
+		}
+		for _, key := range n.patternedKeys {
+			if !n.patterned[key].matchAll(path, rest, yield) {
+				return false
+			}
+		}
+		if n.param != nil && segment != "" && !n.param.matchAll(path, rest, yield) {
+			return false
+		}
+	}
+	return matchAllLeaves(n.wildcard, path, yield)
+}
+
+func matchAllLeaves[T any](leaves []leaf[T], path string, yield func(T) bool) bool {
+	for _, found := range leaves {
+		if len(found.pattern.ParamNames) > 0 && !found.pattern.Regex.MatchString(path) {
+			continue
+		}
+		if !yield(found.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchLeaves[T any](leaves []leaf[T], path string) (*leaf[T], iter.Seq2[string, string], bool) {
+	for i := range leaves {
+		found := &leaves[i]
+		if len(found.pattern.ParamNames) == 0 {
+			return found, emptyParams, true
+		}
+		if ok, params := found.pattern.Match(path); ok {
+			return found, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+func emptyParams(func(string, string) bool) {}
+
+type segmentType int
+
+const (
+	segmentStatic segmentType = iota
+	segmentPatterned
+	segmentParam
+	segmentWildcard
+)
+
+// segmentKind returns the type of the pattern segment by its placeholders.
+func segmentKind(segment string) segmentType {
+	spans := findPlaceholders(segment)
+	if len(spans) == 0 {
+		return segmentStatic
+	}
+
+	for _, span := range spans {
+		name, expr, hasExpr := strings.Cut(segment[span[0]+1:span[1]-1], ":")
+		if strings.TrimSpace(name) == "*" || hasExpr && canMatchSlash(expr) {
+			return segmentWildcard
+		}
+	}
+
+	if len(spans) == 1 && spans[0] == [2]int{0, len(segment)} && !strings.Contains(segment, ":") {
+		return segmentParam
+	}
+	return segmentPatterned
+}
+
+// splitPatternSegments splits the pattern by slashes outside of placeholders.
+func splitPatternSegments(pattern string) []string {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return nil
+	}
+
+	var segments []string
+	var depth, start int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+func splitPathSegments(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// canMatchSlash checks the regex of the placeholder can match a slash
+// and therefore can span several path segments.
+func canMatchSlash(expr string) bool {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return true
+	}
+	return regexpMatchesRune(re, '/')
+}
+
+func regexpMatchesRune(re *syntax.Regexp, r rune) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpLiteral:
+		return slices.Contains(re.Rune, r)
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= r && r <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sub := range re.Sub {
+		if regexpMatchesRune(sub, r) {
+			return true
+		}
+	}
+	return false
+}
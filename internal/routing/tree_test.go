@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestTree_Match(t *testing.T) {
+	patterns := []string{
+		"/",
+		"/static/{*}",
+		"/users/{id}",
+		"/users/{id:\\d+}",
+		"/users/me",
+		"/users/{id}/posts/{postId}",
+		"/users/{id}/posts/latest",
+		"/files/{name}.txt",
+		"/files/{name}",
+		"/assets/{path:.+\\.css}",
+		"/{lang:[a-z]{2}}/about",
+		"/{page}/about",
+		"/{*:.*\\.(png|jpg)}",
+	}
+
+	var tree Tree[string]
+	for _, pattern := range patterns {
+		rp, err := ParseRoutePattern(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Add(rp, pattern)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		params map[string]string
+	}{
+		{"/", "/", map[string]string{}},
+		{"/users/me", "/users/me", map[string]string{}},
+		{"/users/me/", "/users/me", map[string]string{}},
+		{"/users/42", "/users/{id:\\d+}", map[string]string{"id": "42"}},
+		{"/users/bob", "/users/{id}", map[string]string{"id": "bob"}},
+		{"/users/bob/posts/latest", "/users/{id}/posts/latest", map[string]string{"id": "bob"}},
+		{"/users/bob/posts/7", "/users/{id}/posts/{postId}", map[string]string{"id": "bob", "postId": "7"}},
+		{"/files/readme.txt", "/files/{name}.txt", map[string]string{"name": "readme"}},
+		{"/files/readme.md", "/files/{name}", map[string]string{"name": "readme.md"}},
+		{"/assets/css/main.css", "/assets/{path:.+\\.css}", map[string]string{"path": "css/main.css"}},
+		{"/en/about", "/{lang:[a-z]{2}}/about", map[string]string{"lang": "en"}},
+		{"/company/about", "/{page}/about", map[string]string{"page": "company"}},
+		{"/static/js/app.js", "/static/{*}", map[string]string{"*": "js/app.js"}},
+		{"/static/", "/static/{*}", map[string]string{"*": ""}},
+		{"/images/photo.png", "/{*:.*\\.(png|jpg)}", map[string]string{"*": "images/photo.png"}},
+		{"/users/photo.png", "/users/{id}", map[string]string{"id": "photo.png"}},
+		{"/users", "", nil},
+		{"/users//posts/1", "", nil},
+		{"/assets/main.js", "", nil},
+		{"/static", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, params, ok := tree.Match(tt.path)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Fatalf("Match(%q) = %q, %v, want %q", tt.path, got, ok, tt.want)
+			}
+			if ok {
+				if collected := maps.Collect(params); !maps.Equal(collected, tt.params) {
+					t.Errorf("Match(%q) params = %v, want %v", tt.path, collected, tt.params)
+				}
+			}
+		})
+	}
+}
+
+func TestTree_ManyRoutes(t *testing.T) {
+	var tree Tree[int]
+	for i := 0; i < 600; i++ {
+		rp, err := ParseRoutePattern(fmt.Sprintf("/api/v1/resource%d/{id:\\d+}/items", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Add(rp, i)
+	}
+
+	if count := len(slices.Collect(tree.Values())); count != 600 {
+		t.Errorf("Values() count = %d, want 600", count)
+	}
+
+	got, params, ok := tree.Match("/api/v1/resource599/12/items")
+	if !ok || got != 599 {
+		t.Fatalf("Match() = %d, %v, want 599", got, ok)
+	}
+	if collected := maps.Collect(params); collected["id"] != "12" {
+		t.Errorf("Match() params = %v", collected)
+	}
+
+	if _, _, ok = tree.Match("/api/v1/resource599/abc/items"); ok {
+		t.Error("expected regex constraint to reject the path")
+	}
+}
+
+func TestTree_MatchAll(t *testing.T) {
+	var tree Tree[string]
+	for _, pattern := range []string{
+		"/files/latest",
+		"/files/{name:\\d+}",
+		"/files/{name}",
+		"/files/{*}",
+		"/other/{name}",
+	} {
+		rp, err := ParseRoutePattern(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Add(rp, pattern)
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/files/latest", []string{"/files/latest", "/files/{name}", "/files/{*}"}},
+		{"/files/12", []string{"/files/{name:\\d+}", "/files/{name}", "/files/{*}"}},
+		{"/files/a/b", []string{"/files/{*}"}},
+		{"/missing", nil},
+	}
+	for _, tt := range tests {
+		if got := slices.Collect(tree.MatchAll(tt.path)); !slices.Equal(got, tt.want) {
+			t.Errorf("MatchAll(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -55,3 +55,17 @@ func (req *HttpRequest) Header() *specs.Header {
 func (req *HttpRequest) Body() io.Reader {
 	return req.BodyReader
 }
+
+func NewHttpRequest(
+	protoMajor, protoMinor uint16, remoteAddr net.Addr,
+	method specs.HttpMethod, url *specs.Url, header *specs.Header,
+) *HttpRequest {
+	return &HttpRequest{
+		method:     method,
+		protoMajor: protoMajor,
+		protoMinor: protoMinor,
+		remoteAddr: remoteAddr,
+		url:        url,
+		header:     header,
+	}
+}
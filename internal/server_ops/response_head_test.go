@@ -93,6 +93,26 @@ func TestWriteResponseHead(t *testing.T) {
 				"Set-Cookie: emptycookie=",
 			}, "\r\n") + "\r\n\r\n",
 		},
+		{
+			name: "HTTP/1.1 with multi-valued headers",
+			is11: true,
+			code: specs.StatusCodeOK,
+			header: specs.NewHeader(func(header *specs.Header) {
+				header.Add("Vary", "Accept-Encoding")
+				header.Set("Content-Type", "text/html")
+				header.Add("Vary", "Origin")
+				header.Add("Link", "</style.css>; rel=preload")
+				header.Add("Link", "</script.js>; rel=preload")
+			}),
+			expected: strings.Join([]string{
+				"HTTP/1.1 200 OK",
+				"Content-Type: text/html",
+				"Link: </style.css>; rel=preload",
+				"Link: </script.js>; rel=preload",
+				"Vary: Accept-Encoding",
+				"Vary: Origin",
+			}, "\r\n") + "\r\n\r\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -48,11 +48,24 @@ func PrefixRouter(prefix string, configure ...func(router RouterBuilder)) Router
 }
 
 type routerBuilder struct {
-	prefix string
-	routes []*routeBuilder
+	prefix      string
+	routes      []*routeBuilder
+	middlewares []Middleware
+}
+
+func (rb *routerBuilder) Use(md Middleware) RouterBuilder {
+	if md == nil {
+		panic("plow: nil Middleware")
+	}
+	rb.middlewares = append(rb.middlewares, md)
+	return rb
 }
 
 func (rb *routerBuilder) Route(method specs.HttpMethod, pattern string, handler plow.Handler, flags ...any) RouteBuilder {
+	return rb.route(method, pattern, handler, flags, nil)
+}
+
+func (rb *routerBuilder) route(method specs.HttpMethod, pattern string, handler plow.Handler, flags []any, middlewares []Middleware) *routeBuilder {
 	if pattern == "" {
 		panic("plow: route pattern must have at least one character")
 	}
@@ -80,10 +93,12 @@ func (rb *routerBuilder) Route(method specs.HttpMethod, pattern string, handler
 	}
 
 	builder := &routeBuilder{
-		method:  method,
-		pattern: pattern,
-		handler: handler,
-		flags:   flags,
+		router:      rb,
+		method:      method,
+		pattern:     pattern,
+		handler:     handler,
+		flags:       flags,
+		middlewares: middlewares,
 	}
 	rb.routes = append(rb.routes, builder)
 	return builder
@@ -94,7 +109,7 @@ func (rb *routerBuilder) Include(other RouterBuilder) RouterBuilder {
 		panic("plow: router builder cannot include self")
 	}
 	for rt := range other.Routes() {
-		rb.Route(rt.Method(), rt.Pattern(), rt.Handler(), slices.Collect(rt.Flags())...)
+		rb.route(rt.Method(), rt.Pattern(), rt.Handler(), slices.Collect(rt.Flags()), slices.Collect(rt.Middlewares()))
 	}
 	return rb
 }
@@ -110,10 +125,15 @@ func (rb *routerBuilder) Routes() iter.Seq[Route] {
 }
 
 type routeBuilder struct {
+	router  *routerBuilder
 	method  specs.HttpMethod
 	pattern string
 	handler plow.Handler
 	flags   []any
+
+	// middlewares of the route itself or of the included routers,
+	// middlewares of the router are prepended on listing
+	middlewares []Middleware
 }
 
 func (rb *routeBuilder) Method() specs.HttpMethod {
@@ -136,3 +156,28 @@ func (rb *routeBuilder) AddFlag(flags ...any) RouteBuilder {
 	rb.flags = append(rb.flags, flags...)
 	return rb
 }
+
+func (rb *routeBuilder) Name(name string) RouteBuilder {
+	if name == "" {
+		panic("plow: empty route name")
+	}
+	return rb.AddFlag(RouteName(name))
+}
+
+func (rb *routeBuilder) Middlewares() iter.Seq[Middleware] {
+	return func(yield func(Middleware) bool) {
+		for _, md := range slices.Concat(rb.router.middlewares, rb.middlewares) {
+			if !yield(md) {
+				break
+			}
+		}
+	}
+}
+
+func (rb *routeBuilder) Use(md Middleware) RouteBuilder {
+	if md == nil {
+		panic("plow: nil Middleware")
+	}
+	rb.middlewares = append(rb.middlewares, md)
+	return rb
+}
@@ -0,0 +1,395 @@
+package mux
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/plain"
+	"github.com/oesand/plow/specs"
+)
+
+// DefaultFileServer returns a new FileServer of the file system
+// with index file "index.html" and pre-compressed files enabled,
+// [embed.FS] is served as immutable.
+func DefaultFileServer(fsys fs.FS) *FileServer {
+	_, immutable := fsys.(embed.FS)
+	return &FileServer{
+		FS:                  fsys,
+		IndexFile:           "index.html",
+		EnablePrecompressed: true,
+		Immutable:           immutable,
+	}
+}
+
+// FileServer is a [plow.Handler] that serves files of the file system.
+//
+// The file path is taken from the wildcard parameter of the route
+// such as "/static/{*:.*}" or from the whole request path otherwise.
+// Paths with ".." segments are rejected, so files outside the file system root
+// are never served.
+//
+// Content-Type is inferred by [specs.ContentTypeByExtension],
+// conditional requests with "If-None-Match" and "If-Modified-Since" headers
+// and byte range requests are supported by [plow.RangeResponse].
+type FileServer struct {
+	_ internal.NoCopy
+
+	// FS is the file system to serve files from.
+	FS fs.FS
+
+	// IndexFile is the name of the file served for directory requests,
+	// if empty or not found, the directory is listed or not found.
+	IndexFile string
+
+	// EnableListing enables HTML listing of directories without index file.
+	EnableListing bool
+
+	// EnablePrecompressed enables serving of pre-compressed siblings,
+	// such as "app.js.br" or "app.js.gz" for "app.js", when the client accepts the encoding.
+	// The server does not encode the response again.
+	EnablePrecompressed bool
+
+	// Immutable specifies files of the file system are not changed while served,
+	// so content hashes of files without modification times are computed once.
+	// Otherwise files are hashed on every request to tag changed content correctly.
+	Immutable bool
+
+	// hashTags memoizes content hashes of files without modification times by name and size
+	hashTags sync.Map
+}
+
+type hashTagKey struct {
+	name string
+	size int64
+}
+
+// precompressedExtensions in the order of preference
+var precompressedExtensions = []struct {
+	encoding  string
+	extension string
+}{
+	{specs.ContentEncodingBrotli, ".br"},
+	{specs.ContentEncodingGzip, ".gz"},
+}
+
+// Handle implements the [plow.Handler] interface.
+func (fsrv *FileServer) Handle(ctx context.Context, request plow.Request) plow.Response {
+	if fsrv.FS == nil {
+		panic("plow: nil FileServer.FS")
+	}
+
+	method := request.Method()
+	if method != specs.HttpMethodGet && method != specs.HttpMethodHead {
+		return plow.EmptyResponse(specs.StatusCodeMethodNotAllowed, func(resp plow.Response) {
+			resp.Header().Set("Allow", "GET, HEAD")
+		})
+	}
+
+	url := request.Url()
+	reqPath, isParam := PathParams(ctx).TryGet("*")
+	if !isParam {
+		reqPath = url.Path
+	}
+
+	name, ok := cleanFilePath(reqPath)
+	if !ok {
+		return plow.TextResponse(specs.StatusCodeBadRequest, specs.ContentTypePlain, "invalid file path")
+	}
+
+	info, err := fs.Stat(fsrv.FS, name)
+	if err != nil {
+		return fileErrorResponse(err)
+	}
+
+	if info.IsDir() {
+		// Relative links of the directory page require trailing slash
+		if !strings.HasSuffix(url.Path, "/") {
+			location := url.EscapedPath() + "/"
+			if url.Query.Any() {
+				location += "?" + url.Query.String()
+			}
+			return plow.PermanentRedirectResponse(location)
+		}
+
+		if fsrv.IndexFile != "" {
+			indexName := path.Join(name, fsrv.IndexFile)
+			if indexInfo, err := fs.Stat(fsrv.FS, indexName); err == nil && indexInfo.Mode().IsRegular() {
+				return fsrv.serveFile(request, indexName, indexInfo)
+			}
+		}
+
+		if fsrv.EnableListing {
+			return fsrv.serveDir(url.Path, name)
+		}
+		return fileErrorResponse(fs.ErrNotExist)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fileErrorResponse(fs.ErrNotExist)
+	}
+	return fsrv.serveFile(request, name, info)
+}
+
+// cleanFilePath converts request path into the name of the file system,
+// it reports false if the path tries to escape the root.
+func cleanFilePath(reqPath string) (string, bool) {
+	if strings.ContainsAny(reqPath, "\\\x00") {
+		return "", false
+	}
+	if slices.Contains(strings.Split(reqPath, "/"), "..") {
+		return "", false
+	}
+
+	name := strings.Trim(path.Clean("/"+reqPath), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func fileErrorResponse(err error) plow.Response {
+	if errors.Is(err, fs.ErrPermission) {
+		return plow.TextResponse(specs.StatusCodeForbidden, specs.ContentTypePlain, "access denied")
+	}
+	return plow.TextResponse(specs.StatusCodeNotFound, specs.ContentTypePlain, "file not found")
+}
+
+func (fsrv *FileServer) serveFile(request plow.Request, name string, info fs.FileInfo) plow.Response {
+	header := request.Header()
+	contentType := specs.ContentTypeByExtension(path.Ext(name))
+
+	fileName, size, modTime := name, info.Size(), info.ModTime()
+	var contentEncoding string
+
+	// Ranges are served only from the identity content
+	if fsrv.EnablePrecompressed && !header.Has("Range") {
+		for _, variant := range precompressedExtensions {
+			if !acceptsEncoding(header, variant.encoding) {
+				continue
+			}
+			variantInfo, err := fs.Stat(fsrv.FS, name+variant.extension)
+			if err != nil || !variantInfo.Mode().IsRegular() {
+				continue
+			}
+			fileName, size, contentEncoding = name+variant.extension, variantInfo.Size(), variant.encoding
+			break
+		}
+	}
+
+	etag, err := fsrv.fileETag(fileName, size, modTime, contentEncoding)
+	if err != nil {
+		return fileErrorResponse(err)
+	}
+
+	configure := func(resp plow.Response) {
+		respHeader := resp.Header()
+		respHeader.Set("ETag", etag)
+		if !modTime.IsZero() {
+			respHeader.Set("Last-Modified", modTime.UTC().Format(specs.TimeFormat))
+		}
+		if fsrv.EnablePrecompressed {
+			respHeader.Set("Vary", "Accept-Encoding")
+		}
+	}
+
+	if isNotModified(header, etag, modTime) {
+		return plow.EmptyResponse(specs.StatusCodeNotModified, configure)
+	}
+
+	file := &lazyFile{fsys: fsrv.FS, name: fileName, size: size}
+	return plow.RangeResponse(request, contentType, file, func(resp plow.Response) {
+		configure(resp)
+		if contentEncoding != "" {
+			resp.Header().Set("Content-Encoding", contentEncoding)
+		}
+	})
+}
+
+// fileETag returns strong entity tag of the file by its modification time and size,
+// file systems without modification times, such as [embed.FS], are tagged by content hash
+// which is computed once for the name and size of the file if the file system is immutable.
+func (fsrv *FileServer) fileETag(name string, size int64, modTime time.Time, contentEncoding string) (string, error) {
+	var tag string
+	if !modTime.IsZero() {
+		tag = strconv.FormatInt(modTime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16)
+	} else if cached, ok := fsrv.hashTags.Load(hashTagKey{name, size}); fsrv.Immutable && ok {
+		tag = cached.(string)
+	} else {
+		file, err := fsrv.FS.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		hash := fnv.New64a()
+		if _, err = io.Copy(hash, file); err != nil {
+			return "", err
+		}
+		tag = strconv.FormatUint(hash.Sum64(), 16)
+		if fsrv.Immutable {
+			fsrv.hashTags.Store(hashTagKey{name, size}, tag)
+		}
+	}
+
+	if contentEncoding != "" {
+		tag += "-" + contentEncoding
+	}
+	return "\"" + tag + "\"", nil
+}
+
+// isNotModified evaluates conditional request headers, RFC 9110 section 13.2.2.
+func isNotModified(header *specs.Header, etag string, modTime time.Time) bool {
+	if ifNoneMatch, has := header.TryGet("If-None-Match"); has {
+		return matchETag(ifNoneMatch, etag)
+	}
+	if ifModifiedSince, has := header.TryGet("If-Modified-Since"); has && !modTime.IsZero() {
+		since, err := time.Parse(specs.TimeFormat, ifModifiedSince)
+		return err == nil && !modTime.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// matchETag reports whether the list of "If-None-Match" header matches the tag,
+// weak comparison is used.
+func matchETag(list, etag string) bool {
+	for candidate := range strings.SplitSeq(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding reports whether "Accept-Encoding" header allows the encoding.
+func acceptsEncoding(header *specs.Header, encoding string) bool {
+	for _, value := range header.Values("Accept-Encoding") {
+		for part := range strings.SplitSeq(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+				continue
+			}
+			q, hasQ := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !hasQ {
+				return true
+			}
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+	}
+	return false
+}
+
+func (fsrv *FileServer) serveDir(urlPath, name string) plow.Response {
+	entries, err := fs.ReadDir(fsrv.FS, name)
+	if err != nil {
+		return fileErrorResponse(err)
+	}
+
+	var buf strings.Builder
+	title := html.EscapeString(urlPath)
+	buf.WriteString("<!doctype html>\n<meta charset=\"utf-8\">\n")
+	buf.WriteString("<title>Index of " + title + "</title>\n")
+	buf.WriteString("<h1>Index of " + title + "</h1>\n<pre>\n")
+	if name != "." {
+		buf.WriteString("<a href=\"../\">../</a>\n")
+	}
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := plain.EscapeUrl(entry.Name(), plain.EscapingPathSegment)
+		if entry.IsDir() {
+			href += "/"
+		}
+		buf.WriteString("<a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(entryName) + "</a>\n")
+	}
+	buf.WriteString("</pre>\n")
+
+	return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypeHTML+"; charset=utf-8", buf.String())
+}
+
+// lazyFile opens the file only when the content is read,
+// so the file is not left open when the body is never sent.
+type lazyFile struct {
+	fsys   fs.FS
+	name   string
+	size   int64
+	offset int64
+
+	file fs.File
+	pos  int64
+}
+
+func (f *lazyFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative file position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *lazyFile) Read(p []byte) (int, error) {
+	if f.file != nil && f.pos != f.offset {
+		if seeker, ok := f.file.(io.Seeker); ok {
+			if _, err := seeker.Seek(f.offset, io.SeekStart); err != nil {
+				return 0, err
+			}
+			f.pos = f.offset
+		} else if f.offset < f.pos {
+			// Files without seeking are read again from the start
+			f.file.Close()
+			f.file = nil
+		}
+	}
+
+	if f.file == nil {
+		file, err := f.fsys.Open(f.name)
+		if err != nil {
+			return 0, err
+		}
+		f.file, f.pos = file, 0
+	}
+
+	if f.pos < f.offset {
+		skipped, err := io.CopyN(io.Discard, f.file, f.offset-f.pos)
+		f.pos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+	return n, err
+}
+
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
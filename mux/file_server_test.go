@@ -0,0 +1,305 @@
+package mux
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/mock"
+	"github.com/oesand/plow/specs"
+)
+
+var testModTime = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestFileServer() *FileServer {
+	fsrv := DefaultFileServer(fstest.MapFS{
+		"index.html":       {Data: []byte("<h1>home</h1>"), ModTime: testModTime},
+		"css/app.css":      {Data: []byte("body{}"), ModTime: testModTime},
+		"js/app.js":        {Data: []byte("console.log('plain')"), ModTime: testModTime},
+		"js/app.js.gz":     {Data: []byte("gzipped"), ModTime: testModTime},
+		"js/app.js.br":     {Data: []byte("brotli"), ModTime: testModTime},
+		"data/file.bin":    {Data: []byte("0123456789"), ModTime: testModTime},
+		"data/<b>&amp.txt": {Data: []byte("escaped"), ModTime: testModTime},
+		"embed.txt":        {Data: []byte("no mod time")},
+	})
+	fsrv.EnableListing = true
+	return fsrv
+}
+
+func serveTestFile(fsrv *FileServer, method specs.HttpMethod, path string, configure func(header *specs.Header)) plow.Response {
+	builder := mock.DefaultRequest().Method(method).Url(specs.MustParseUrl(path))
+	if configure != nil {
+		builder.ConfHeader(configure)
+	}
+	return fsrv.Handle(context.Background(), builder.Request())
+}
+
+func readTestBody(t *testing.T, resp plow.Response) string {
+	t.Helper()
+	writer, ok := resp.(plow.BodyWriter)
+	if !ok {
+		t.Fatal("response has no body")
+	}
+	var buf strings.Builder
+	if err := writer.WriteBody(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestFileServer_ServeFile(t *testing.T) {
+	fsrv := newTestFileServer()
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/css/app.css", nil)
+	if resp.StatusCode() != specs.StatusCodeOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode())
+	}
+	if resp.Header().Get("Content-Type") != specs.ContentTypeCSS ||
+		resp.Header().Get("Content-Length") != "6" ||
+		resp.Header().Get("Last-Modified") != testModTime.Format(specs.TimeFormat) ||
+		resp.Header().Get("ETag") == "" {
+		t.Errorf("unexpected headers %+v", resp.Header())
+	}
+	if body := readTestBody(t, resp); body != "body{}" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodHead, "/css/app.css", nil)
+	if _, ok := resp.(plow.BodyWriter); ok || resp.Header().Get("Content-Length") != "6" {
+		t.Errorf("expected head response without body, %+v", resp.Header())
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodPost, "/css/app.css", nil)
+	if resp.StatusCode() != specs.StatusCodeMethodNotAllowed || resp.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("expected method not allowed, got %d", resp.StatusCode())
+	}
+}
+
+func TestFileServer_WildcardParam(t *testing.T) {
+	mx := New().Route(specs.HttpMethodGet, "/static/{*:.*}", newTestFileServer())
+
+	req := mock.DefaultRequest().Url(specs.MustParseUrl("/static/css/app.css")).Request()
+	resp := mx.Handle(context.Background(), req)
+	if resp.StatusCode() != specs.StatusCodeOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode())
+	}
+	if body := readTestBody(t, resp); body != "body{}" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestFileServer_PathTraversal(t *testing.T) {
+	fsrv := newTestFileServer()
+	for _, path := range []string{"/../secret", "/css/../../secret", "/css/..%5Capp.css"} {
+		resp := serveTestFile(fsrv, specs.HttpMethodGet, path, nil)
+		if resp.StatusCode() != specs.StatusCodeBadRequest {
+			t.Errorf("expected bad request for %s, got %d", path, resp.StatusCode())
+		}
+	}
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/missing.txt", nil)
+	if resp.StatusCode() != specs.StatusCodeNotFound {
+		t.Errorf("expected not found, got %d", resp.StatusCode())
+	}
+}
+
+func TestFileServer_Directory(t *testing.T) {
+	fsrv := newTestFileServer()
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/", nil)
+	if body := readTestBody(t, resp); body != "<h1>home</h1>" {
+		t.Errorf("expected index file, got %q", body)
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/data", nil)
+	if resp.StatusCode() != specs.StatusCodePermanentRedirect || resp.Header().Get("Location") != "/data/" {
+		t.Errorf("expected redirect to trailing slash, got %d %+v", resp.StatusCode(), resp.Header())
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/data/", nil)
+	body := readTestBody(t, resp)
+	if !strings.Contains(body, `<a href="file.bin">file.bin</a>`) ||
+		!strings.Contains(body, `<a href="%3Cb%3E&amp;amp.txt">&lt;b&gt;&amp;amp.txt</a>`) ||
+		!strings.Contains(body, `<a href="../">../</a>`) {
+		t.Errorf("unexpected listing %s", body)
+	}
+
+	fsrv.EnableListing = false
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/data/", nil)
+	if resp.StatusCode() != specs.StatusCodeNotFound {
+		t.Errorf("expected not found without listing, got %d", resp.StatusCode())
+	}
+}
+
+func TestFileServer_Conditional(t *testing.T) {
+	fsrv := newTestFileServer()
+	etag := serveTestFile(fsrv, specs.HttpMethodGet, "/css/app.css", nil).Header().Get("ETag")
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/css/app.css", func(header *specs.Header) {
+		header.Set("If-None-Match", `"other", `+etag)
+	})
+	if resp.StatusCode() != specs.StatusCodeNotModified {
+		t.Errorf("expected not modified by etag, got %d", resp.StatusCode())
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/css/app.css", func(header *specs.Header) {
+		header.Set("If-Modified-Since", testModTime.Add(time.Hour).Format(specs.TimeFormat))
+	})
+	if resp.StatusCode() != specs.StatusCodeNotModified {
+		t.Errorf("expected not modified by date, got %d", resp.StatusCode())
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/css/app.css", func(header *specs.Header) {
+		header.Set("If-Modified-Since", testModTime.Add(-time.Hour).Format(specs.TimeFormat))
+	})
+	if resp.StatusCode() != specs.StatusCodeOK {
+		t.Errorf("expected modified, got %d", resp.StatusCode())
+	}
+
+	embedTag := serveTestFile(fsrv, specs.HttpMethodGet, "/embed.txt", nil).Header().Get("ETag")
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/embed.txt", func(header *specs.Header) {
+		header.Set("If-None-Match", embedTag)
+	})
+	if embedTag == "" || resp.StatusCode() != specs.StatusCodeNotModified {
+		t.Errorf("expected not modified by content hash, got %d", resp.StatusCode())
+	}
+}
+
+type countingFS struct {
+	fstest.MapFS
+	opens map[string]int
+}
+
+func (fsys *countingFS) Open(name string) (fs.File, error) {
+	fsys.opens[name]++
+	return fsys.MapFS.Open(name)
+}
+
+func TestFileServer_HashTagMemoized(t *testing.T) {
+	fsys := &countingFS{
+		MapFS: fstest.MapFS{"embed.txt": {Data: []byte("no mod time")}},
+		opens: map[string]int{},
+	}
+	fsrv := DefaultFileServer(fsys)
+	fsrv.Immutable = true
+
+	etag := serveTestFile(fsrv, specs.HttpMethodGet, "/embed.txt", nil).Header().Get("ETag")
+	opens := fsys.opens["embed.txt"]
+	for range 3 {
+		resp := serveTestFile(fsrv, specs.HttpMethodGet, "/embed.txt", func(header *specs.Header) {
+			header.Set("If-None-Match", etag)
+		})
+		if resp.StatusCode() != specs.StatusCodeNotModified {
+			t.Fatalf("expected not modified, got %d", resp.StatusCode())
+		}
+	}
+	if fsys.opens["embed.txt"] != opens {
+		t.Errorf("expected content hash to be computed once, file opened %d times", fsys.opens["embed.txt"])
+	}
+
+	fsys.MapFS["embed.txt"] = &fstest.MapFile{Data: []byte("changed content")}
+	if changed := serveTestFile(fsrv, specs.HttpMethodGet, "/embed.txt", nil).Header().Get("ETag"); changed == etag {
+		t.Errorf("expected new etag for file of other size, got %s", changed)
+	}
+}
+
+func TestFileServer_HashTagMutable(t *testing.T) {
+	fsys := fstest.MapFS{"data.txt": {Data: []byte("version 1")}}
+	fsrv := DefaultFileServer(fsys)
+	if fsrv.Immutable {
+		t.Fatal("expected mutable file system")
+	}
+
+	etag := serveTestFile(fsrv, specs.HttpMethodGet, "/data.txt", nil).Header().Get("ETag")
+	fsys["data.txt"] = &fstest.MapFile{Data: []byte("version 2")}
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/data.txt", func(header *specs.Header) {
+		header.Set("If-None-Match", etag)
+	})
+	if resp.StatusCode() != specs.StatusCodeOK || resp.Header().Get("ETag") == etag {
+		t.Errorf("expected changed content of the same size to be served with new etag, got %d %s",
+			resp.StatusCode(), resp.Header().Get("ETag"))
+	}
+}
+
+func TestFileServer_Range(t *testing.T) {
+	fsrv := newTestFileServer()
+
+	tests := []struct {
+		rangeHeader  string
+		code         specs.StatusCode
+		contentRange string
+		body         string
+	}{
+		{"bytes=2-5", specs.StatusCodePartialContent, "bytes 2-5/10", "2345"},
+		{"bytes=7-", specs.StatusCodePartialContent, "bytes 7-9/10", "789"},
+		{"bytes=-3", specs.StatusCodePartialContent, "bytes 7-9/10", "789"},
+		{"bytes=8-100", specs.StatusCodePartialContent, "bytes 8-9/10", "89"},
+		{"items=0-1", specs.StatusCodeOK, "", "0123456789"},
+		{"bytes=20-", specs.StatusCodeRequestedRangeNotSatisfiable, "bytes */10", ""},
+	}
+	for _, tt := range tests {
+		resp := serveTestFile(fsrv, specs.HttpMethodGet, "/data/file.bin", func(header *specs.Header) {
+			header.Set("Range", tt.rangeHeader)
+		})
+		if resp.StatusCode() != tt.code || resp.Header().Get("Content-Range") != tt.contentRange {
+			t.Errorf("%s: unexpected response %d %+v", tt.rangeHeader, resp.StatusCode(), resp.Header())
+			continue
+		}
+		if tt.body != "" {
+			if body := readTestBody(t, resp); body != tt.body {
+				t.Errorf("%s: expected body %q, got %q", tt.rangeHeader, tt.body, body)
+			}
+		}
+	}
+
+	resp := serveTestFile(fsrv, specs.HttpMethodGet, "/data/file.bin", func(header *specs.Header) {
+		header.Set("Range", "bytes=0-1,4-5")
+	})
+	if resp.StatusCode() != specs.StatusCodePartialContent ||
+		!strings.HasPrefix(resp.Header().Get("Content-Type"), specs.ContentTypeMultipartByteranges) {
+		t.Errorf("expected multipart byteranges, got %d %+v", resp.StatusCode(), resp.Header())
+	}
+
+	resp = serveTestFile(fsrv, specs.HttpMethodGet, "/data/file.bin", func(header *specs.Header) {
+		header.Set("Range", "bytes=0-1")
+		header.Set("If-Range", `"stale"`)
+	})
+	if resp.StatusCode() != specs.StatusCodeOK {
+		t.Errorf("expected full content for stale If-Range, got %d", resp.StatusCode())
+	}
+}
+
+func TestFileServer_Precompressed(t *testing.T) {
+	fsrv := newTestFileServer()
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"gzip, br", specs.ContentEncodingBrotli, "brotli"},
+		{"gzip", specs.ContentEncodingGzip, "gzipped"},
+		{"br;q=0, gzip", specs.ContentEncodingGzip, "gzipped"},
+		{"", "", "console.log('plain')"},
+	}
+	for _, tt := range tests {
+		resp := serveTestFile(fsrv, specs.HttpMethodGet, "/js/app.js", func(header *specs.Header) {
+			if tt.acceptEncoding != "" {
+				header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+		})
+		if resp.Header().Get("Content-Encoding") != tt.encoding ||
+			resp.Header().Get("Content-Type") != specs.ContentTypeJavaScript ||
+			resp.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: unexpected headers %+v", tt.acceptEncoding, resp.Header())
+		}
+		if body := readTestBody(t, resp); body != tt.body {
+			t.Errorf("%q: expected body %q, got %q", tt.acceptEncoding, tt.body, body)
+		}
+	}
+}
@@ -29,6 +29,11 @@ func (f MiddlewareFunc) Intercept(ctx context.Context, request plow.Request, nex
 // RouterBuilder provides an interface for building and configuring route collections.
 // It allows adding routes, including other routers, and retrieving all configured routes.
 type RouterBuilder interface {
+	// Use adds a middleware to all routes of the router, including routes
+	// of the included routers. Router middlewares run after the [Mux] middlewares
+	// and before middlewares of the included routers and of the route itself.
+	Use(middleware Middleware) RouterBuilder
+
 	// Route adds a new route to the router with the specified HTTP method,
 	// URL pattern, handler function, and optional flags.
 	// Returns a RouteBuilder for further configuration of the route.
@@ -52,6 +57,14 @@ type RouteBuilder interface {
 	// AddFlag adds one or more flags to the route for additional configuration.
 	// Flags can be used to modify route behavior or provide metadata.
 	AddFlag(flags ...any) RouteBuilder
+
+	// Name names the route with the [RouteName] flag,
+	// so its URL can be built by [Mux.URL].
+	Name(name string) RouteBuilder
+
+	// Use adds a middleware to the route, which runs after middlewares
+	// of the [Mux] and of the routers containing the route.
+	Use(middleware Middleware) RouteBuilder
 }
 
 // Route represents a configured HTTP route with its method, path pattern, handler, and flags.
@@ -69,6 +82,10 @@ type Route interface {
 	// Flags returns an iterator over all flags associated with this route.
 	// Flags can provide additional configuration or metadata for the route.
 	Flags() iter.Seq[any]
+
+	// Middlewares returns an iterator over middlewares of the route
+	// in order of execution, including middlewares of the routers containing it.
+	Middlewares() iter.Seq[Middleware]
 }
 
 // Mux is the main multiplexer interface that combines routing, middleware support,
@@ -93,6 +110,9 @@ type Mux interface {
 	// Everything outside {…} is safely regex-escaped
 	// Trailing slash is ignored at compile-time; both /path and /path/ are accepted at match-time
 	// Wildcard parameters (*) can match any characters including slashes
+	//
+	// The most specific route matches the request: static segments take priority
+	// over parameters with regex, which take priority over plain parameters and wildcards
 	Route(method specs.HttpMethod, pattern string, handler plow.Handler, flags ...any) Mux
 
 	// Include incorporates all routes from a RouterBuilder into this mux.
@@ -103,6 +123,22 @@ type Mux interface {
 	// If not set, a default 404 response is returned.
 	NotFoundHandler(handler plow.Handler) Mux
 
+	// MethodNotAllowedHandler sets a custom handler for requests which path matches
+	// routes of other methods only. If not set, a default 405 response is returned.
+	// The "Allow" header with methods of the matching routes is added to the response.
+	//
+	// OPTIONS requests to such paths are answered with 204 response and the "Allow" header,
+	// HEAD requests are handled by GET routes if there is no HEAD route.
+	MethodNotAllowedHandler(handler plow.Handler) Mux
+
+	// URL builds the url of the route named by the [RouteName] flag
+	// with the path parameters.
+	//
+	// Every parameter of the route is required and must match its regex,
+	// such as "\d+" of "/users/{id:\d+}", values are escaped in the url path.
+	// Returns an error if the route is not found or the parameters are invalid.
+	URL(name string, params Params) (*specs.Url, error)
+
 	// Routes returns an iterator over all routes configured in this mux.
 	// The routes include both the route information and matching capabilities.
 	Routes() iter.Seq[MuxRoute]
@@ -1,14 +1,18 @@
 package mux
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"iter"
+	"maps"
 	"slices"
-	"sort"
+	"strings"
 	"sync"
 
 	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/routing"
 	"github.com/oesand/plow/specs"
 )
 
@@ -24,10 +28,16 @@ func New(routers ...RouterBuilder) Mux {
 }
 
 type mux struct {
-	routes          map[specs.HttpMethod][]*route
+	trees           map[specs.HttpMethod]*routing.Tree[*route]
+	endpoints       routing.Tree[*endpoint]
+	patterns        map[string]*endpoint
+	names           map[string]*route
+	count           int
 	middlewares     []Middleware
 	notFoundHandler plow.Handler
 
+	methodNotAllowedHandler plow.Handler
+
 	mu sync.RWMutex
 }
 
@@ -43,35 +53,81 @@ func (mx *mux) Use(md Middleware) Mux {
 }
 
 func (mx *mux) Route(method specs.HttpMethod, path string, handler plow.Handler, flags ...any) Mux {
-	rt, err := newRoute(method, path, handler, flags)
+	rt, err := newRoute(method, path, handler, flags, nil)
 	if err != nil {
 		panic(err)
 	}
+	mx.addRoute(rt)
+	return mx
+}
+
+func (mx *mux) addRoute(rt *route) {
 	mx.mu.Lock()
 	defer mx.mu.Unlock()
 
-	if mx.routes == nil {
-		mx.routes = make(map[specs.HttpMethod][]*route)
+	if mx.trees == nil {
+		mx.trees = make(map[specs.HttpMethod]*routing.Tree[*route])
 	}
+	mx.count++
+	rt.index = mx.count
 
-	routes := mx.routes[method]
-	routes = append(routes, rt)
-	sort.Slice(routes, func(i, j int) bool {
-		if routes[i].Depth == routes[j].Depth {
-			return len(routes[i].ParamNames) < len(routes[j].ParamNames)
+	if name, ok := rt.name(); ok {
+		if _, has := mx.names[name]; has {
+			panic(fmt.Sprintf("plow: duplicate route name: %s", name))
 		}
-		return routes[i].Depth > routes[j].Depth
-	})
-	mx.routes[method] = routes
-	return mx
+		if mx.names == nil {
+			mx.names = make(map[string]*route)
+		}
+		mx.names[name] = rt
+	}
+
+	tree, has := mx.trees[rt.method]
+	if !has {
+		tree = &routing.Tree[*route]{}
+		mx.trees[rt.method] = tree
+	}
+	tree.Add(&rt.RoutePattern, rt)
+
+	ep, has := mx.patterns[rt.Original]
+	if !has {
+		if mx.patterns == nil {
+			mx.patterns = make(map[string]*endpoint)
+		}
+		ep = &endpoint{}
+		mx.patterns[rt.Original] = ep
+		mx.endpoints.Add(&rt.RoutePattern, ep)
+	}
+	ep.add(rt.method)
+}
+
+// endpoint holds methods of routes with the same pattern
+// and caches the value of the Allow header for them.
+type endpoint struct {
+	methods []string
+	allow   string
+}
+
+func (ep *endpoint) add(method specs.HttpMethod) {
+	ep.methods = append(ep.methods, string(method), string(specs.HttpMethodOptions))
+	if method == specs.HttpMethodGet {
+		ep.methods = append(ep.methods, string(specs.HttpMethodHead))
+	}
+	slices.Sort(ep.methods)
+	ep.methods = slices.Compact(ep.methods)
+	ep.allow = strings.Join(ep.methods, ", ")
 }
 
 func (mx *mux) Include(rb RouterBuilder) Mux {
 	if rb == nil {
 		panic("plow: nil RouterBuilder")
 	}
-	for rt := range rb.Routes() {
-		mx.Route(rt.Method(), rt.Pattern(), rt.Handler(), slices.Collect(rt.Flags())...)
+	for included := range rb.Routes() {
+		rt, err := newRoute(included.Method(), included.Pattern(), included.Handler(),
+			slices.Collect(included.Flags()), slices.Collect(included.Middlewares()))
+		if err != nil {
+			panic(err)
+		}
+		mx.addRoute(rt)
 	}
 	return mx
 }
@@ -84,16 +140,56 @@ func (mx *mux) NotFoundHandler(handler plow.Handler) Mux {
 	return mx
 }
 
+func (mx *mux) MethodNotAllowedHandler(handler plow.Handler) Mux {
+	mx.mu.Lock()
+	defer mx.mu.Unlock()
+
+	mx.methodNotAllowedHandler = handler
+	return mx
+}
+
+func (mx *mux) URL(name string, params Params) (*specs.Url, error) {
+	mx.mu.RLock()
+	rt, has := mx.names[name]
+	mx.mu.RUnlock()
+	if !has {
+		return nil, fmt.Errorf("plow: unknown route name: %s", name)
+	}
+
+	path, err := rt.Build(params)
+	if err != nil {
+		return nil, fmt.Errorf("plow: route %s: %w", name, err)
+	}
+
+	url := &specs.Url{Path: path}
+	if path != "/" {
+		url.PathSegments = strings.Split(path[1:], "/")
+	}
+	return url, nil
+}
+
 func (mx *mux) Routes() iter.Seq[MuxRoute] {
 	return func(yield func(MuxRoute) bool) {
 		mx.mu.RLock()
-		defer mx.mu.RUnlock()
+		var routes []*route
+		for _, tree := range mx.trees {
+			routes = slices.AppendSeq(routes, tree.Values())
+		}
+		mx.mu.RUnlock()
+
+		slices.SortFunc(routes, func(a, b *route) int {
+			if c := cmp.Compare(b.Depth, a.Depth); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(len(a.ParamNames), len(b.ParamNames)); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.index, b.index)
+		})
 
-		for _, routes := range mx.routes {
-			for _, rt := range routes {
-				if !yield(rt) {
-					break
-				}
+		for _, rt := range routes {
+			if !yield(rt) {
+				break
 			}
 		}
 	}
@@ -104,38 +200,132 @@ func (mx *mux) Handle(ctx context.Context, request plow.Request) plow.Response {
 	defer mx.mu.RUnlock()
 
 	if len(mx.middlewares) > 0 {
-		nextMd, stop := iter.Pull(slices.Values(mx.middlewares))
-		defer stop()
+		ctx = context.WithValue(ctx, matchedRouteKey, &routeHook{mx: mx, request: request})
+	}
 
-		var nextFunc NextFunc
-		nextFunc = func(ctx context.Context) plow.Response {
-			if md, ok := nextMd(); ok {
-				return md.Intercept(ctx, request, nextFunc)
-			}
-			return mx.handle(ctx, request)
+	return intercept(ctx, request, mx.middlewares, func(ctx context.Context) plow.Response {
+		return mx.handle(ctx, request)
+	})
+}
+
+// intercept runs the middlewares in order, the last one continues with the handle function.
+func intercept(ctx context.Context, request plow.Request, middlewares []Middleware, handle NextFunc) plow.Response {
+	if len(middlewares) == 0 {
+		return handle(ctx)
+	}
+
+	nextMd, stop := iter.Pull(slices.Values(middlewares))
+	defer stop()
+
+	var nextFunc NextFunc
+	nextFunc = func(ctx context.Context) plow.Response {
+		if md, ok := nextMd(); ok {
+			return md.Intercept(ctx, request, nextFunc)
 		}
+		return handle(ctx)
+	}
+
+	return nextFunc(ctx)
+}
+
+// match is the result of matching the request with the routes.
+type match struct {
+	route  *route
+	params Params
+
+	// allow contains methods of routes matching the path
+	// if there is no route for the request method.
+	allow string
+}
 
-		return nextFunc(ctx)
+// matchRoute returns the most specific route matching the request and its path parameters,
+// HEAD requests are matched with GET routes if there is no HEAD route.
+func (mx *mux) matchRoute(request plow.Request) (*route, iter.Seq2[string, string]) {
+	method, path := request.Method(), request.Url().Path
+	if tree, has := mx.trees[method]; has {
+		if rt, params, ok := tree.Match(path); ok {
+			return rt, params
+		}
+	}
+	if tree, has := mx.trees[specs.HttpMethodGet]; has && method == specs.HttpMethodHead {
+		if rt, params, ok := tree.Match(path); ok {
+			return rt, params
+		}
+	}
+	return nil, nil
+}
+
+// match returns the route matching the request with collected path parameters
+// or methods of routes matching the path otherwise.
+func (mx *mux) match(request plow.Request) match {
+	if rt, params := mx.matchRoute(request); rt != nil {
+		return match{route: rt, params: maps.Collect(params)}
 	}
 
-	return mx.handle(ctx, request)
+	// Allow list is cached by the pattern and joined
+	// only if the path matches patterns with different methods
+	var found *endpoint
+	var methods []string
+	for ep := range mx.endpoints.MatchAll(request.Url().Path) {
+		switch {
+		case found == nil:
+			found = ep
+		case methods == nil:
+			methods = append(slices.Clone(found.methods), ep.methods...)
+		default:
+			methods = append(methods, ep.methods...)
+		}
+	}
+	if methods != nil {
+		slices.Sort(methods)
+		return match{allow: strings.Join(slices.Compact(methods), ", ")}
+	}
+	if found != nil {
+		return match{allow: found.allow}
+	}
+	return match{}
 }
 
 func (mx *mux) handle(ctx context.Context, request plow.Request) plow.Response {
-	if mx.routes != nil {
-		url := request.Url()
-		routes := mx.routes[request.Method()]
-		for _, rt := range routes {
-			if ok, params := rt.Match(url.Path); ok {
-				for key, value := range params {
-					if url.Query == nil {
-						url.Query = make(specs.Query)
-					}
-					url.Query[key] = value
-				}
-				return rt.Handler().Handle(ctx, request)
+	m := mx.match(request)
+
+	hook, ok := ctx.Value(matchedRouteKey).(*routeHook)
+	if !ok && m.route != nil {
+		hook = &routeHook{}
+		ctx = context.WithValue(ctx, matchedRouteKey, hook)
+	}
+	if hook != nil {
+		hook.route, hook.matched = m.route, true
+	}
+
+	if m.route != nil {
+		if len(m.params) > 0 {
+			ctx = WithPathParams(ctx, m.params)
+		}
+		return intercept(ctx, request, m.route.middlewares, func(ctx context.Context) plow.Response {
+			return m.route.Handler().Handle(ctx, request)
+		})
+	}
+
+	if allow := m.allow; allow != "" {
+		if request.Method() == specs.HttpMethodOptions {
+			return plow.EmptyResponse(specs.StatusCodeNoContent, func(resp plow.Response) {
+				resp.Header().Set("Allow", allow)
+			})
+		}
+
+		if handler := mx.methodNotAllowedHandler; handler != nil {
+			resp := handler.Handle(ctx, request)
+			if resp != nil && !resp.Header().Has("Allow") {
+				resp.Header().Set("Allow", allow)
 			}
+			return resp
 		}
+
+		return plow.TextResponse(specs.StatusCodeMethodNotAllowed, specs.ContentTypePlain,
+			fmt.Sprintf("Method Not Allowed %s", request.Method()), func(resp plow.Response) {
+				resp.Header().Set("Allow", allow)
+			})
 	}
 
 	if handler := mx.notFoundHandler; handler != nil {
@@ -145,3 +335,40 @@ func (mx *mux) handle(ctx context.Context, request plow.Request) plow.Response {
 	return plow.TextResponse(specs.StatusCodeNotFound, specs.ContentTypePlain,
 		fmt.Sprintf("Not Found %s", request.Url().Path))
 }
+
+var matchedRouteKey = internal.FlagKey{Key: "mux.matched.route.key"}
+
+// routeHook holds the route matched by the request of the [Mux],
+// it is filled in when the request is handled after middlewares of the Mux
+// or by the first call of [MatchedRoute] in the middlewares.
+type routeHook struct {
+	mx      *mux
+	request plow.Request
+
+	matched bool
+	route   *route
+}
+
+// MatchedRoute returns the route of the [Mux] matched by the request
+// of the handler or middleware context.
+//
+// In middlewares of the Mux the route is matched by the current state of the request
+// on the first call, so previous middlewares can rewrite the request
+// before the route is chosen. The request is matched again after the middlewares.
+//
+// Returns nil if no route matches the request.
+func MatchedRoute(ctx context.Context) MuxRoute {
+	hook, ok := ctx.Value(matchedRouteKey).(*routeHook)
+	if !ok {
+		return nil
+	}
+	if !hook.matched {
+		// Handle of the mux holds the read lock while middlewares are running
+		hook.route, _ = hook.mx.matchRoute(hook.request)
+		hook.matched = true
+	}
+	if hook.route == nil {
+		return nil
+	}
+	return hook.route
+}
@@ -11,6 +11,16 @@ import (
 	"testing"
 )
 
+func routesOf(mx Mux, method specs.HttpMethod) []MuxRoute {
+	var routes []MuxRoute
+	for rt := range mx.Routes() {
+		if rt.Method() == method {
+			routes = append(routes, rt)
+		}
+	}
+	return routes
+}
+
 func TestMux(t *testing.T) {
 	t.Run("Route", func(t *testing.T) {
 		handler := plow.HandlerFunc(nil)
@@ -54,7 +64,7 @@ func TestMux(t *testing.T) {
 
 		for method, exroutes := range expectedRoutes {
 			var i int
-			for _, rt := range mx.routes[method] {
+			for _, rt := range routesOf(mx, method) {
 				want := exroutes[i]
 				if !reflect.DeepEqual(rt.Method(), method) {
 					t.Errorf("Mux.Method() = %v, want %v", rt.Method(), method)
@@ -123,7 +133,7 @@ func TestMux(t *testing.T) {
 
 		for method, exroutes := range expectedRoutes {
 			var i int
-			for _, rt := range mx.routes[method] {
+			for _, rt := range routesOf(mx, method) {
 				want := exroutes[i]
 				if !reflect.DeepEqual(rt.Method(), method) {
 					t.Errorf("Mux.Method() = %v, want %v", rt.Method(), method)
@@ -198,8 +208,11 @@ func TestMux(t *testing.T) {
 			}
 			visitPattern.Store(true)
 
-			if request.Url().Query["id"] != "expected790" {
-				t.Errorf("wrong path parameter: %v", request.Url().Query)
+			if PathParam(ctx, "id") != "expected790" {
+				t.Errorf("wrong path parameter: %v", PathParams(ctx))
+			}
+			if request.Url().Query.Any() {
+				t.Errorf("path parameters must not be added to query: %v", request.Url().Query)
 			}
 			return nil
 		}))
@@ -253,11 +266,13 @@ func TestMux(t *testing.T) {
 			}
 			visitNotFound.Store(false)
 
-			mx.Handle(ctx, mock.DefaultRequest().Method(specs.HttpMethodDelete).Url(specs.MustParseUrl("/")).Request())
-			if !visitNotFound.Load() {
-				t.Errorf("not visited")
+			resp := mx.Handle(ctx, mock.DefaultRequest().Method(specs.HttpMethodDelete).Url(specs.MustParseUrl("/")).Request())
+			if visitNotFound.Load() {
+				t.Errorf("visited not found for other method")
+			}
+			if resp.StatusCode() != specs.StatusCodeMethodNotAllowed || resp.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+				t.Errorf("expected method not allowed, got %d %v", resp.StatusCode(), resp.Header())
 			}
-			visitNotFound.Store(false)
 		})
 
 		// Check Middleware
@@ -287,3 +302,251 @@ func TestMux(t *testing.T) {
 		})
 	})
 }
+
+func TestMatchedRoute(t *testing.T) {
+	var matched MuxRoute
+	mx := New().
+		Use(MiddlewareFunc(func(ctx context.Context, request plow.Request, next NextFunc) plow.Response {
+			matched = MatchedRoute(ctx)
+			return next(ctx)
+		})).
+		Route(specs.HttpMethodGet, "/items/{id}", plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+			if MatchedRoute(ctx) != matched {
+				t.Errorf("handler route differs from middleware route")
+			}
+			if PathParam(ctx, "id") != "1" {
+				t.Errorf("PathParam() = %q, want 1", PathParam(ctx, "id"))
+			}
+			return nil
+		}), "flag")
+
+	mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl("/items/1")).Request())
+	if matched == nil || matched.Pattern() != "/items/{id}" || !slices.Contains(slices.Collect(matched.Flags()), any("flag")) {
+		t.Errorf("MatchedRoute() = %v, want /items/{id}", matched)
+	}
+
+	mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl("/unknown")).Request())
+	if matched != nil {
+		t.Errorf("MatchedRoute() = %v, want nil", matched.Pattern())
+	}
+}
+
+func TestMux_MethodNotAllowed(t *testing.T) {
+	handler := plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, string(request.Method()))
+	})
+
+	mx := New().
+		Route(specs.HttpMethodGet, "/items/{id}", handler).
+		Route(specs.HttpMethodPut, "/items/{id}", handler).
+		Route(specs.HttpMethodDelete, "/items/{id:\\d+}", handler).
+		Route(specs.HttpMethodHead, "/head", handler).
+		Route(specs.HttpMethodPost, "/items", handler).
+		Route(specs.HttpMethodPatch, "/items", handler)
+
+	ctx := context.Background()
+	request := func(method specs.HttpMethod, path string) plow.Response {
+		return mx.Handle(ctx, mock.DefaultRequest().Method(method).Url(specs.MustParseUrl(path)).Request())
+	}
+
+	tests := []struct {
+		method specs.HttpMethod
+		path   string
+		code   specs.StatusCode
+		allow  string
+	}{
+		{specs.HttpMethodGet, "/items/1", specs.StatusCodeOK, ""},
+		{specs.HttpMethodHead, "/items/1", specs.StatusCodeOK, ""},
+		{specs.HttpMethodHead, "/head", specs.StatusCodeOK, ""},
+		{specs.HttpMethodPost, "/items/1", specs.StatusCodeMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PUT"},
+		{specs.HttpMethodPost, "/items/abc", specs.StatusCodeMethodNotAllowed, "GET, HEAD, OPTIONS, PUT"},
+		{specs.HttpMethodGet, "/head", specs.StatusCodeMethodNotAllowed, "HEAD, OPTIONS"},
+		{specs.HttpMethodGet, "/items", specs.StatusCodeMethodNotAllowed, "OPTIONS, PATCH, POST"},
+		{specs.HttpMethodPost, "/items/1/more", specs.StatusCodeNotFound, ""},
+		{specs.HttpMethodOptions, "/items/1", specs.StatusCodeNoContent, "DELETE, GET, HEAD, OPTIONS, PUT"},
+		{specs.HttpMethodPost, "/unknown", specs.StatusCodeNotFound, ""},
+		{specs.HttpMethodOptions, "/unknown", specs.StatusCodeNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp := request(tt.method, tt.path)
+		if resp.StatusCode() != tt.code || resp.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %d with Allow %q, want %d with %q",
+				tt.method, tt.path, resp.StatusCode(), resp.Header().Get("Allow"), tt.code, tt.allow)
+		}
+	}
+
+	mx.MethodNotAllowedHandler(plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.EmptyResponse(specs.StatusCodeMethodNotAllowed, func(resp plow.Response) {
+			resp.Header().Set("X-Custom", "1")
+		})
+	}))
+	if resp := request(specs.HttpMethodPost, "/items/1"); resp.Header().Get("X-Custom") != "1" ||
+		resp.Header().Get("Allow") != "DELETE, GET, HEAD, OPTIONS, PUT" {
+		t.Errorf("expected custom handler response with Allow header, got %v", resp.Header())
+	}
+}
+
+func TestMux_RouteMiddlewares(t *testing.T) {
+	var trace []string
+	tracing := func(name string) Middleware {
+		return MiddlewareFunc(func(ctx context.Context, request plow.Request, next NextFunc) plow.Response {
+			trace = append(trace, name)
+			return next(ctx)
+		})
+	}
+	handler := plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		trace = append(trace, "handler")
+		return plow.EmptyResponse(specs.StatusCodeOK)
+	})
+
+	users := PrefixRouter("/users", func(router RouterBuilder) {
+		router.Route(specs.HttpMethodGet, "/{id}", handler).Use(tracing("route"))
+		router.Route(specs.HttpMethodGet, "/", handler)
+	}).Use(tracing("users"))
+
+	api := PrefixRouter("/api").Use(tracing("api")).Include(users)
+	api.Route(specs.HttpMethodGet, "/health", handler)
+
+	mx := New().
+		Use(tracing("global")).
+		Include(api).
+		Route(specs.HttpMethodGet, "/", handler)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/users/1", []string{"global", "api", "users", "route", "handler"}},
+		{"/api/users", []string{"global", "api", "users", "handler"}},
+		{"/api/health", []string{"global", "api", "handler"}},
+		{"/", []string{"global", "handler"}},
+		{"/unknown", []string{"global"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			trace = nil
+			mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl(tt.path)).Request())
+			if !slices.Equal(trace, tt.want) {
+				t.Errorf("middlewares = %v, want %v", trace, tt.want)
+			}
+		})
+	}
+}
+
+func TestMux_URL(t *testing.T) {
+	handler := plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.EmptyResponse(specs.StatusCodeOK)
+	})
+
+	mx := New(PrefixRouter("/api", func(router RouterBuilder) {
+		router.Route(specs.HttpMethodGet, "/users/{id:\\d+}", handler).Name("user")
+		router.Route(specs.HttpMethodGet, "/search/{query}", handler).Name("search")
+	})).
+		Route(specs.HttpMethodGet, "/", handler, RouteName("home")).
+		Route(specs.HttpMethodGet, "/static/{*}", handler, RouteName("static"))
+
+	tests := []struct {
+		name    string
+		params  Params
+		want    string
+		wantErr bool
+	}{
+		{"home", nil, "/", false},
+		{"user", Params{"id": "42"}, "/api/users/42", false},
+		{"user", Params{"id": "bob"}, "", true},
+		{"user", nil, "", true},
+		{"search", Params{"query": "hello world?"}, "/api/search/hello%20world%3F", false},
+		{"static", Params{"*": "css/app.css"}, "/static/css/app.css", false},
+		{"unknown", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := mx.URL(tt.name, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("URL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := url.String(); got != tt.want {
+				t.Errorf("URL() = %q, want %q", got, tt.want)
+			}
+
+			resp := mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl(url.String())).Request())
+			if resp.StatusCode() != specs.StatusCodeOK {
+				t.Errorf("built url %q is not routed back, status %d", url, resp.StatusCode())
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate route name")
+		}
+	}()
+	mx.Route(specs.HttpMethodPost, "/other", handler, RouteName("home"))
+}
+
+func TestMux_MiddlewareRewrite(t *testing.T) {
+	routeHandler := func(name string) plow.Handler {
+		return plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+			return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, name)
+		})
+	}
+
+	var matched []string
+	mx := New().
+		Use(MiddlewareFunc(func(ctx context.Context, request plow.Request, next NextFunc) plow.Response {
+			if request.Url().Path == "/old" {
+				request.Url().Path = "/new"
+			}
+			return next(ctx)
+		})).
+		Use(MiddlewareFunc(func(ctx context.Context, request plow.Request, next NextFunc) plow.Response {
+			if route := MatchedRoute(ctx); route != nil {
+				matched = append(matched, route.Pattern())
+			}
+			return next(ctx)
+		})).
+		Route(specs.HttpMethodGet, "/old", routeHandler("old")).
+		Route(specs.HttpMethodGet, "/new", routeHandler("new"))
+
+	resp := mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl("/old")).Request())
+	if body := readTestBody(t, resp); body != "new" {
+		t.Errorf("expected rewritten route handled, got %q", body)
+	}
+	if !slices.Equal(matched, []string{"/new"}) {
+		t.Errorf("MatchedRoute() in middleware = %v, want [/new]", matched)
+	}
+}
+
+func TestMux_RoutePriority(t *testing.T) {
+	routeHandler := func(pattern string) plow.Handler {
+		return plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+			return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, pattern)
+		})
+	}
+
+	mx := New()
+	for _, pattern := range []string{"/files/{*}", "/files/{name}", "/files/{name:\\d+}", "/files/latest"} {
+		mx.Route(specs.HttpMethodGet, pattern, routeHandler(pattern))
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/files/latest", "/files/latest"},
+		{"/files/42", "/files/{name:\\d+}"},
+		{"/files/readme", "/files/{name}"},
+		{"/files/docs/readme", "/files/{*}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := mx.Handle(context.Background(), mock.DefaultRequest().Url(specs.MustParseUrl(tt.path)).Request())
+			if body := readTestBody(t, resp); body != tt.want {
+				t.Errorf("expected route %q, got %q", tt.want, body)
+			}
+		})
+	}
+}
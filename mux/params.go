@@ -0,0 +1,56 @@
+package mux
+
+import (
+	"context"
+	"iter"
+	"maps"
+
+	"github.com/oesand/plow/internal"
+)
+
+// Params contains path parameters of the route matched by the request,
+// such as "id" of the "/users/{id}" pattern or "*" of the wildcard.
+//
+// Path parameters are kept apart from [specs.Url.Query],
+// so query parameters with the same name are not shadowed.
+type Params map[string]string
+
+// Get returns the value of the path parameter or empty string if it does not exist.
+func (params Params) Get(name string) string {
+	return params[name]
+}
+
+// TryGet returns the value of the path parameter and whether it exists.
+func (params Params) TryGet(name string) (string, bool) {
+	value, has := params[name]
+	return value, has
+}
+
+// All returns an iterator over all path parameters.
+func (params Params) All() iter.Seq2[string, string] {
+	return maps.All(params)
+}
+
+var pathParamsKey = internal.FlagKey{Key: "mux.path.params.key"}
+
+// PathParams returns path parameters of the route matched by the request
+// of the handler or middleware context.
+//
+// Returns nil if no route matches the request or the route has no parameters.
+func PathParams(ctx context.Context) Params {
+	params, _ := ctx.Value(pathParamsKey).(Params)
+	return params
+}
+
+// PathParam returns the value of the path parameter of the route
+// matched by the request of the handler or middleware context.
+func PathParam(ctx context.Context, name string) string {
+	return PathParams(ctx).Get(name)
+}
+
+// WithPathParams returns a copy of [context.Context] in which the path parameters are stored.
+//
+// Used to call handlers which depend on path parameters outside the [Mux].
+func WithPathParams(ctx context.Context, params Params) context.Context {
+	return context.WithValue(ctx, pathParamsKey, params)
+}
@@ -25,7 +25,7 @@ func TestFormParam(t *testing.T) {
 			name:         "valid form data",
 			body:         "username=john&email=john@example.com&age=25",
 			contentType:  "application/x-www-form-urlencoded",
-			expectedData: specs.Query{"username": "john", "email": "john@example.com", "age": "25"},
+			expectedData: specs.Query{"username": {"john"}, "email": {"john@example.com"}, "age": {"25"}},
 			expectedResp: false,
 		},
 		{
@@ -40,14 +40,14 @@ func TestFormParam(t *testing.T) {
 			name:         "form data with special chars",
 			body:         "name=John%20Doe&city=New%20York",
 			contentType:  "application/x-www-form-urlencoded",
-			expectedData: specs.Query{"name": "John Doe", "city": "New York"},
+			expectedData: specs.Query{"name": {"John Doe"}, "city": {"New York"}},
 			expectedResp: false,
 		},
 		{
 			name:         "form data with empty values",
 			body:         "username=john&password=&email=john@example.com",
 			contentType:  "application/x-www-form-urlencoded",
-			expectedData: specs.Query{"username": "john", "password": "", "email": "john@example.com"},
+			expectedData: specs.Query{"username": {"john"}, "password": {""}, "email": {"john@example.com"}},
 			expectedResp: false,
 		},
 		{
@@ -98,7 +98,7 @@ func TestFormParam(t *testing.T) {
 						t.Errorf("expected %d form fields, got %d", len(tt.expectedData), len(result))
 					}
 					for key, expectedValue := range tt.expectedData {
-						if actualValue := result[key]; actualValue != expectedValue {
+						if actualValue := result[key]; !reflect.DeepEqual(actualValue, expectedValue) {
 							t.Errorf("expected value %q for key %q, got %q", expectedValue, key, actualValue)
 						}
 					}
@@ -0,0 +1,45 @@
+package prm
+
+import (
+	"context"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/mux"
+)
+
+// PathParam creates a new path parameter of the route matched by the [mux.Mux],
+// such as "id" of the "/users/{id}" pattern.
+//
+// The parameter is always required, the route without it responds with bad request.
+func PathParam[T BasicTypes](name string, conditions ...Condition[T]) ParameterProvider[T] {
+	return &pathParameter[T]{
+		name:       name,
+		conditions: conditions,
+	}
+}
+
+type pathParameter[T BasicTypes] struct {
+	name       string
+	conditions []Condition[T]
+}
+
+func (pp *pathParameter[T]) GetParamValue(ctx context.Context, _ plow.Request) (T, plow.Response) {
+	str, has := mux.PathParams(ctx).TryGet(pp.name)
+	if !has {
+		var val T
+		return val, ErrorResponse("path parameter '%s' is required", pp.name)
+	}
+
+	val, resp := parseValue[T]("path parameter", pp.name, str)
+	if resp != nil {
+		return val, resp
+	}
+
+	for _, condition := range pp.conditions {
+		if err := condition.Validate(val); err != nil {
+			resp = ErrorResponse("path parameter '%s' is invalid: %s", pp.name, err)
+			break
+		}
+	}
+	return val, resp
+}
@@ -0,0 +1,68 @@
+package prm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/mock"
+	"github.com/oesand/plow/mux"
+	"github.com/oesand/plow/specs"
+)
+
+func TestPathParam(t *testing.T) {
+	tests := []struct {
+		name          string
+		params        mux.Params
+		expectedValue int
+		expectedResp  bool
+	}{
+		{"valid param", mux.Params{"id": "42"}, 42, false},
+		{"missing param", mux.Params{"other": "42"}, 0, true},
+		{"invalid param", mux.Params{"id": "abc"}, 0, true},
+		{"failed condition", mux.Params{"id": "0"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := mux.WithPathParams(context.Background(), tt.params)
+			req := mock.DefaultRequest().Request()
+
+			value, resp := PathParam[int]("id", Min(1)).GetParamValue(ctx, req)
+			if tt.expectedResp && resp == nil {
+				t.Error("expected response, got nil")
+			}
+			if !tt.expectedResp && resp != nil {
+				t.Errorf("unexpected response: %v", resp)
+			}
+			if !tt.expectedResp && value != tt.expectedValue {
+				t.Errorf("expected value %d, got %d", tt.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestPathParam_Mux(t *testing.T) {
+	var gotId int
+	var gotQuery string
+	mx := mux.New().Route(specs.HttpMethodGet, "/users/{id}", ParamHandler2(
+		PathParam[int]("id"),
+		QueryParam[string]("id"),
+		func(ctx context.Context, id int, query string) plow.Response {
+			gotId, gotQuery = id, query
+			return plow.EmptyResponse(specs.StatusCodeOK)
+		},
+	))
+
+	req := mock.DefaultRequest().Url(specs.MustParseUrl("/users/7?id=query")).Request()
+	resp := mx.Handle(context.Background(), req)
+	if resp.StatusCode() != specs.StatusCodeOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode())
+	}
+	if gotId != 7 || gotQuery != "query" {
+		t.Errorf("expected path 7 and query %q, got %d and %q", "query", gotId, gotQuery)
+	}
+	if req.Url().Query.Get("id") != "query" {
+		t.Errorf("request query was modified: %v", req.Url().Query)
+	}
+}
@@ -9,15 +9,32 @@ import (
 	"github.com/oesand/plow"
 )
 
+// QueryTypes represents types that can be parsed from query parameters,
+// slice types collect all values of the repeated parameter.
+type QueryTypes interface {
+	BasicTypes | SliceTypes
+}
+
+// SliceTypes represents slices of basic types
+type SliceTypes interface {
+	~[]string | ~[]bool |
+		~[]uint | ~[]uint8 | ~[]uint16 | ~[]uint32 | ~[]uint64 |
+		~[]int | ~[]int8 | ~[]int16 | ~[]int32 | ~[]int64 |
+		~[]float32 | ~[]float64
+}
+
 // QueryParam creates a new query parameter.
-func QueryParam[T BasicTypes](name string, conditions ...Condition[T]) OptionalParameterProvider[T] {
+//
+// Slice type parameter, for example QueryParam[[]int], collects
+// all values of the repeated parameter such as "?id=1&id=2".
+func QueryParam[T QueryTypes](name string, conditions ...Condition[T]) OptionalParameterProvider[T] {
 	return &queryParameter[T]{
 		name:       name,
 		conditions: conditions,
 	}
 }
 
-type queryParameter[T BasicTypes] struct {
+type queryParameter[T QueryTypes] struct {
 	name       string
 	required   bool
 	conditions []Condition[T]
@@ -29,27 +46,94 @@ func (qp *queryParameter[T]) Require() OptionalParameterProvider[T] {
 }
 
 func (qp *queryParameter[T]) GetParamValue(_ context.Context, req plow.Request) (T, plow.Response) {
-	var str string
+	var values []string
 	if req.Url().Query.Any() {
-		str, _ = req.Url().Query[qp.name]
+		for _, value := range req.Url().Query.Values(qp.name) {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
 	}
 
 	var val T
 	var resp plow.Response
-	if str == "" {
+	if len(values) == 0 {
 		if qp.required {
 			resp = ErrorResponse("query parameter '%s' is required", qp.name)
 		}
 		return val, resp
 	}
 
+	switch any(val).(type) {
+	case []string:
+		val, resp = parseQueryValues[string, T](qp.name, values)
+	case []bool:
+		val, resp = parseQueryValues[bool, T](qp.name, values)
+	case []uint:
+		val, resp = parseQueryValues[uint, T](qp.name, values)
+	case []uint8:
+		val, resp = parseQueryValues[uint8, T](qp.name, values)
+	case []uint16:
+		val, resp = parseQueryValues[uint16, T](qp.name, values)
+	case []uint32:
+		val, resp = parseQueryValues[uint32, T](qp.name, values)
+	case []uint64:
+		val, resp = parseQueryValues[uint64, T](qp.name, values)
+	case []int:
+		val, resp = parseQueryValues[int, T](qp.name, values)
+	case []int8:
+		val, resp = parseQueryValues[int8, T](qp.name, values)
+	case []int16:
+		val, resp = parseQueryValues[int16, T](qp.name, values)
+	case []int32:
+		val, resp = parseQueryValues[int32, T](qp.name, values)
+	case []int64:
+		val, resp = parseQueryValues[int64, T](qp.name, values)
+	case []float32:
+		val, resp = parseQueryValues[float32, T](qp.name, values)
+	case []float64:
+		val, resp = parseQueryValues[float64, T](qp.name, values)
+	default:
+		val, resp = parseValue[T]("query parameter", qp.name, values[0])
+	}
+	if resp != nil {
+		return val, resp
+	}
+
+	for _, condition := range qp.conditions {
+		if err := condition.Validate(val); err != nil {
+			resp = ErrorResponse("query parameter '%s' is invalid: %s", qp.name, err)
+			break
+		}
+	}
+	return val, resp
+}
+
+// parseQueryValues parses every value of the repeated query parameter into slice T of E.
+func parseQueryValues[E BasicTypes, T QueryTypes](name string, values []string) (T, plow.Response) {
+	items := make([]E, 0, len(values))
+	for _, str := range values {
+		item, resp := parseValue[E]("query parameter", name, str)
+		if resp != nil {
+			var val T
+			return val, resp
+		}
+		items = append(items, item)
+	}
+	return any(items).(T), nil
+}
+
+// parseValue parses the string value of the parameter of the kind, such as "query parameter", into T.
+func parseValue[T QueryTypes](kind, name, str string) (T, plow.Response) {
+	var val T
+	var resp plow.Response
 	switch any(val).(type) {
 	case string:
 		val = any(str).(T)
 	case bool:
 		bv, err := strconv.ParseBool(str)
 		if err != nil {
-			resp = ErrorResponse("query parameter '%s' must be bool", qp.name)
+			resp = ErrorResponse("%s '%s' must be bool", kind, name)
 			break
 		}
 		val = any(bv).(T)
@@ -57,35 +141,28 @@ func (qp *queryParameter[T]) GetParamValue(_ context.Context, req plow.Request)
 		bitSize := bitSizeNum(val)
 		uiv, err := strconv.ParseUint(str, 10, bitSize)
 		if err != nil {
-			resp = ErrorResponse("query parameter '%s' must be integer", qp.name)
+			resp = ErrorResponse("%s '%s' must be integer", kind, name)
 			break
 		}
-		val = any(uiv).(T)
+		val = reflect.ValueOf(uiv).Convert(reflect.TypeFor[T]()).Interface().(T)
 	case int, int8, int16, int32, int64:
 		bitSize := bitSizeNum(val)
 		iv, err := strconv.ParseInt(str, 10, bitSize)
 		if err != nil {
-			resp = ErrorResponse("query parameter '%s' must be integer", qp.name)
+			resp = ErrorResponse("%s '%s' must be integer", kind, name)
 			break
 		}
-		val = any(iv).(T)
+		val = reflect.ValueOf(iv).Convert(reflect.TypeFor[T]()).Interface().(T)
 	case float32, float64:
 		bitSize := bitSizeNum(val)
-		iv, err := strconv.ParseFloat(str, bitSize)
+		fv, err := strconv.ParseFloat(str, bitSize)
 		if err != nil {
-			resp = ErrorResponse("query parameter '%s' must be float", qp.name)
+			resp = ErrorResponse("%s '%s' must be float", kind, name)
 			break
 		}
-		val = any(iv).(T)
+		val = reflect.ValueOf(fv).Convert(reflect.TypeFor[T]()).Interface().(T)
 	default:
 		panic(fmt.Sprintf("plow: unknown type: %s", reflect.TypeFor[T]().String()))
 	}
-
-	for _, condition := range qp.conditions {
-		if err := condition.Validate(val); err != nil {
-			resp = ErrorResponse("query parameter '%s' is invalid: %s", qp.name, err)
-			break
-		}
-	}
 	return val, resp
 }
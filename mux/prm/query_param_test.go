@@ -2,6 +2,7 @@ package prm
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/oesand/plow/mock"
@@ -21,6 +22,14 @@ func (m *mockCondition[T]) Validate(value T) error {
 	return nil
 }
 
+func queryOf(params map[string]string) specs.Query {
+	query := specs.Query{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	return query
+}
+
 type mockError struct {
 	msg string
 }
@@ -45,7 +54,7 @@ func TestQueryParamString(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[string](tt.paramName)
@@ -84,7 +93,7 @@ func TestQueryParamBool(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[bool](tt.paramName)
@@ -122,7 +131,7 @@ func TestQueryParamInt64(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[int64](tt.paramName)
@@ -159,7 +168,7 @@ func TestQueryParamUint64(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[uint64](tt.paramName)
@@ -197,7 +206,7 @@ func TestQueryParamFloat64(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[float64](tt.paramName)
@@ -232,7 +241,7 @@ func TestQueryParamRequired(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[string](tt.paramName)
@@ -274,7 +283,7 @@ func TestQueryParamWithConditions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			url := &specs.Url{Query: specs.Query(tt.queryParams)}
+			url := &specs.Url{Query: queryOf(tt.queryParams)}
 			req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 			param := QueryParam[int64](tt.paramName, tt.conditions...)
@@ -322,7 +331,7 @@ func TestQueryParamEdgeCases(t *testing.T) {
 	})
 
 	t.Run("query param with equals sign", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"name": "key=value"}}
+		url := &specs.Url{Query: specs.Query{"name": {"key=value"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[string]("name")
@@ -337,7 +346,7 @@ func TestQueryParamEdgeCases(t *testing.T) {
 	})
 
 	t.Run("query param with ampersand", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"name": "a&b"}}
+		url := &specs.Url{Query: specs.Query{"name": {"a&b"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[string]("name")
@@ -354,7 +363,7 @@ func TestQueryParamEdgeCases(t *testing.T) {
 
 func TestQueryParamTypeConversions(t *testing.T) {
 	t.Run("int64 conversion", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"num": "127"}}
+		url := &specs.Url{Query: specs.Query{"num": {"127"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[int64]("num")
@@ -369,7 +378,7 @@ func TestQueryParamTypeConversions(t *testing.T) {
 	})
 
 	t.Run("uint64 conversion", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"num": "65535"}}
+		url := &specs.Url{Query: specs.Query{"num": {"65535"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[uint64]("num")
@@ -384,7 +393,7 @@ func TestQueryParamTypeConversions(t *testing.T) {
 	})
 
 	t.Run("float64 conversion", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"num": "3.14159"}}
+		url := &specs.Url{Query: specs.Query{"num": {"3.14159"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[float64]("num")
@@ -418,7 +427,7 @@ func TestQueryParamErrorMessages(t *testing.T) {
 	})
 
 	t.Run("invalid bool error message", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"flag": "invalid"}}
+		url := &specs.Url{Query: specs.Query{"flag": {"invalid"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[bool]("flag")
@@ -434,7 +443,7 @@ func TestQueryParamErrorMessages(t *testing.T) {
 	})
 
 	t.Run("invalid int error message", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"num": "not_a_number"}}
+		url := &specs.Url{Query: specs.Query{"num": {"not_a_number"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[int64]("num")
@@ -450,7 +459,7 @@ func TestQueryParamErrorMessages(t *testing.T) {
 	})
 
 	t.Run("condition validation error message", func(t *testing.T) {
-		url := &specs.Url{Query: specs.Query{"num": "42"}}
+		url := &specs.Url{Query: specs.Query{"num": {"42"}}}
 		req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
 
 		param := QueryParam[int64]("num", &mockCondition[int64]{shouldFail: true, failMsg: "custom validation error"})
@@ -465,3 +474,34 @@ func TestQueryParamErrorMessages(t *testing.T) {
 		}
 	})
 }
+
+func TestQueryParamSlice(t *testing.T) {
+	url := specs.MustParseUrl("/search?id=1&id=20&id=300&tag=a&tag=b&flag=yes")
+	req := mock.DefaultRequest().Method(specs.HttpMethodGet).Url(url).Request()
+
+	ids, resp := QueryParam[[]int]("id").GetParamValue(context.Background(), req)
+	if resp != nil {
+		t.Errorf("unexpected error response: %v", resp)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 20, 300}) {
+		t.Errorf("expected [1 20 300], got %v", ids)
+	}
+
+	tags, resp := QueryParam[[]string]("tag").GetParamValue(context.Background(), req)
+	if resp != nil {
+		t.Errorf("unexpected error response: %v", resp)
+	}
+	if !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("expected [a b], got %v", tags)
+	}
+
+	_, resp = QueryParam[[]bool]("flag").GetParamValue(context.Background(), req)
+	if resp == nil || resp.StatusCode() != specs.StatusCodeBadRequest {
+		t.Error("expected bad request response")
+	}
+
+	missing, resp := QueryParam[[]int8]("missing").Require().GetParamValue(context.Background(), req)
+	if resp == nil || missing != nil {
+		t.Errorf("expected required error response, got %v", missing)
+	}
+}
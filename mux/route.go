@@ -11,7 +11,11 @@ import (
 	"strings"
 )
 
-func newRoute(method specs.HttpMethod, pattern string, handler plow.Handler, flags []any) (*route, error) {
+// RouteName is a route flag which names the route,
+// so its URL can be built by [Mux.URL].
+type RouteName string
+
+func newRoute(method specs.HttpMethod, pattern string, handler plow.Handler, flags []any, middlewares []Middleware) (*route, error) {
 	if pattern == "" {
 		return nil, errors.New("plow: route pattern must have at least one character")
 	}
@@ -40,6 +44,7 @@ func newRoute(method specs.HttpMethod, pattern string, handler plow.Handler, fla
 		method:       method,
 		handler:      handler,
 		flags:        flags,
+		middlewares:  middlewares,
 	}, nil
 }
 
@@ -48,6 +53,11 @@ type route struct {
 	method  specs.HttpMethod
 	handler plow.Handler
 	flags   []any
+
+	middlewares []Middleware
+
+	// index is the order of the route addition to the mux
+	index int
 }
 
 func (rb *route) Method() specs.HttpMethod {
@@ -65,3 +75,17 @@ func (rb *route) Handler() plow.Handler {
 func (rb *route) Flags() iter.Seq[any] {
 	return slices.Values(rb.flags)
 }
+
+func (rb *route) Middlewares() iter.Seq[Middleware] {
+	return slices.Values(rb.middlewares)
+}
+
+// name returns the name of the route by the first [RouteName] flag.
+func (rb *route) name() (string, bool) {
+	for _, flag := range rb.flags {
+		if name, ok := flag.(RouteName); ok {
+			return string(name), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal/parsing"
+	"github.com/oesand/plow/specs"
+)
+
+// Request is the outbound request of the proxy,
+// it is prepared from the incoming request and passed to [ReverseProxy.Director].
+type Request struct {
+	// In is the incoming request received by the [plow.Server].
+	In plow.Request
+
+	// Method specifies the HTTP method of the outbound request.
+	Method specs.HttpMethod
+
+	// Url specifies the URL of the outbound request,
+	// it is a copy of the incoming request url.
+	Url *specs.Url
+
+	// Header contains the header fields and cookies of the outbound request,
+	// it is a copy of the incoming header without hop-by-hop headers.
+	// The [ReverseProxy] adds "X-Forwarded-For", "X-Forwarded-Host" and "X-Forwarded-Proto" headers.
+	Header *specs.Header
+}
+
+// newRequest prepares the outbound request from the incoming one.
+func newRequest(in plow.Request) *Request {
+	inUrl := in.Url()
+	url := *inUrl
+	url.Query = make(specs.Query, len(inUrl.Query))
+	for key, values := range inUrl.Query {
+		for _, value := range values {
+			url.Query.Add(key, value)
+		}
+	}
+
+	header := in.Header().Clone()
+	removeHopHeaders(header)
+
+	return &Request{
+		In:     in,
+		Method: in.Method(),
+		Url:    &url,
+		Header: header,
+	}
+}
+
+// newTransport returns [plow.DefaultTransport] without body size limit and read timeout,
+// so long-lived streaming responses are not interrupted.
+func newTransport() *plow.Transport {
+	transport := plow.DefaultTransport()
+	transport.MaxBodySize = 0
+	transport.ReadTimeout = 0
+	return transport
+}
+
+// exchange sends the outbound request to the target server
+// and prepares the response to be sent to the client.
+//
+// If upgrade protocol is provided, the connection is tunneled
+// to the target server after switching protocols.
+func exchange(
+	ctx context.Context, transport plow.RoundTripper, req *Request, upgrade string,
+	modifyResponse func(req *Request, resp plow.ClientResponse) error,
+) (plow.Response, error) {
+	var hijacker *plow.TransportHijacker
+	if upgrade != "" {
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", upgrade)
+		hijacker, ctx = plow.WithTransportHijacker(ctx)
+	}
+
+	var writer plow.BodyWriter
+	if body := req.In.Body(); body != nil && req.Method.IsPostable() {
+		inHeader := req.In.Header()
+		isChunked, contentLength, _ := parsing.ParseContentLength(inHeader)
+		if isChunked || contentLength < 0 || contentLength == 0 && !inHeader.Has("Content-Length") {
+			// Both headers must never be sent, the target could frame the body differently
+			req.Header.Del("Content-Length")
+			specs.WithChunkedEncoding(req.Header)
+			contentLength = -1
+		}
+		if contentLength != 0 {
+			writer = &bodyWriter{reader: body, contentLength: contentLength}
+		}
+	}
+
+	resp, err := transport.RoundTrip(ctx, req.Method, req.Url, req.Header, writer)
+	if err != nil {
+		return nil, err
+	}
+
+	var backendConn net.Conn
+	if hijacker != nil {
+		backendConn = hijacker.Conn
+	}
+
+	if resp.StatusCode() == specs.StatusCodeSwitchingProtocols {
+		if backendConn == nil || !strings.EqualFold(resp.Header().Get("Upgrade"), upgrade) {
+			if backendConn != nil {
+				backendConn.Close()
+			}
+			return nil, errors.New("proxy: target switched to unrequested protocol")
+		}
+	} else if backendConn != nil && resp.Body() == nil {
+		// Connection is closed with the body otherwise
+		backendConn.Close()
+		backendConn = nil
+	}
+
+	if modifyResponse != nil {
+		if err = modifyResponse(req, resp); err != nil {
+			if body := resp.Body(); body != nil {
+				body.Close()
+			}
+			if backendConn != nil {
+				backendConn.Close()
+			}
+			return nil, err
+		}
+	}
+
+	header := resp.Header()
+	removeHopHeaders(header)
+
+	if resp.StatusCode() == specs.StatusCodeSwitchingProtocols {
+		header.Set("Connection", "Upgrade")
+		header.Set("Upgrade", upgrade)
+		req.In.Hijack(func(ctx context.Context, conn net.Conn) {
+			tunnel(ctx, conn, backendConn)
+		})
+		return &response{code: resp.StatusCode(), header: header}, nil
+	}
+
+	body := resp.Body()
+	if body == nil {
+		return &response{code: resp.StatusCode(), header: header}, nil
+	}
+
+	// Body is decoded by the transport
+	if header.Has("Content-Encoding") {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+	}
+
+	contentLength := int64(-1)
+	if value, has := header.TryGet("Content-Length"); has {
+		if length, err := strconv.ParseInt(value, 10, 64); err == nil && length >= 0 {
+			contentLength = length
+		}
+	}
+	if contentLength < 0 {
+		header.Del("Content-Length")
+		specs.WithChunkedEncoding(header)
+	}
+
+	return &bodyResponse{
+		response:      response{code: resp.StatusCode(), header: header},
+		body:          body,
+		contentLength: contentLength,
+	}, nil
+}
+
+type bodyWriter struct {
+	reader        io.Reader
+	contentLength int64
+}
+
+func (w *bodyWriter) WriteBody(writer io.Writer) error {
+	_, err := io.Copy(writer, w.reader)
+	return err
+}
+
+func (w *bodyWriter) ContentLength() int64 {
+	return w.contentLength
+}
+
+type response struct {
+	code   specs.StatusCode
+	header *specs.Header
+}
+
+func (resp *response) StatusCode() specs.StatusCode {
+	return resp.code
+}
+
+func (resp *response) Header() *specs.Header {
+	return resp.header
+}
+
+type bodyResponse struct {
+	response
+	body          io.ReadCloser
+	contentLength int64
+}
+
+// WriteBody copies the response body from the target server,
+// written data is flushed to the client immediately for streaming responses.
+func (resp *bodyResponse) WriteBody(writer io.Writer) error {
+	defer resp.body.Close()
+
+	if flusher, ok := writer.(plow.Flusher); ok {
+		writer = &flushWriter{Writer: writer, flusher: flusher}
+	}
+	_, err := io.Copy(writer, resp.body)
+	return err
+}
+
+func (resp *bodyResponse) ContentLength() int64 {
+	return resp.contentLength
+}
+
+type flushWriter struct {
+	io.Writer
+	flusher plow.Flusher
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err == nil {
+		err = w.flusher.Flush()
+	}
+	return n, err
+}
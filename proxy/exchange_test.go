@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/mock"
+	"github.com/oesand/plow/specs"
+)
+
+func TestExchange_RequestBodyFraming(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        map[string]string
+		wantChunked   bool
+		wantLength    string
+		wantNoWriter  bool
+		wantWriterLen int64
+	}{
+		{"Sized", map[string]string{"Content-Length": "4"}, false, "4", false, 4},
+		{"Empty", map[string]string{"Content-Length": "0"}, false, "0", true, 0},
+		{"Chunked", map[string]string{"Transfer-Encoding": "chunked", "Content-Length": "4"}, true, "", false, -1},
+		{"Unknown", map[string]string{}, true, "", false, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := mock.DefaultRequest().
+				Method(specs.HttpMethodPost).
+				ConfHeader(func(header *specs.Header) {
+					for name, value := range tt.header {
+						header.Set(name, value)
+					}
+				}).
+				Body(io.NopCloser(strings.NewReader("body"))).
+				Request()
+
+			stop := errors.New("stop")
+			transport := plow.RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url,
+				header *specs.Header, writer plow.BodyWriter) (plow.ClientResponse, error) {
+				if chunked := header.Get("Transfer-Encoding") == "chunked"; chunked != tt.wantChunked {
+					t.Errorf("chunked = %v, want %v", chunked, tt.wantChunked)
+				}
+				if length := header.Get("Content-Length"); length != tt.wantLength {
+					t.Errorf("Content-Length = %q, want %q", length, tt.wantLength)
+				}
+				if (writer == nil) != tt.wantNoWriter {
+					t.Errorf("writer = %v, want none %v", writer, tt.wantNoWriter)
+				} else if writer != nil && writer.ContentLength() != tt.wantWriterLen {
+					t.Errorf("writer length = %d, want %d", writer.ContentLength(), tt.wantWriterLen)
+				}
+				return nil, stop
+			})
+
+			if _, err := exchange(context.Background(), transport, newRequest(in), "", nil); !errors.Is(err, stop) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/catch"
+	"github.com/oesand/plow/internal/client_ops"
+	iproxy "github.com/oesand/plow/internal/proxy"
+	"github.com/oesand/plow/specs"
+)
+
+// Policy decides whether the destination may be reached through the [ForwardProxy].
+type Policy interface {
+	// Allow reports whether the request of the client may be sent to the destination host and port.
+	Allow(ctx context.Context, request plow.Request, host string, port uint16) bool
+}
+
+// PolicyFunc shorthand implementation for [Policy]
+type PolicyFunc func(ctx context.Context, request plow.Request, host string, port uint16) bool
+
+// Allow triggers top level function [PolicyFunc]
+func (f PolicyFunc) Allow(ctx context.Context, request plow.Request, host string, port uint16) bool {
+	return f(ctx, request, host, port)
+}
+
+// AddressPolicy is an optional interface of the [Policy]
+// which decides whether the IP address resolved for the destination may be dialed,
+// so host names resolving to denied addresses can not be used to reach them.
+type AddressPolicy interface {
+	// AllowAddress reports whether the resolved IP address and port of the destination may be dialed.
+	AllowAddress(ctx context.Context, ip net.IP, port uint16) bool
+}
+
+// HostPolicy returns a [Policy] by lists of destination host patterns.
+//
+// A pattern is "*" for any host, an IP address, a CIDR such as "10.0.0.0/8",
+// a domain name which matches the domain and its subdomains,
+// or a domain with leading "." or "*." which matches only subdomains.
+// Domains and IP addresses may be followed by ":port" to match only the port.
+//
+// Deny patterns take precedence over allow patterns,
+// if allow list is empty, any host which is not denied is allowed.
+// Deny IP and CIDR patterns are also checked against the dialed address
+// by [AddressPolicy], so host names resolving into denied ranges are rejected.
+func HostPolicy(allow, deny []string) Policy {
+	return &hostPolicy{
+		allow: iproxy.ParseHostPatterns(allow),
+		deny:  iproxy.ParseHostPatterns(deny),
+	}
+}
+
+type hostPolicy struct {
+	allow, deny iproxy.HostPatterns
+}
+
+func (policy *hostPolicy) Allow(_ context.Context, _ plow.Request, host string, port uint16) bool {
+	if policy.deny.Match(host, port) {
+		return false
+	}
+	return len(policy.allow) == 0 || policy.allow.Match(host, port)
+}
+
+func (policy *hostPolicy) AllowAddress(_ context.Context, ip net.IP, port uint16) bool {
+	return !policy.deny.Match(ip.String(), port)
+}
+
+// NewForwardProxy returns a new ForwardProxy
+// which allows any destination without authentication.
+func NewForwardProxy() *ForwardProxy {
+	proxy := &ForwardProxy{}
+	proxy.Transport = proxy.newTransport()
+	return proxy
+}
+
+// ForwardProxy is a [plow.Handler] that acts as HTTP proxy for the clients
+// configured to use the [plow.Server] as their proxy.
+//
+// Requests with absolute-form url such as "GET http://example.com/ HTTP/1.1"
+// are sent to the destination, "CONNECT" requests are tunneled
+// to the destination through [plow.Request.Hijack].
+// Tunnels are supported only over HTTP/1.x connections.
+type ForwardProxy struct {
+	_ internal.NoCopy
+
+	// Transport specifies the mechanism by which outbound requests are made.
+	// If nil, [plow.DefaultTransport] without body size limit and read timeout is used,
+	// which dials only addresses allowed by [AddressPolicy] of the Policy.
+	Transport plow.RoundTripper
+
+	// Dialer specifies the dialer of "CONNECT" tunnel connections.
+	// If nil, [net.Dialer] is used, which dials only addresses
+	// allowed by [AddressPolicy] of the Policy.
+	Dialer plow.Dialer
+
+	// Authenticate is an optional function that validates credentials
+	// of "Proxy-Authorization" header with Basic scheme.
+	// If nil, authentication is not required.
+	Authenticate func(username, password string) bool
+
+	// Realm specifies the realm of "Proxy-Authenticate" header.
+	// If empty, "plow" is used.
+	Realm string
+
+	// Policy decides whether the destination may be reached.
+	// If nil, any destination is allowed.
+	Policy Policy
+
+	// ErrorHandler is an optional function that handles errors
+	// reaching the destination.
+	// If nil, [specs.StatusCodeBadGateway] is sent to the client,
+	// or [specs.StatusCodeGatewayTimeout] if the error is timeout.
+	ErrorHandler func(ctx context.Context, request plow.Request, err error) plow.Response
+
+	mu sync.Mutex
+}
+
+// Handle implements the [plow.Handler] interface.
+func (proxy *ForwardProxy) Handle(ctx context.Context, request plow.Request) plow.Response {
+	if proxy.Authenticate != nil && !proxy.authenticate(request) {
+		realm := proxy.Realm
+		if realm == "" {
+			realm = "plow"
+		}
+		return plow.EmptyResponse(specs.StatusCodeProxyAuthRequired, func(resp plow.Response) {
+			resp.Header().Set("Proxy-Authenticate", "Basic realm="+strconv.Quote(realm))
+		})
+	}
+
+	url := request.Url()
+	if request.Method() == specs.HttpMethodConnect {
+		if major, _ := request.ProtoVersion(); major != 1 {
+			return plow.TextResponse(specs.StatusCodeNotImplemented, specs.ContentTypePlain,
+				"proxy: tunnels are supported only over HTTP/1.x")
+		}
+		if url.Host == "" || url.Port == 0 {
+			return plow.TextResponse(specs.StatusCodeBadRequest, specs.ContentTypePlain,
+				"proxy: invalid tunnel destination")
+		}
+		if !proxy.allow(ctx, request, url.Host, url.Port) {
+			return plow.EmptyResponse(specs.StatusCodeForbidden)
+		}
+		return proxy.connect(ctx, request, url.Host, url.Port)
+	}
+
+	if url.Host == "" || !(url.Scheme == "http" || url.Scheme == "https") {
+		return plow.TextResponse(specs.StatusCodeBadRequest, specs.ContentTypePlain,
+			"proxy: absolute-form request url required")
+	}
+
+	port := url.Port
+	if port == 0 {
+		if url.Scheme == "https" {
+			port = 443
+		} else {
+			port = 80
+		}
+	}
+	if !proxy.allow(ctx, request, url.Host, port) {
+		return plow.EmptyResponse(specs.StatusCodeForbidden)
+	}
+
+	req := newRequest(request)
+	resp, err := exchange(ctx, proxy.transport(), req, upgradeType(request.Header()), nil)
+	if err != nil {
+		return proxy.handleError(ctx, request, err)
+	}
+	return resp
+}
+
+func (proxy *ForwardProxy) authenticate(request plow.Request) bool {
+	value, has := request.Header().TryGet("Proxy-Authorization")
+	if !has {
+		return false
+	}
+	username, password, err := specs.ParseBasicAuthHeader(value)
+	return err == nil && proxy.Authenticate(username, password)
+}
+
+func (proxy *ForwardProxy) allow(ctx context.Context, request plow.Request, host string, port uint16) bool {
+	return proxy.Policy == nil || proxy.Policy.Allow(ctx, request, host, port)
+}
+
+// connect dials the destination and tunnels the client connection to it.
+func (proxy *ForwardProxy) connect(ctx context.Context, request plow.Request, host string, port uint16) plow.Response {
+	address := client_ops.HostPort(host, port)
+
+	var conn net.Conn
+	var err error
+	if proxy.Dialer != nil {
+		conn, err = proxy.Dialer.Dial(ctx, "tcp", address)
+	} else {
+		conn, err = proxy.dialer().DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return proxy.handleError(ctx, request, err)
+	}
+
+	request.Hijack(func(ctx context.Context, clientConn net.Conn) {
+		tunnel(ctx, clientConn, conn)
+	})
+	return plow.EmptyResponse(specs.StatusCodeOK)
+}
+
+func (proxy *ForwardProxy) transport() plow.RoundTripper {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if proxy.Transport == nil {
+		proxy.Transport = proxy.newTransport()
+	}
+	return proxy.Transport
+}
+
+func (proxy *ForwardProxy) newTransport() *plow.Transport {
+	transport := newTransport()
+	transport.Dialer = plow.DialerFunc(proxy.dialer().DialContext)
+	return transport
+}
+
+var errAddressDenied = errors.New("proxy: destination address is denied")
+
+// dialer returns the dialer which checks the dialed address by [AddressPolicy] of the Policy.
+func (proxy *ForwardProxy) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 10 * time.Second,
+		ControlContext: func(ctx context.Context, _, address string, _ syscall.RawConn) error {
+			policy, ok := proxy.Policy.(AddressPolicy)
+			if !ok {
+				return nil
+			}
+			host, portStr, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			port, err := strconv.ParseUint(portStr, 10, 16)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !policy.AllowAddress(ctx, ip, uint16(port)) {
+				return errAddressDenied
+			}
+			return nil
+		},
+	}
+}
+
+func (proxy *ForwardProxy) handleError(ctx context.Context, request plow.Request, err error) plow.Response {
+	var opErr *specs.OpError
+	if errors.Is(err, errAddressDenied) || errors.As(err, &opErr) && errors.Is(opErr.Err, errAddressDenied) {
+		return plow.EmptyResponse(specs.StatusCodeForbidden)
+	}
+	if proxy.ErrorHandler != nil {
+		return proxy.ErrorHandler(ctx, request, err)
+	}
+	if errors.Is(catch.CatchCommonErr(err), specs.ErrTimeout) {
+		return plow.EmptyResponse(specs.StatusCodeGatewayTimeout)
+	}
+	return plow.EmptyResponse(specs.StatusCodeBadGateway)
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+)
+
+func newTestForwardProxy(t *testing.T, configure func(proxy *ForwardProxy)) (string, string) {
+	t.Helper()
+	backendAddr := serveTest(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		if request.Header().Has("Proxy-Authorization") {
+			t.Error("proxy credentials leaked to the backend")
+		}
+		return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "hello "+request.Url().Path)
+	}))
+
+	proxy := NewForwardProxy()
+	backendTransport := plow.DefaultTransport()
+	t.Cleanup(backendTransport.CloseIdleConnections)
+	proxy.Transport = backendTransport
+	if configure != nil {
+		configure(proxy)
+	}
+	return serveTest(t, proxy), backendAddr
+}
+
+func proxyClient(t *testing.T, proxyUrl string) *http.Client {
+	t.Helper()
+	parsed, err := url.Parse(proxyUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyURL(parsed)}
+	t.Cleanup(transport.CloseIdleConnections)
+	return &http.Client{Transport: transport}
+}
+
+func TestForwardProxy_AbsoluteForm(t *testing.T) {
+	proxyAddr, backendAddr := newTestForwardProxy(t, nil)
+	client := proxyClient(t, "http://"+proxyAddr)
+
+	resp, err := client.Get("http://" + backendAddr + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "hello /page" {
+		t.Errorf("unexpected response %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestForwardProxy_OriginForm(t *testing.T) {
+	proxyAddr, _ := newTestForwardProxy(t, nil)
+
+	resp, err := (&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}).Get("http://" + proxyAddr + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func connectTunnel(t *testing.T, proxyAddr, target, auth string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if auth != "" {
+		request += "Proxy-Authorization: " + auth + "\r\n"
+	}
+	if _, err = io.WriteString(conn, request+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, reader, resp
+}
+
+func TestForwardProxy_Connect(t *testing.T) {
+	proxyAddr, backendAddr := newTestForwardProxy(t, nil)
+
+	conn, reader, resp := connectTunnel(t, proxyAddr, backendAddr, "")
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	_, err := io.WriteString(conn, "GET /tunnel HTTP/1.1\r\nHost: "+backendAddr+"\r\nConnection: close\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "hello /tunnel" {
+		t.Errorf("unexpected tunneled response %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestForwardProxy_ConnectBadGateway(t *testing.T) {
+	proxyAddr, _ := newTestForwardProxy(t, nil)
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	_, _, resp := connectTunnel(t, proxyAddr, closedAddr, "")
+	if resp.StatusCode != 502 {
+		t.Errorf("expected 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestForwardProxy_Authenticate(t *testing.T) {
+	proxyAddr, backendAddr := newTestForwardProxy(t, func(proxy *ForwardProxy) {
+		proxy.Realm = "test"
+		proxy.Authenticate = func(username, password string) bool {
+			return username == "user" && password == "secret"
+		}
+	})
+
+	resp, err := proxyClient(t, "http://"+proxyAddr).Get("http://" + backendAddr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 407 || resp.Header.Get("Proxy-Authenticate") != `Basic realm="test"` {
+		t.Errorf("expected 407 with challenge, got %d %+v", resp.StatusCode, resp.Header)
+	}
+
+	resp, err = proxyClient(t, "http://user:wrong@"+proxyAddr).Get("http://" + backendAddr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 407 {
+		t.Errorf("expected 407 for wrong password, got %d", resp.StatusCode)
+	}
+
+	resp, err = proxyClient(t, "http://user:secret@"+proxyAddr).Get("http://" + backendAddr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 with credentials, got %d", resp.StatusCode)
+	}
+
+	_, _, resp = connectTunnel(t, proxyAddr, backendAddr, specs.BasicAuthHeader("user", "secret"))
+	if resp.StatusCode != 200 {
+		t.Errorf("expected tunnel with credentials, got %d", resp.StatusCode)
+	}
+}
+
+func TestForwardProxy_Policy(t *testing.T) {
+	proxyAddr, backendAddr := newTestForwardProxy(t, func(proxy *ForwardProxy) {
+		proxy.Policy = HostPolicy([]string{"127.0.0.0/8", "example.com"}, []string{"127.0.0.1"})
+	})
+
+	resp, err := proxyClient(t, "http://"+proxyAddr).Get("http://" + backendAddr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 403 {
+		t.Errorf("expected 403 for denied host, got %d", resp.StatusCode)
+	}
+
+	_, _, resp = connectTunnel(t, proxyAddr, "other.org:443", "")
+	if resp.StatusCode != 403 {
+		t.Errorf("expected 403 for not allowed host, got %d", resp.StatusCode)
+	}
+}
+
+func TestForwardProxy_PolicyResolvedAddress(t *testing.T) {
+	proxyAddr, backendAddr := newTestForwardProxy(t, func(proxy *ForwardProxy) {
+		proxy.Transport = nil
+		proxy.Policy = HostPolicy(nil, []string{"127.0.0.0/8", "::1"})
+	})
+	_, port, _ := net.SplitHostPort(backendAddr)
+
+	resp, err := proxyClient(t, "http://"+proxyAddr).Get("http://localhost:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 403 {
+		t.Errorf("expected 403 for host resolving into denied range, got %d", resp.StatusCode)
+	}
+
+	_, _, resp = connectTunnel(t, proxyAddr, "localhost:"+port, "")
+	if resp.StatusCode != 403 {
+		t.Errorf("expected 403 for tunnel to host resolving into denied range, got %d", resp.StatusCode)
+	}
+}
+
+func TestHostPolicy(t *testing.T) {
+	policy := HostPolicy([]string{"example.com"}, []string{"admin.example.com", "example.com:8080"})
+	tests := []struct {
+		host  string
+		port  uint16
+		allow bool
+	}{
+		{"example.com", 443, true},
+		{"api.example.com", 80, true},
+		{"admin.example.com", 443, false},
+		{"example.com", 8080, false},
+		{"other.org", 80, false},
+	}
+	for _, tt := range tests {
+		if got := policy.Allow(context.Background(), nil, tt.host, tt.port); got != tt.allow {
+			t.Errorf("Allow(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.allow)
+		}
+	}
+
+	if !HostPolicy(nil, []string{"blocked.org"}).Allow(context.Background(), nil, "any.com", 80) {
+		t.Error("expected empty allow list to allow not denied hosts")
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oesand/plow/specs"
+)
+
+// hopHeaders are the headers of a single transport-level connection,
+// they must not be forwarded by proxies, RFC 9110 section 7.6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopHeaders removes hop-by-hop headers
+// and the headers listed by "Connection" header.
+func removeHopHeaders(header *specs.Header) {
+	for _, connection := range header.Values("Connection") {
+		for name := range strings.SplitSeq(connection, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// upgradeType returns the protocol of "Upgrade" header
+// if the connection upgrade is requested.
+func upgradeType(header *specs.Header) string {
+	for _, connection := range header.Values("Connection") {
+		if hasToken(connection, "Upgrade") {
+			return header.Get("Upgrade")
+		}
+	}
+	return ""
+}
+
+// hasToken reports whether comma-separated header value contains the token.
+func hasToken(value, token string) bool {
+	for part := range strings.SplitSeq(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the host of the client address without port.
+func clientIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/catch"
+	"github.com/oesand/plow/specs"
+)
+
+// NewReverseProxy returns a new ReverseProxy that routes requests to the target url.
+//
+// The request path is appended to the target path
+// and the target query values are added to the request query.
+func NewReverseProxy(target *specs.Url) *ReverseProxy {
+	if target == nil {
+		panic("plow: passed nil target url")
+	}
+	if !(target.Scheme == "http" || target.Scheme == "https") || target.Host == "" {
+		panic("plow: invalid reverse proxy target url '" + target.String() + "'")
+	}
+
+	return &ReverseProxy{
+		Transport: newTransport(),
+		Director: func(req *Request) {
+			req.Url.Scheme = target.Scheme
+			req.Url.Host = target.Host
+			req.Url.Port = target.Port
+			req.Url.Username, req.Url.Password = target.Username, target.Password
+			req.Url.Path = joinPath(target.Path, req.Url.Path)
+			req.Url.PathSegments = nil
+			req.Url.Fragment = ""
+
+			for key, values := range target.Query {
+				for _, value := range values {
+					req.Url.Query.Add(key, value)
+				}
+			}
+		},
+	}
+}
+
+// ReverseProxy is a [plow.Handler] that takes an incoming request and
+// sends it to another server, proxying the response back to the client.
+//
+// Request and response bodies are streamed without buffering,
+// connection upgrades such as WebSocket are tunneled
+// through [plow.Request.Hijack] and [plow.WithTransportHijacker].
+type ReverseProxy struct {
+	_ internal.NoCopy
+
+	// Transport specifies the mechanism by which outbound requests are made.
+	// If nil, [plow.DefaultTransport] without body size limit and read timeout is used.
+	Transport plow.RoundTripper
+
+	// Director modifies the outbound request, at least url scheme and host
+	// must be rewritten to the target server.
+	Director func(req *Request)
+
+	// ModifyResponse is an optional function that modifies
+	// the response from the target server.
+	// If it returns an error, [ReverseProxy.ErrorHandler] is called.
+	ModifyResponse func(req *Request, resp plow.ClientResponse) error
+
+	// ErrorHandler is an optional function that handles errors
+	// reaching the target server or from [ReverseProxy.ModifyResponse].
+	// If nil, [specs.StatusCodeBadGateway] is sent to the client,
+	// or [specs.StatusCodeGatewayTimeout] if the error is timeout.
+	ErrorHandler func(ctx context.Context, request plow.Request, err error) plow.Response
+
+	mu sync.Mutex
+}
+
+// Handle implements the [plow.Handler] interface.
+func (proxy *ReverseProxy) Handle(ctx context.Context, request plow.Request) plow.Response {
+	if proxy.Director == nil {
+		panic("plow: nil ReverseProxy.Director")
+	}
+
+	req := newRequest(request)
+	setForwardedHeaders(ctx, req)
+	upgrade := upgradeType(request.Header())
+
+	proxy.Director(req)
+
+	resp, err := exchange(ctx, proxy.transport(), req, upgrade, proxy.ModifyResponse)
+	if err != nil {
+		return proxy.handleError(ctx, request, err)
+	}
+	return resp
+}
+
+func (proxy *ReverseProxy) transport() plow.RoundTripper {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if proxy.Transport == nil {
+		proxy.Transport = newTransport()
+	}
+	return proxy.Transport
+}
+
+func (proxy *ReverseProxy) handleError(ctx context.Context, request plow.Request, err error) plow.Response {
+	if proxy.ErrorHandler != nil {
+		return proxy.ErrorHandler(ctx, request, err)
+	}
+	if errors.Is(catch.CatchCommonErr(err), specs.ErrTimeout) {
+		return plow.EmptyResponse(specs.StatusCodeGatewayTimeout)
+	}
+	return plow.EmptyResponse(specs.StatusCodeBadGateway)
+}
+
+// setForwardedHeaders adds "X-Forwarded-*" headers of the client to the outbound request.
+func setForwardedHeaders(ctx context.Context, req *Request) {
+	header := req.Header
+	if ip := clientIP(req.In.RemoteAddr()); ip != "" {
+		if prior := header.Values("X-Forwarded-For"); len(prior) > 0 {
+			ip = strings.Join(prior, ", ") + ", " + ip
+		}
+		header.Set("X-Forwarded-For", ip)
+	}
+	if host := req.In.Header().Get("Host"); host != "" {
+		header.Set("X-Forwarded-Host", host)
+	}
+	if plow.TLSConnectionState(ctx) != nil {
+		header.Set("X-Forwarded-Proto", "https")
+	} else {
+		header.Set("X-Forwarded-Proto", "http")
+	}
+}
+
+// joinPath joins the target path and the request path with a single slash.
+func joinPath(base, path string) string {
+	if base == "" || base == "/" {
+		if path == "" {
+			return "/"
+		}
+		return path
+	}
+	if path == "" || path == "/" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+	"github.com/oesand/plow/ws"
+)
+
+func serveTest(t *testing.T, handler plow.Handler) string {
+	t.Helper()
+	server := plow.DefaultServer(handler)
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		// Serve returns after the listener is closed,
+		// so shutdown state is not accessed concurrently
+		listener.Close()
+		<-served
+		server.Shutdown()
+	})
+	return listener.Addr().String()
+}
+
+func newTestReverseProxy(t *testing.T, backend plow.Handler) (*ReverseProxy, string, *http.Client) {
+	t.Helper()
+	backendAddr := serveTest(t, backend)
+
+	proxy := NewReverseProxy(specs.MustParseUrl("http://" + backendAddr + "/api?key=base"))
+	backendTransport := plow.DefaultTransport()
+	t.Cleanup(backendTransport.CloseIdleConnections)
+	proxy.Transport = backendTransport
+
+	transport := &http.Transport{DisableKeepAlives: true}
+	t.Cleanup(transport.CloseIdleConnections)
+	return proxy, "http://" + serveTest(t, proxy), &http.Client{Transport: transport}
+}
+
+func TestReverseProxy_Forward(t *testing.T) {
+	_, url, client := newTestReverseProxy(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		header := request.Header()
+		if request.Url().Path != "/api/items" || request.Url().Query.Get("key") != "base" || request.Url().Query.Get("id") != "7" {
+			t.Errorf("unexpected url %s", request.Url())
+		}
+		if header.Get("X-Forwarded-For") != "10.0.0.1, 127.0.0.1" ||
+			header.Get("X-Forwarded-Proto") != "http" ||
+			!strings.HasPrefix(header.Get("X-Forwarded-Host"), "127.0.0.1:") {
+			t.Errorf("unexpected forwarded headers %+v", header)
+		}
+		if header.Has("X-Hop") || header.Has("Keep-Alive") || header.Get("X-End") != "yes" {
+			t.Errorf("unexpected hop-by-hop headers %+v", header)
+		}
+		return plow.TextResponse(specs.StatusCodeCreated, specs.ContentTypePlain, "created", func(resp plow.Response) {
+			resp.Header().Set("X-Backend", "1")
+			resp.Header().Set("Keep-Alive", "timeout=5")
+			resp.Header().SetCookieValue("session", "abc")
+		})
+	}))
+
+	req, _ := http.NewRequest("GET", url+"/items?id=7", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "secret")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-End", "yes")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 201 || string(body) != "created" {
+		t.Errorf("unexpected response %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Backend") != "1" || resp.Header.Get("Keep-Alive") != "" ||
+		len(resp.Cookies()) != 1 || resp.Cookies()[0].Value != "abc" {
+		t.Errorf("unexpected response headers %+v", resp.Header)
+	}
+}
+
+func TestReverseProxy_StreamBody(t *testing.T) {
+	_, url, client := newTestReverseProxy(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		data, err := io.ReadAll(request.Body())
+		if err != nil {
+			t.Error(err)
+		}
+		return plow.BufferResponse(specs.StatusCodeOK, specs.ContentTypeRaw, bytes.ToUpper(data))
+	}))
+
+	tests := []struct {
+		name string
+		body io.Reader
+		want string
+	}{
+		{"Sized", strings.NewReader("sized body"), "SIZED BODY"},
+		{"Chunked", io.MultiReader(strings.NewReader("chunked "), strings.NewReader("body")), "CHUNKED BODY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Post(url+"/echo", specs.ContentTypeRaw, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != 200 || string(body) != tt.want {
+				t.Errorf("unexpected response %d %q", resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+type flushedResponse struct {
+	plow.Response
+	release chan struct{}
+}
+
+func (resp *flushedResponse) WriteBody(writer io.Writer) error {
+	writer.Write([]byte("first"))
+	if flusher, ok := writer.(plow.Flusher); ok {
+		flusher.Flush()
+	}
+	<-resp.release
+	_, err := writer.Write([]byte("second"))
+	return err
+}
+
+func (resp *flushedResponse) ContentLength() int64 {
+	return -1
+}
+
+func TestReverseProxy_StreamResponse(t *testing.T) {
+	release := make(chan struct{})
+	_, url, client := newTestReverseProxy(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return &flushedResponse{
+			Response: plow.EmptyResponse(specs.StatusCodeOK, func(resp plow.Response) {
+				resp.Header().Set("Content-Type", specs.ContentTypePlain)
+				specs.WithChunkedEncoding(resp.Header())
+			}),
+			release: release,
+		}
+	}))
+
+	resp, err := client.Get(url + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// First part arrives before the backend completes the body
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first" {
+		t.Fatalf("unexpected first part %q: %v", buf, err)
+	}
+	close(release)
+
+	rest, _ := io.ReadAll(resp.Body)
+	if string(rest) != "second" {
+		t.Errorf("unexpected rest %q", rest)
+	}
+}
+
+func TestReverseProxy_ModifyResponse(t *testing.T) {
+	proxy, url, client := newTestReverseProxy(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "ok")
+	}))
+
+	proxy.ModifyResponse = func(req *Request, resp plow.ClientResponse) error {
+		if req.In.Url().Path == "/fail" {
+			return errors.New("rejected")
+		}
+		resp.Header().Set("X-Modified", "true")
+		return nil
+	}
+	proxy.ErrorHandler = func(ctx context.Context, request plow.Request, err error) plow.Response {
+		return plow.TextResponse(specs.StatusCodeServiceUnavailable, specs.ContentTypePlain, err.Error())
+	}
+
+	resp, err := client.Get(url + "/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Modified") != "true" {
+		t.Errorf("expected modified response, %+v", resp.Header)
+	}
+
+	resp, err = client.Get(url + "/fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || string(body) != "rejected" {
+		t.Errorf("expected error handler response, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestReverseProxy_BadGateway(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	proxy := NewReverseProxy(specs.MustParseUrl("http://" + addr))
+	url := "http://" + serveTest(t, proxy)
+
+	transport := &http.Transport{DisableKeepAlives: true}
+	t.Cleanup(transport.CloseIdleConnections)
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 502 {
+		t.Errorf("expected bad gateway, got %d", resp.StatusCode)
+	}
+}
+
+func TestReverseProxy_WebSocket(t *testing.T) {
+	upgrader := ws.DefaultUpgrader()
+	_, url, _ := newTestReverseProxy(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return upgrader.Upgrade(request, func(ctx context.Context, conn ws.Conn) {
+			for {
+				// Every message is read until EOF
+				message, err := io.ReadAll(conn)
+				if err != nil {
+					return
+				}
+				if _, err = conn.Write(bytes.ToUpper(message)); err != nil {
+					return
+				}
+			}
+		})
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wsUrl := specs.MustParseUrl(strings.Replace(url, "http://", "ws://", 1) + "/socket")
+	conn, err := ws.DefaultDialer().DialContext(ctx, plow.DefaultClient(), wsUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, message := range []string{"hello", "proxy"} {
+		if _, err = conn.WriteText(message); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(reply) != strings.ToUpper(message) {
+			t.Errorf("expected %q, got %q", strings.ToUpper(message), reply)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		base, path, want string
+	}{
+		{"", "/a", "/a"},
+		{"/", "", "/"},
+		{"/api", "", "/api"},
+		{"/api", "/", "/api"},
+		{"/api", "/a/b", "/api/a/b"},
+		{"/api/", "/a", "/api/a"},
+	}
+	for _, tt := range tests {
+		if got := joinPath(tt.base, tt.path); got != tt.want {
+			t.Errorf("joinPath(%q, %q) = %q, want %q", tt.base, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/internal"
+	iproxy "github.com/oesand/plow/internal/proxy"
+	"github.com/oesand/plow/specs"
+)
+
+// NewSocks5Server returns a new Socks5Server
+// which accepts clients without authentication.
+func NewSocks5Server() *Socks5Server {
+	return &Socks5Server{
+		HandshakeTimeout: 10 * time.Second,
+		UDPIdleTimeout:   time.Minute,
+	}
+}
+
+// Socks5Server is a SOCKS5 proxy server of RFC 1928,
+// which can be used as a proxy with "socks5" and "socks5h" schemes of [plow.Transport.Proxy].
+//
+// Supports "CONNECT" command and optionally "UDP ASSOCIATE",
+// "BIND" command is not supported.
+// The zero value for Socks5Server is a valid configuration.
+type Socks5Server struct {
+	_ internal.NoCopy
+
+	// Dialer specifies the dialer of outbound TCP and UDP connections.
+	// If nil, [net.Dialer] is used.
+	Dialer plow.Dialer
+
+	// Authenticate is an optional function that validates
+	// username and password of RFC 1929 authentication.
+	// If nil, authentication is not required.
+	Authenticate func(username, password string) bool
+
+	// EnableUDP enables "UDP ASSOCIATE" command,
+	// datagrams of the client are relayed through the UDP socket
+	// bound on the same address as the TCP connection
+	// while the TCP connection is open.
+	//
+	// Fragmented datagrams are not supported and dropped.
+	EnableUDP bool
+
+	// UDPIdleTimeout is the maximum amount of time to keep
+	// outbound UDP connection without datagrams.
+	// If zero, there is no timeout.
+	UDPIdleTimeout time.Duration
+
+	// HandshakeTimeout is the maximum amount of time to wait for
+	// the handshake, authentication and command of the client.
+	// If zero, there is no timeout.
+	HandshakeTimeout time.Duration
+
+	// FilterConn handles all new incoming connections to provide filtering by address
+	// Returns true - accept, false - close connection
+	FilterConn func(addr net.Addr) bool
+
+	// ErrorHandler is an optional handler of errors
+	// that occur during the handshake and handling of the command.
+	ErrorHandler plow.ErrorHandler
+
+	listenerTrack sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+	once          sync.Once
+}
+
+func (srv *Socks5Server) beforeOnce() {
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+}
+
+// ListenAndServe listens on the TCP network address and then
+// calls [Socks5Server.Serve] to handle incoming connections.
+//
+// If addr is blank, ":1080" is used.
+//
+// ListenAndServe always returns a non-nil error.
+// After [Socks5Server.Shutdown], the returned error is [specs.ErrClosed].
+func (srv *Socks5Server) ListenAndServe(addr string) error {
+	if srv.IsShutdown() {
+		return specs.ErrClosed
+	} else if addr == "" {
+		addr = ":" + strconv.Itoa(int(iproxy.DefaultSocks5Port))
+	}
+	lst, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(lst)
+}
+
+// Serve accepts incoming connections on the [net.Listener], creating a
+// new service goroutine for each, the listener is closed on return.
+//
+// Serve always returns a non-nil error.
+// After [Socks5Server.Shutdown], the returned error is [specs.ErrClosed].
+func (srv *Socks5Server) Serve(listener net.Listener) error {
+	if listener == nil {
+		panic("plow: nil listener")
+	}
+	srv.once.Do(srv.beforeOnce)
+	if srv.IsShutdown() {
+		return specs.ErrClosed
+	}
+
+	srv.listenerTrack.Add(1)
+	defer srv.listenerTrack.Done()
+
+	stop := context.AfterFunc(srv.ctx, func() { listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	var attemptDelay time.Duration
+	var connTrack sync.WaitGroup
+	var err error
+	for {
+		var conn net.Conn
+		conn, err = listener.Accept()
+
+		if err != nil {
+			if srv.IsShutdown() {
+				err = specs.ErrClosed
+				break
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if attemptDelay == 0 {
+					attemptDelay = 5 * time.Millisecond
+				} else if maxDelay := 1 * time.Second; attemptDelay >= maxDelay {
+					attemptDelay = maxDelay
+				} else {
+					attemptDelay *= 2
+				}
+
+				time.Sleep(attemptDelay)
+				continue
+			}
+			break
+		}
+
+		attemptDelay = 0
+		if srv.FilterConn != nil {
+			if allow := srv.FilterConn(conn.RemoteAddr()); !allow {
+				conn.Close()
+				continue
+			}
+		}
+
+		connTrack.Add(1)
+		go func(conn net.Conn) {
+			defer connTrack.Done()
+			defer conn.Close()
+
+			if err := srv.handle(srv.ctx, conn); err != nil && srv.ErrorHandler != nil {
+				srv.ErrorHandler.HandleError(srv.ctx, conn, err)
+			}
+		}(conn)
+	}
+
+	connTrack.Wait()
+	return err
+}
+
+// IsShutdown checks if the server is shutting down
+// or is already shut down after calling [Socks5Server.Shutdown]
+func (srv *Socks5Server) IsShutdown() bool {
+	srv.once.Do(srv.beforeOnce)
+	return srv.ctx.Err() != nil
+}
+
+// Shutdown closes all listeners and active connections
+// including tunnels and UDP associations, and waits until
+// all [Socks5Server.Serve] calls return.
+//
+// Once Shutdown has been called on a server, it may not be reused;
+// future calls to methods such as Serve will return [specs.ErrClosed].
+func (srv *Socks5Server) Shutdown() {
+	srv.once.Do(srv.beforeOnce)
+	srv.cancel()
+
+	srv.listenerTrack.Wait()
+}
+
+func (srv *Socks5Server) handle(ctx context.Context, conn net.Conn) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if srv.HandshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(srv.HandshakeTimeout))
+	}
+
+	req, err := iproxy.AcceptSocks5(conn, srv.Authenticate)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case req.Command == iproxy.Socks5CmdConnect:
+		return srv.connect(ctx, conn, req)
+	case req.Command == iproxy.Socks5CmdUDPAssociate && srv.EnableUDP:
+		return srv.associate(ctx, conn, req)
+	default:
+		iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplyCommandNotSupported, nil)
+		return errors.New("socks5: command not supported")
+	}
+}
+
+func (srv *Socks5Server) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if srv.Dialer != nil {
+		return srv.Dialer.Dial(ctx, network, address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// connect dials the destination and tunnels the client connection to it.
+func (srv *Socks5Server) connect(ctx context.Context, conn net.Conn, req *iproxy.Socks5Request) error {
+	target, err := srv.dial(ctx, "tcp", req.Address())
+	if err != nil {
+		iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplyCode(err), nil)
+		return err
+	}
+
+	if err = iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplySucceeded, target.LocalAddr()); err != nil {
+		target.Close()
+		return err
+	}
+
+	tunnel(ctx, conn, target)
+	return nil
+}
+
+// associate relays datagrams of the client while the connection is open.
+func (srv *Socks5Server) associate(ctx context.Context, conn net.Conn, req *iproxy.Socks5Request) error {
+	localAddr, _ := conn.LocalAddr().(*net.TCPAddr)
+	remoteAddr, _ := conn.RemoteAddr().(*net.TCPAddr)
+	if localAddr == nil || remoteAddr == nil {
+		iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplyGeneralFailure, nil)
+		return errors.New("socks5: udp associate supported only over tcp connections")
+	}
+
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: localAddr.IP, Zone: localAddr.Zone})
+	if err != nil {
+		iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplyGeneralFailure, nil)
+		return err
+	}
+
+	if err = iproxy.WriteSocks5Reply(conn, iproxy.Socks5ReplySucceeded, relay.LocalAddr()); err != nil {
+		relay.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(ctx)
+	association := &udpAssociation{
+		srv:    srv,
+		relay:  relay,
+		ip:     remoteAddr.IP,
+		port:   int(req.Port),
+		conns:  map[string]net.Conn{},
+		cancel: cancel,
+	}
+
+	go func() {
+		// Association terminates when the control connection is closed
+		var buf [1]byte
+		for {
+			if _, err := conn.Read(buf[:]); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	association.serve(ctx)
+	return nil
+}
+
+type udpAssociation struct {
+	srv   *Socks5Server
+	relay *net.UDPConn
+
+	// ip and port of the client,
+	// zero port means the port of the first datagram
+	ip   net.IP
+	port int
+
+	mu     sync.Mutex
+	client *net.UDPAddr
+	conns  map[string]net.Conn
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func (a *udpAssociation) serve(ctx context.Context) {
+	stop := context.AfterFunc(ctx, func() {
+		a.relay.Close()
+		a.mu.Lock()
+		for _, conn := range a.conns {
+			conn.Close()
+		}
+		a.mu.Unlock()
+	})
+	defer stop()
+	defer a.wg.Wait()
+	defer a.cancel()
+
+	buf := make([]byte, 64<<10)
+	for {
+		n, addr, err := a.relay.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !a.fromClient(addr) {
+			continue
+		}
+
+		host, port, payload, err := iproxy.ParseSocks5Datagram(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		conn, err := a.conn(ctx, net.JoinHostPort(host, strconv.Itoa(int(port))), host, port)
+		if err != nil {
+			continue
+		}
+		if a.srv.UDPIdleTimeout > 0 {
+			conn.SetDeadline(time.Now().Add(a.srv.UDPIdleTimeout))
+		}
+		conn.Write(payload)
+	}
+}
+
+// fromClient checks the datagram source is the client
+// and remembers the client address.
+func (a *udpAssociation) fromClient(addr *net.UDPAddr) bool {
+	if !addr.IP.Equal(a.ip) || a.port != 0 && addr.Port != a.port {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client == nil {
+		a.client = addr
+	}
+	return a.client.Port == addr.Port
+}
+
+// conn returns outbound connection to the destination, dialing it if not exists.
+func (a *udpAssociation) conn(ctx context.Context, address, host string, port uint16) (net.Conn, error) {
+	a.mu.Lock()
+	conn, has := a.conns[address]
+	a.mu.Unlock()
+	if has {
+		return conn, nil
+	}
+
+	conn, err := a.srv.dial(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if ctx.Err() != nil {
+		a.mu.Unlock()
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	a.conns[address] = conn
+	a.mu.Unlock()
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer func() {
+			a.mu.Lock()
+			delete(a.conns, address)
+			a.mu.Unlock()
+			conn.Close()
+		}()
+
+		buf := make([]byte, 64<<10)
+		var packet []byte
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			if a.srv.UDPIdleTimeout > 0 {
+				conn.SetDeadline(time.Now().Add(a.srv.UDPIdleTimeout))
+			}
+
+			packet, err = iproxy.AppendSocks5Datagram(packet[:0], host, port, buf[:n])
+			if err != nil {
+				return
+			}
+
+			a.mu.Lock()
+			client := a.client
+			a.mu.Unlock()
+			a.relay.WriteToUDP(packet, client)
+		}
+	}()
+	return conn, nil
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow"
+	iproxy "github.com/oesand/plow/internal/proxy"
+	"github.com/oesand/plow/specs"
+)
+
+func serveTestSocks5(t *testing.T, srv *Socks5Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+	t.Cleanup(srv.Shutdown)
+	return listener.Addr().String()
+}
+
+func TestSocks5Server_Transport(t *testing.T) {
+	backendAddr := serveTest(t, plow.HandlerFunc(func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "hello "+request.Url().Path)
+	}))
+
+	srv := NewSocks5Server()
+	srv.Authenticate = func(username, password string) bool {
+		return username == "user" && password == "secret"
+	}
+	proxyAddr := serveTestSocks5(t, srv)
+
+	for _, scheme := range []string{"socks5", "socks5h"} {
+		t.Run(scheme, func(t *testing.T) {
+			transport := plow.DefaultTransport()
+			t.Cleanup(transport.CloseIdleConnections)
+			transport.Proxy = plow.FixedProxyUrl(specs.MustParseUrl(scheme + "://user:secret@" + proxyAddr))
+
+			resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+				specs.MustParseUrl("http://"+backendAddr+"/page"), specs.NewHeader(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body())
+			if resp.StatusCode() != specs.StatusCodeOK || string(body) != "hello /page" {
+				t.Errorf("unexpected response %d %q", resp.StatusCode(), body)
+			}
+		})
+	}
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		transport := plow.DefaultTransport()
+		transport.Proxy = plow.FixedProxyUrl(specs.MustParseUrl("socks5://user:wrong@" + proxyAddr))
+
+		_, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+			specs.MustParseUrl("http://"+backendAddr+"/"), specs.NewHeader(), nil)
+		if err == nil || !strings.Contains(err.Error(), "authentication failed") {
+			t.Errorf("expected authentication error, got %v", err)
+		}
+	})
+}
+
+func TestSocks5Server_Dialer(t *testing.T) {
+	var dialed string
+	srv := NewSocks5Server()
+	srv.Dialer = plow.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = network + " " + address
+		client, server := net.Pipe()
+		go func() {
+			io.WriteString(server, "pong")
+			server.Close()
+		}()
+		return client, nil
+	})
+	proxyAddr := serveTestSocks5(t, srv)
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = iproxy.DialSocks5(conn, "example.com", 443, nil); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(conn)
+	if string(body) != "pong" || dialed != "tcp example.com:443" {
+		t.Errorf("unexpected tunnel %q to %q", body, dialed)
+	}
+}
+
+func TestSocks5Server_ConnectRefused(t *testing.T) {
+	proxyAddr := serveTestSocks5(t, NewSocks5Server())
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = iproxy.DialSocks5(conn, closedAddr.IP.String(), uint16(closedAddr.Port), nil)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected connection refused, got %v", err)
+	}
+}
+
+func TestSocks5Server_UDPAssociate(t *testing.T) {
+	echo, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteToUDP(append([]byte("echo "), buf[:n]...), addr)
+		}
+	}()
+	echoAddr := echo.LocalAddr().(*net.UDPAddr)
+
+	srv := NewSocks5Server()
+	proxyAddr := serveTestSocks5(t, srv)
+
+	associate := func(t *testing.T) ([]byte, net.Conn) {
+		conn, err := net.Dial("tcp", proxyAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		conn.Write([]byte{5, 1, 0})
+		reply := make([]byte, 10)
+		if _, err = io.ReadFull(conn, reply[:2]); err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte{5, 3, 0, 1, 0, 0, 0, 0, 0, 0})
+		if _, err = io.ReadFull(conn, reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply, conn
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		srv.EnableUDP = false
+		if reply, _ := associate(t); reply[1] != iproxy.Socks5ReplyCommandNotSupported {
+			t.Errorf("expected command not supported, got %d", reply[1])
+		}
+	})
+
+	t.Run("Relay", func(t *testing.T) {
+		srv.EnableUDP = true
+		reply, _ := associate(t)
+		if reply[1] != iproxy.Socks5ReplySucceeded {
+			t.Fatalf("unexpected reply code %d", reply[1])
+		}
+		relayAddr := &net.UDPAddr{IP: net.IP(reply[4:8]), Port: int(reply[8])<<8 | int(reply[9])}
+
+		client, err := net.DialUDP("udp4", nil, relayAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		packet, _ := iproxy.AppendSocks5Datagram(nil, echoAddr.IP.String(), uint16(echoAddr.Port), []byte("ping"))
+		if _, err = client.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 1024)
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, port, payload, err := iproxy.ParseSocks5Datagram(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if net.JoinHostPort(host, strconv.Itoa(int(port))) != echoAddr.String() || string(payload) != "echo ping" {
+			t.Errorf("unexpected datagram from %s:%d %q", host, port, payload)
+		}
+	})
+}
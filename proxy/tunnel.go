@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// tunnel copies data between connections in both directions
+// until one of them is closed or the context is done,
+// both connections are closed on return.
+func tunnel(ctx context.Context, conn, target net.Conn) {
+	conn.SetDeadline(time.Time{})
+	target.SetDeadline(time.Time{})
+
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			conn.Close()
+			target.Close()
+		})
+	}
+
+	stop := context.AfterFunc(ctx, closeBoth)
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(target, conn)
+		closeBoth()
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(conn, target)
+		closeBoth()
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,266 @@
+package plow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oesand/plow/internal/client_ops"
+	"github.com/oesand/plow/internal/proxy"
+	"github.com/oesand/plow/specs"
+)
+
+// ErrNoProxyAvailable is returned by [ProxySelector.Proxy]
+// when all proxies of the matched rule are unreachable.
+var ErrNoProxyAvailable = errors.New("plow: no proxy available")
+
+// ProxyFromEnvironment returns a proxy function (for use in a [Transport])
+// that uses the "HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY" and "NO_PROXY"
+// environment variables or the lowercase versions thereof.
+//
+// "HTTPS_PROXY" takes precedence over "HTTP_PROXY" for https requests,
+// "ALL_PROXY" is used when the variable of the request scheme is empty.
+// The proxy url may be either a complete url or a "host[:port]",
+// in which case the "http" scheme is assumed.
+//
+// "NO_PROXY" is a comma-separated list of hosts which should not use the proxy,
+// see [ProxyRule.Hosts] for the format of patterns, "*" disables the proxy for all hosts.
+// Requests to "localhost" and loopback addresses never use the proxy.
+//
+// Environment is read once on the call of ProxyFromEnvironment.
+func ProxyFromEnvironment() func(*specs.Url) (*specs.Url, error) {
+	httpProxy := getEnvAny("HTTP_PROXY", "http_proxy")
+	httpsProxy := getEnvAny("HTTPS_PROXY", "https_proxy")
+	allProxy := getEnvAny("ALL_PROXY", "all_proxy")
+	noProxy := proxy.ParseHostPatterns(strings.Split(getEnvAny("NO_PROXY", "no_proxy"), ","))
+
+	if httpProxy == "" {
+		httpProxy = allProxy
+	}
+	if httpsProxy == "" {
+		httpsProxy = allProxy
+	}
+
+	return func(url *specs.Url) (*specs.Url, error) {
+		value := httpProxy
+		if url.Scheme == "https" {
+			value = httpsProxy
+		}
+		if value == "" || isLocalHost(url.Host) || noProxy.Match(url.Host, urlPort(url)) {
+			return nil, nil
+		}
+		return parseProxyUrl(value)
+	}
+}
+
+// urlPort returns the port of the url or the default port of its scheme.
+func urlPort(url *specs.Url) uint16 {
+	if url.Port != 0 {
+		return url.Port
+	}
+	switch url.Scheme {
+	case "http", "ws":
+		return 80
+	case "https", "wss":
+		return 443
+	}
+	return 0
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func isLocalHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func parseProxyUrl(value string) (*specs.Url, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	url, err := specs.ParseUrl(value)
+	if err != nil || url.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url '%s'", value)
+	}
+	return url, nil
+}
+
+// ProxyRule routes requests to the matching hosts through the proxies.
+type ProxyRule struct {
+	// Hosts is a list of destination host patterns.
+	//
+	// A pattern is "*" for any host, an IP address, a CIDR such as "10.0.0.0/8",
+	// a domain name which matches the domain and its subdomains,
+	// or a domain with leading "." or "*." which matches only subdomains.
+	// Domains and IP addresses may be followed by ":port" to match only the port.
+	//
+	// If empty, the rule matches any host.
+	Hosts []string
+
+	// Proxies is a list of proxy urls in order of preference,
+	// next proxy is used when the previous is unreachable.
+	// A nil url means direct connection without proxy.
+	// If empty, requests are sent directly.
+	Proxies []*specs.Url
+}
+
+// NewProxySelector returns a new ProxySelector with the rules
+// which are matched in the order they are provided.
+//
+// Requests to the hosts which do not match any rule are sent directly.
+func NewProxySelector(rules ...ProxyRule) *ProxySelector {
+	selector := &ProxySelector{
+		ProbeTimeout:  3 * time.Second,
+		ProbeInterval: 30 * time.Second,
+		RetryAfter:    30 * time.Second,
+	}
+	for _, rule := range rules {
+		selector.rules = append(selector.rules, proxyRule{
+			hosts:   proxy.ParseHostPatterns(rule.Hosts),
+			proxies: rule.Proxies,
+		})
+	}
+	return selector
+}
+
+// ProxySelector selects the proxy by the host of the request
+// with failover across several proxies of the rule.
+//
+// Reachability of the proxy is checked by establishing TCP connection
+// at most once per [ProxySelector.ProbeInterval], unreachable proxies
+// are skipped for [ProxySelector.RetryAfter].
+//
+// Use [ProxySelector.Proxy] method as [Transport.Proxy].
+type ProxySelector struct {
+	// Dialer specifies the dialer for checking reachability of proxies.
+	// If nil, [net.Dialer] is used.
+	Dialer Dialer
+
+	// ProbeTimeout is the maximum amount of time to wait
+	// for connecting to the proxy when checking reachability.
+	// If zero, there is no timeout.
+	ProbeTimeout time.Duration
+
+	// ProbeInterval specifies how long the proxy is considered reachable
+	// after successful check.
+	// If zero, reachability is checked on each request.
+	ProbeInterval time.Duration
+
+	// RetryAfter specifies how long the unreachable proxy is skipped.
+	// If zero, reachability is checked on each request.
+	RetryAfter time.Duration
+
+	rules  []proxyRule
+	mu     sync.Mutex
+	health map[string]*proxyHealth
+}
+
+type proxyRule struct {
+	hosts   proxy.HostPatterns
+	proxies []*specs.Url
+}
+
+type proxyHealth struct {
+	checkedAt time.Time
+	downUntil time.Time
+}
+
+// Proxy returns the proxy url for the request url
+// or nil if the request should be sent directly.
+//
+// Returns [ErrNoProxyAvailable] if all proxies of the matched rule are unreachable.
+func (selector *ProxySelector) Proxy(url *specs.Url) (*specs.Url, error) {
+	for _, rule := range selector.rules {
+		if len(rule.hosts) > 0 && !rule.hosts.Match(url.Host, urlPort(url)) {
+			continue
+		}
+		if len(rule.proxies) == 0 {
+			return nil, nil
+		}
+
+		for _, proxyUrl := range rule.proxies {
+			if proxyUrl == nil {
+				return nil, nil
+			}
+			if selector.reachable(proxyUrl) {
+				clone := *proxyUrl
+				return &clone, nil
+			}
+		}
+		return nil, ErrNoProxyAvailable
+	}
+	return nil, nil
+}
+
+// reachable checks the proxy is reachable,
+// the result of the check is cached.
+func (selector *ProxySelector) reachable(proxyUrl *specs.Url) bool {
+	port := proxyUrl.Port
+	if port == 0 {
+		port = proxy.SchemeDefaultPortMap[proxyUrl.Scheme]
+	}
+	address := client_ops.HostPort(client_ops.IdnaHost(proxyUrl.Host), port)
+
+	selector.mu.Lock()
+	if selector.health == nil {
+		selector.health = map[string]*proxyHealth{}
+	}
+	health, has := selector.health[address]
+	if !has {
+		health = &proxyHealth{}
+		selector.health[address] = health
+	}
+	now := time.Now()
+	switch {
+	case now.Before(health.downUntil):
+		selector.mu.Unlock()
+		return false
+	case !health.checkedAt.IsZero() && now.Before(health.checkedAt.Add(selector.ProbeInterval)):
+		selector.mu.Unlock()
+		return true
+	}
+	selector.mu.Unlock()
+
+	ctx := context.Background()
+	if selector.ProbeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, selector.ProbeTimeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	var err error
+	if selector.Dialer != nil {
+		conn, err = selector.Dialer.Dial(ctx, "tcp", address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+	if err != nil {
+		health.checkedAt = time.Time{}
+		health.downUntil = time.Now().Add(selector.RetryAfter)
+		return false
+	}
+	conn.Close()
+	health.checkedAt = time.Now()
+	return true
+}
@@ -0,0 +1,303 @@
+package plow
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+// maxRangeCount maximum number of ranges served in a single response,
+// requests with more ranges are served with whole content.
+const maxRangeCount = 64
+
+// RangeResponse is implementation for the [Response] that
+// serves the content of [io.ReadSeeker] with support of range requests
+// to be sent by the [Server].
+//
+// The "Range" header of GET request is served with [specs.StatusCodePartialContent]
+// and "Content-Range" header for a single range
+// or with [specs.ContentTypeMultipartByteranges] body for several ranges.
+// Unsatisfiable ranges are served with [specs.StatusCodeRequestedRangeNotSatisfiable],
+// malformed "Range" header is ignored and whole content is served.
+//
+// The "If-Range" header is compared with "ETag" or "Last-Modified" headers
+// of the response, so they must be set by configure to support resumable transfers.
+//
+// Content type applies as "Content-Type" header value,
+// if content type unspecified then [specs.ContentTypeRaw] will be set.
+//
+// If content implements [io.Closer], it is closed after the body is written,
+// or at once if the response has no body, such as the response to HEAD request.
+func RangeResponse(request Request, contentType string, content io.ReadSeeker, configure ...func(Response)) Response {
+	if request == nil {
+		panic("plow: passed nil request")
+	}
+	if content == nil {
+		panic("plow: passed nil content")
+	}
+
+	if contentType == specs.ContentTypeUndefined {
+		contentType = specs.ContentTypeRaw
+	}
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeContent(content)
+		return TextResponse(specs.StatusCodeInternalServerError, specs.ContentTypePlain, "content is not seekable")
+	}
+
+	resp := &rangeResponse{
+		partialResponse: partialResponse{Response: EmptyResponse(specs.StatusCodeOK, configure...)},
+		content:         content,
+		contentType:     contentType,
+		size:            size,
+	}
+
+	header := resp.Header()
+	header.Set("Accept-Ranges", "bytes")
+
+	method := request.Method()
+	if rangeHeader, has := request.Header().TryGet("Range"); has && method == specs.HttpMethodGet && isRangeFresh(request.Header(), header) {
+		ranges, ok := parseRanges(rangeHeader, size)
+		if ok && len(ranges) == 0 {
+			resp.statusCode = specs.StatusCodeRequestedRangeNotSatisfiable
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			closeContent(content)
+			return &resp.partialResponse
+		}
+		if ok {
+			resp.ranges = ranges
+		}
+	}
+
+	switch len(resp.ranges) {
+	case 0:
+		header.Set("Content-Type", contentType)
+		resp.contentLength = size
+	case 1:
+		resp.statusCode = specs.StatusCodePartialContent
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Range", resp.ranges[0].contentRange(size))
+		resp.contentLength = resp.ranges[0].length
+	default:
+		resp.statusCode = specs.StatusCodePartialContent
+		resp.boundary = multipartBoundary()
+		header.Set("Content-Type", specs.ContentTypeMultipartByteranges+"; boundary="+resp.boundary)
+		resp.contentLength = resp.multipartLength()
+	}
+	header.Set("Content-Length", strconv.FormatInt(resp.contentLength, 10))
+
+	if method == specs.HttpMethodHead {
+		closeContent(content)
+		return &resp.partialResponse
+	}
+	return resp
+}
+
+func closeContent(content io.ReadSeeker) {
+	if closer, ok := content.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+type byteRange struct {
+	start, length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRanges parses "Range" header value, RFC 9110 section 14.2.
+//
+// It reports false if the header is malformed and must be ignored,
+// empty ranges are returned if none of the ranges is satisfiable.
+func parseRanges(value string, size int64) ([]byteRange, bool) {
+	spec, found := strings.CutPrefix(value, "bytes=")
+	if !found {
+		return nil, false
+	}
+
+	var ranges []byteRange
+	var total, count int64
+	for part := range strings.SplitSeq(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		count++
+		if count > maxRangeCount {
+			return nil, false
+		}
+
+		startStr, endStr, found := strings.Cut(part, "-")
+		if !found {
+			return nil, false
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		if startStr == "" {
+			// Suffix range of the last bytes
+			suffix, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, false
+			}
+			if suffix == 0 || size == 0 {
+				continue
+			}
+			suffix = min(suffix, size)
+			ranges = append(ranges, byteRange{start: size - suffix, length: suffix})
+			total += suffix
+			continue
+		}
+
+		start, err := strconv.ParseInt(startStr, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+
+		end := size - 1
+		if endStr != "" {
+			end, err = strconv.ParseInt(endStr, 10, 64)
+			if err != nil || end < start {
+				return nil, false
+			}
+			end = min(end, size-1)
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{start: start, length: end - start + 1})
+		total += end - start + 1
+	}
+	if count == 0 {
+		return nil, false
+	}
+
+	// Overlapping ranges which exceed the content are cheaper to send as whole content
+	if total > size {
+		return nil, false
+	}
+	return ranges, true
+}
+
+// isRangeFresh reports whether the range may be served by "If-Range" header,
+// the entity tag is compared with strong comparison.
+func isRangeFresh(reqHeader, respHeader *specs.Header) bool {
+	ifRange, has := reqHeader.TryGet("If-Range")
+	if !has {
+		return true
+	}
+	if strings.HasPrefix(ifRange, "\"") {
+		etag := respHeader.Get("ETag")
+		return etag != "" && ifRange == etag
+	}
+	lastModified, has := respHeader.TryGet("Last-Modified")
+	if !has {
+		return false
+	}
+	date, err := time.Parse(specs.TimeFormat, ifRange)
+	if err != nil {
+		return false
+	}
+	modTime, err := time.Parse(specs.TimeFormat, lastModified)
+	return err == nil && modTime.Equal(date)
+}
+
+type partialResponse struct {
+	Response
+	statusCode specs.StatusCode
+}
+
+func (resp *partialResponse) StatusCode() specs.StatusCode {
+	if resp.statusCode != specs.StatusCodeUndefined {
+		return resp.statusCode
+	}
+	return resp.Response.StatusCode()
+}
+
+type rangeResponse struct {
+	partialResponse
+	content       io.ReadSeeker
+	contentType   string
+	size          int64
+	ranges        []byteRange
+	boundary      string
+	contentLength int64
+}
+
+func (resp *rangeResponse) WriteBody(writer io.Writer) error {
+	defer closeContent(resp.content)
+
+	switch len(resp.ranges) {
+	case 0:
+		_, err := io.CopyN(writer, resp.content, resp.size)
+		return err
+	case 1:
+		return resp.copyRange(writer, resp.ranges[0])
+	}
+
+	mw := multipart.NewWriter(writer)
+	if err := mw.SetBoundary(resp.boundary); err != nil {
+		return err
+	}
+	for _, r := range resp.ranges {
+		part, err := mw.CreatePart(resp.partHeader(r))
+		if err != nil {
+			return err
+		}
+		if err = resp.copyRange(part, r); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (resp *rangeResponse) ContentLength() int64 {
+	return resp.contentLength
+}
+
+func (resp *rangeResponse) copyRange(writer io.Writer, r byteRange) error {
+	if _, err := resp.content.Seek(r.start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.CopyN(writer, resp.content, r.length)
+	return err
+}
+
+func (resp *rangeResponse) partHeader(r byteRange) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {resp.contentType},
+		"Content-Range": {r.contentRange(resp.size)},
+	}
+}
+
+// multipartLength computes length of the multipart body
+// by writing part headers without content.
+func (resp *rangeResponse) multipartLength() int64 {
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	_ = mw.SetBoundary(resp.boundary)
+	for _, r := range resp.ranges {
+		_, _ = mw.CreatePart(resp.partHeader(r))
+		counter += countingWriter(r.length)
+	}
+	_ = mw.Close()
+	return int64(counter)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
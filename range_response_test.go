@@ -0,0 +1,187 @@
+package plow
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []byteRange
+		ok    bool
+	}{
+		{"bytes=0-4", []byteRange{{0, 5}}, true},
+		{"bytes=5-", []byteRange{{5, 5}}, true},
+		{"bytes=-3", []byteRange{{7, 3}}, true},
+		{"bytes=-30", []byteRange{{0, 10}}, true},
+		{"bytes=8-100", []byteRange{{8, 2}}, true},
+		{"bytes=0-1, 4-5", []byteRange{{0, 2}, {4, 2}}, true},
+		{"bytes=0-1,20-30", []byteRange{{0, 2}}, true},
+		{"bytes=20-30", nil, true},
+		{"bytes=-0", nil, true},
+		{"bytes=0-9,0-9", nil, false},
+		{"bytes=5-1", nil, false},
+		{"bytes=a-b", nil, false},
+		{"bytes=", nil, false},
+		{"items=0-1", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRanges(tt.value, 10)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRanges(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+type closingReader struct {
+	*strings.Reader
+	closed *atomic.Int32
+}
+
+func (r closingReader) Close() error {
+	r.closed.Add(1)
+	return nil
+}
+
+func TestRangeResponse(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	modTime := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	var opened, closed atomic.Int32
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		opened.Add(1)
+		reader := closingReader{strings.NewReader(content), &closed}
+		return RangeResponse(request, specs.ContentTypePlain, reader, func(resp Response) {
+			resp.Header().Set("ETag", `"v1"`)
+			resp.Header().Set("Last-Modified", modTime.Format(specs.TimeFormat))
+		})
+	}))
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Shutdown)
+
+	url := "http://" + listener.Addr().String()
+	transport := &http.Transport{}
+	t.Cleanup(transport.CloseIdleConnections)
+	client := &http.Client{Transport: transport}
+
+	do := func(method string, header map[string]string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, url, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal("req:", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		resp, body := do("GET", nil)
+		if resp.StatusCode != 200 || body != content || resp.Header.Get("Accept-Ranges") != "bytes" {
+			t.Errorf("unexpected response %d %+v %q", resp.StatusCode, resp.Header, body)
+		}
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		resp, body := do("GET", map[string]string{"Range": "bytes=5-9", "Accept-Encoding": "gzip"})
+		if resp.StatusCode != 206 || body != "56789" ||
+			resp.Header.Get("Content-Range") != "bytes 5-9/20" ||
+			resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("unexpected response %d %+v %q", resp.StatusCode, resp.Header, body)
+		}
+	})
+
+	t.Run("Multipart", func(t *testing.T) {
+		resp, body := do("GET", map[string]string{"Range": "bytes=0-2,-3"})
+		if resp.StatusCode != 206 || resp.ContentLength != int64(len(body)) {
+			t.Fatalf("unexpected response %d %+v", resp.StatusCode, resp.Header)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil || mediaType != specs.ContentTypeMultipartByteranges {
+			t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+		}
+
+		reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+		expected := []struct{ contentRange, data string }{
+			{"bytes 0-2/20", "012"},
+			{"bytes 17-19/20", "hij"},
+		}
+		for _, exp := range expected {
+			part, err := reader.NextPart()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(part)
+			if part.Header.Get("Content-Range") != exp.contentRange ||
+				part.Header.Get("Content-Type") != specs.ContentTypePlain ||
+				string(data) != exp.data {
+				t.Errorf("unexpected part %+v %q", part.Header, data)
+			}
+		}
+		if _, err = reader.NextPart(); err != io.EOF {
+			t.Errorf("expected end of parts, got %v", err)
+		}
+	})
+
+	t.Run("NotSatisfiable", func(t *testing.T) {
+		resp, _ := do("GET", map[string]string{"Range": "bytes=30-"})
+		if resp.StatusCode != 416 || resp.Header.Get("Content-Range") != "bytes */20" {
+			t.Errorf("unexpected response %d %+v", resp.StatusCode, resp.Header)
+		}
+	})
+
+	t.Run("IfRange", func(t *testing.T) {
+		tests := []struct {
+			ifRange string
+			code    int
+		}{
+			{`"v1"`, 206},
+			{`"v0"`, 200},
+			{`W/"v1"`, 200},
+			{modTime.Format(specs.TimeFormat), 206},
+			{modTime.Add(-time.Hour).Format(specs.TimeFormat), 200},
+		}
+		for _, tt := range tests {
+			resp, _ := do("GET", map[string]string{"Range": "bytes=0-1", "If-Range": tt.ifRange})
+			if resp.StatusCode != tt.code {
+				t.Errorf("If-Range %s: expected %d, got %d", tt.ifRange, tt.code, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		resp, body := do("HEAD", map[string]string{"Range": "bytes=0-1"})
+		if resp.StatusCode != 200 || body != "" || resp.ContentLength != 20 {
+			t.Errorf("unexpected response %d %+v", resp.StatusCode, resp.Header)
+		}
+	})
+
+	if opened.Load() != closed.Load() {
+		t.Errorf("expected every content to be closed, opened %d, closed %d", opened.Load(), closed.Load())
+	}
+}
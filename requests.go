@@ -108,9 +108,16 @@ func (req *bufferRequest) ContentLength() int64 {
 	return req.contentLength
 }
 
+func (req *bufferRequest) Rewind() error {
+	return nil
+}
+
 // StreamRequest is implementation for the [ClientRequest] that
 // copy response body from [io.Reader] to be sent by the [Client] or [Transport].
 //
+// If stream implements [io.Seeker], the request implements [Rewinder]
+// and the body is written from the current stream offset on each write.
+//
 // Content type applies as "Content-Type" header value
 //
 // if method unspecified then [specs.HttpMethodPost] will be set
@@ -137,6 +144,16 @@ func StreamRequest(method specs.HttpMethod, url *specs.Url, contentType string,
 	}
 	req.Header().Set("Content-Type", contentType)
 
+	if seeker, ok := stream.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return &seekableStreamRequest{
+				streamRequest: req,
+				seeker:        seeker,
+				offset:        offset,
+			}
+		}
+	}
+
 	return req
 }
 
@@ -154,3 +171,14 @@ func (req *streamRequest) WriteBody(w io.Writer) error {
 func (req *streamRequest) ContentLength() int64 {
 	return req.contentLength
 }
+
+type seekableStreamRequest struct {
+	*streamRequest
+	seeker io.Seeker
+	offset int64
+}
+
+func (req *seekableStreamRequest) Rewind() error {
+	_, err := req.seeker.Seek(req.offset, io.SeekStart)
+	return err
+}
@@ -0,0 +1,205 @@
+package plow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/oesand/plow/internal/catch"
+	"github.com/oesand/plow/specs"
+)
+
+// DefaultRetryPolicy factory for creating [RetryPolicy]
+// with optimal parameters for retrying temporary failures
+//
+// Each call creates a new instance of [RetryPolicy]
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// RetryPolicy specifies how the [Client] retries failed requests.
+//
+// Requests are retried on connection errors and on responses
+// with [RetryPolicy.StatusCodes], waiting between attempts with
+// exponential backoff or for the duration of "Retry-After" response header.
+//
+// Only idempotent methods are retried unless [RetryPolicy.RetryNonIdempotent] is set,
+// requests with the body are retried only if the body implements [Rewinder].
+type RetryPolicy struct {
+	// MaxAttempts maximum number of attempts for each request,
+	// including the first one. If less than 2, requests are not retried.
+	MaxAttempts int
+
+	// BaseDelay specifies the delay before the first retry,
+	// the delay is doubled for each next retry.
+	BaseDelay time.Duration
+
+	// MaxDelay maximum delay between attempts.
+	// If "Retry-After" response header requires longer delay,
+	// the response is returned without retry.
+	//
+	// If zero, there is no limit.
+	MaxDelay time.Duration
+
+	// Jitter specifies the fraction of the backoff delay in range [0, 1]
+	// which is randomly subtracted from the delay,
+	// to spread retries of concurrent clients.
+	Jitter float64
+
+	// StatusCodes specifies response status codes to retry.
+	// If nil, [specs.StatusCodeTooManyRequests]
+	// and [specs.StatusCodeServiceUnavailable] are retried.
+	StatusCodes []specs.StatusCode
+
+	// RetryNonIdempotent allows to retry requests with
+	// non-idempotent methods such as POST and PATCH.
+	RetryNonIdempotent bool
+
+	// ShouldRetry is an optional function that decides
+	// whether the attempt with the response or the error should be retried.
+	// If nil, connection errors and [RetryPolicy.StatusCodes] are retried.
+	ShouldRetry func(resp ClientResponse, err error) bool
+}
+
+var defaultRetryStatusCodes = []specs.StatusCode{
+	specs.StatusCodeTooManyRequests,
+	specs.StatusCodeServiceUnavailable,
+}
+
+// roundTrip sends the request with the transport, retrying it by the policy.
+func (policy *RetryPolicy) roundTrip(ctx context.Context, transport RoundTripper, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+	maxAttempts := 1
+	if policy != nil && policy.MaxAttempts > 1 && policy.canRetry(method, writer) {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && writer != nil {
+			if err := writer.(Rewinder).Rewind(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := transport.RoundTrip(ctx, method, url, header, writer)
+		if err == nil && ctx.Err() != nil {
+			// Response is discarded, so its connection is released
+			if body := resp.Body(); body != nil {
+				body.Close()
+			}
+			resp, err = nil, ctx.Err()
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil || !policy.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header().Get("Retry-After")); ok {
+				if policy.MaxDelay > 0 && retryAfter > policy.MaxDelay {
+					return resp, nil
+				}
+				delay = retryAfter
+			}
+			if body := resp.Body(); body != nil {
+				body.Close()
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, catch.CatchCommonErr(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (policy *RetryPolicy) canRetry(method specs.HttpMethod, writer BodyWriter) bool {
+	if !policy.RetryNonIdempotent && !method.IsIdempotent() {
+		return false
+	}
+	if writer != nil {
+		if _, ok := writer.(Rewinder); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (policy *RetryPolicy) shouldRetry(resp ClientResponse, err error) bool {
+	if policy.ShouldRetry != nil {
+		return policy.ShouldRetry(resp, err)
+	}
+	if err != nil {
+		return isRetryableErr(err)
+	}
+
+	codes := policy.StatusCodes
+	if codes == nil {
+		codes = defaultRetryStatusCodes
+	}
+	return slices.Contains(codes, resp.StatusCode())
+}
+
+// backoff returns the delay before the next attempt after failed attempt.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if jitter := min(max(policy.Jitter, 0), 1); jitter > 0 && delay > 0 {
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// parseRetryAfter parses "Retry-After" header value
+// in delay seconds or HTTP date formats.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := time.Parse(specs.TimeFormat, value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// isRetryableErr checks the error is caused by connection failure
+// and the request can be sent again.
+func isRetryableErr(err error) bool {
+	var opErr *specs.OpError
+	for errors.As(err, &opErr) && opErr.Err != nil {
+		err = opErr.Err
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, specs.ErrTimeout) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
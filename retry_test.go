@@ -0,0 +1,216 @@
+package plow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow/internal/client_ops"
+	"github.com/oesand/plow/specs"
+)
+
+func newRetryTestClient() *Client {
+	client := DefaultClient()
+	client.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+	return client
+}
+
+func TestClient_RetryStatusCode(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	resp, err := newRetryTestClient().Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponseBody(t, resp, []byte("OK"))
+	if attempts.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestClient_RetryAfterExceedsMaxDelay(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	resp, err := newRetryTestClient().Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl(server.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != specs.StatusCodeTooManyRequests || attempts.Load() != 1 {
+		t.Errorf("expected single attempt with 429, got %d after %d", resp.StatusCode(), attempts.Load())
+	}
+}
+
+func TestClient_RetryBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     func(url *specs.Url) ClientRequest
+		optIn       bool
+		wantAttempt int32
+	}{
+		{
+			name: "Buffer Post",
+			request: func(url *specs.Url) ClientRequest {
+				return TextRequest(specs.HttpMethodPost, url, "", "payload")
+			},
+			wantAttempt: 1,
+		},
+		{
+			name: "Buffer Post Opt In",
+			request: func(url *specs.Url) ClientRequest {
+				return TextRequest(specs.HttpMethodPost, url, "", "payload")
+			},
+			optIn:       true,
+			wantAttempt: 3,
+		},
+		{
+			name: "Seekable Stream",
+			request: func(url *specs.Url) ClientRequest {
+				return StreamRequest(specs.HttpMethodPut, url, "", strings.NewReader("payload"), 7)
+			},
+			wantAttempt: 3,
+		},
+		{
+			name: "Not Seekable Stream",
+			request: func(url *specs.Url) ClientRequest {
+				return StreamRequest(specs.HttpMethodPut, url, "", io.MultiReader(strings.NewReader("payload")), 7)
+			},
+			wantAttempt: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				body, _ := io.ReadAll(r.Body)
+				if !bytes.Equal(body, []byte("payload")) {
+					t.Errorf("unexpected body %q", body)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			client := newRetryTestClient()
+			client.RetryPolicy.RetryNonIdempotent = tt.optIn
+			resp, err := client.Make(tt.request(specs.MustParseUrl(server.URL)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode() != specs.StatusCodeServiceUnavailable || attempts.Load() != tt.wantAttempt {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempt, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestClient_RetryConnectionError(t *testing.T) {
+	var attempts int
+	client := newRetryTestClient()
+	client.Transport = RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+		attempts++
+		return nil, &specs.OpError{Op: "read", Err: syscall.ECONNRESET}
+	})
+
+	_, err := client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl("http://example.com")))
+	if err == nil || !strings.Contains(err.Error(), "reset") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	client.Transport = RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+		attempts++
+		return nil, specs.ErrUnknownTransferEncoding
+	})
+	if _, err = client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl("http://example.com"))); err == nil || attempts != 1 {
+		t.Errorf("expected not retryable error, got %v after %d attempts", err, attempts)
+	}
+}
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (body *closeTracker) Close() error {
+	body.closed = true
+	return nil
+}
+
+func TestRetryPolicy_CanceledAfterResponse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	body := &closeTracker{Reader: strings.NewReader("OK")}
+	transport := RoundTripperFunc(func(ctx context.Context, method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter) (ClientResponse, error) {
+		cancel()
+		resp := client_ops.NewHttpClientResponse(specs.StatusCodeOK, specs.NewHeader())
+		resp.Reader = body
+		return resp, nil
+	})
+
+	policy := &RetryPolicy{MaxAttempts: 3}
+	resp, err := policy.roundTrip(ctx, transport, specs.HttpMethodGet, specs.MustParseUrl("http://example.com"), specs.NewHeader(), nil)
+	if resp != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected only canceled error, got %v and %v", resp, err)
+	}
+	if !body.closed {
+		t.Error("expected body of discarded response to be closed")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := policy.backoff(attempt + 1); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %s, want %s", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for range 10 {
+		if got := policy.backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Errorf("backoff with jitter out of range: %s", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay, ok := parseRetryAfter("5"); !ok || delay != 5*time.Second {
+		t.Errorf("unexpected delay %s", delay)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(specs.TimeFormat)
+	if delay, ok := parseRetryAfter(date); !ok || delay <= 50*time.Second || delay > time.Minute {
+		t.Errorf("unexpected delay %s for date", delay)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("expected invalid value")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"github.com/oesand/plow/internal/catch"
 	"github.com/oesand/plow/internal/encoding"
+	"github.com/oesand/plow/internal/h2"
 	"github.com/oesand/plow/internal/parsing"
 	"github.com/oesand/plow/internal/server_ops"
 	"github.com/oesand/plow/internal/stream"
@@ -23,7 +24,9 @@ import (
 // new service goroutine for each. The service goroutines read requests and
 // then call [Server.Handler] to reply to them.
 //
-// HTTP/2 not supported
+// HTTP/2 is served over TLS connections which negotiated "h2" protocol
+// when [Server.EnableHTTP2] is set and over plain connections
+// when [Server.EnableH2C] is set.
 //
 // Serve always returns a non-nil error.
 // After [Server.Shutdown], the returned error is [specs.ErrClosed].
@@ -164,7 +167,10 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 			conn.SetDeadline(time.Time{})
 		}
 
-		proto := tlsConn.ConnectionState().NegotiatedProtocol
+		state := tlsConn.ConnectionState()
+		ctx = context.WithValue(ctx, tlsStateKey, &state)
+
+		proto := state.NegotiatedProtocol
 
 		if srv.tlsNextProtos != nil {
 			if handler, ok := srv.tlsNextProtos[proto]; ok {
@@ -172,6 +178,10 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 				return nil
 			}
 		}
+
+		if proto == h2.NextProtoTLS && srv.EnableHTTP2 {
+			return srv.serveH2(ctx, tlsConn, nil, nil, handler)
+		}
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -188,6 +198,8 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 	bufioReader := stream.DefaultBufioReaderPool.Get(conn)
 	defer stream.DefaultBufioReaderPool.Put(bufioReader)
 
+	_, isTLS := conn.(*tls.Conn)
+
 	for i := 0; true; i++ {
 		if i > 0 {
 			idleTimeout := srv.IdleTimeout
@@ -212,6 +224,14 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 			conn.SetReadDeadline(time.Now().Add(srv.ReadTimeout))
 		}
 
+		// HTTP/2 with prior knowledge starts with the client preface
+		if i == 0 && srv.EnableH2C && !isTLS {
+			if prefix, err := bufioReader.Peek(len(h2cPrefacePrefix)); err == nil && string(prefix) == h2cPrefacePrefix {
+				conn.SetReadDeadline(time.Time{})
+				return srv.serveH2(ctx, conn, bufioReader, nil, handler)
+			}
+		}
+
 		req, err := server_ops.ReadRequest(ctx, conn.RemoteAddr(), bufioReader, srv.ReadLineMaxLength, srv.HeadMaxLength)
 
 		if err == nil {
@@ -245,16 +265,7 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 			}
 		}
 
-		var selectedEncoding string
-		if acceptEncoding, has := req.Header().TryGet("Accept-Encoding"); has {
-			variants := strings.Split(acceptEncoding, ", ")
-			for _, variant := range variants {
-				if encoding.IsKnownEncoding(variant) {
-					selectedEncoding = variant
-					break
-				}
-			}
-		}
+		selectedEncoding := selectContentEncoding(req.Header())
 
 		var isChunked bool
 		if req.Method().IsPostable() {
@@ -264,6 +275,9 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 				if errors.Is(err, parsing.ErrParsing) {
 					return responseInvalidContentLength
 				}
+				if errors.Is(err, parsing.ErrConflictingContentLength) {
+					return responseConflictingContentLength
+				}
 				if errors.Is(err, specs.ErrUnknownTransferEncoding) {
 					return responseUnsupportedTransferEncoding
 				}
@@ -305,6 +319,10 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 			req.BodyReader = server_ops.ExpectContinueReader(req.BodyReader, conn)
 		}
 
+		if srv.EnableH2C && !isTLS && isHttp11 && isH2CUpgrade(req.Header()) {
+			return srv.upgradeH2C(ctx, conn, bufioReader, req, handler)
+		}
+
 		resp := handler.Handle(ctx, req)
 		var header *specs.Header
 		var code specs.StatusCode
@@ -326,6 +344,11 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 
 		header.Set("Date", time.Now().Format(specs.TimeFormat))
 
+		// Response body is already encoded or is a part of the content
+		if header.Has("Content-Encoding") || code == specs.StatusCodePartialContent {
+			selectedEncoding = ""
+		}
+
 		if selectedEncoding != "" {
 			header.Set("Content-Encoding", selectedEncoding)
 		}
@@ -420,6 +443,20 @@ func (srv *Server) handle(ctx context.Context, conn net.Conn, handler Handler) e
 	return nil
 }
 
+// selectContentEncoding returns the first known encoding
+// from the "Accept-Encoding" request header.
+func selectContentEncoding(header *specs.Header) string {
+	if acceptEncoding, has := header.TryGet("Accept-Encoding"); has {
+		variants := strings.Split(acceptEncoding, ", ")
+		for _, variant := range variants {
+			if encoding.IsKnownEncoding(variant) {
+				return variant
+			}
+		}
+	}
+	return ""
+}
+
 func (srv *Server) writeBody(writable BodyWriter, writer io.Writer, chunked bool, contentEncoding string) error {
 	if chunked {
 		chw := encoding.NewChunkedWriter(writer)
@@ -433,8 +470,30 @@ func (srv *Server) writeBody(writable BodyWriter, writer io.Writer, chunked bool
 			return err
 		}
 		defer encodingWriter.Close()
-		writer = encodingWriter
+
+		next, nextOk := writer.(Flusher)
+		encoder, encoderOk := encodingWriter.(Flusher)
+		if nextOk && encoderOk {
+			writer = &encodingFlusher{Writer: encodingWriter, encoder: encoder, next: next}
+		} else {
+			writer = encodingWriter
+		}
 	}
 
 	return writable.WriteBody(writer)
 }
+
+// encodingFlusher flushes the encoding writer
+// together with the buffered writer it wraps.
+type encodingFlusher struct {
+	io.Writer
+	encoder Flusher
+	next    Flusher
+}
+
+func (w *encodingFlusher) Flush() error {
+	if err := w.encoder.Flush(); err != nil {
+		return err
+	}
+	return w.next.Flush()
+}
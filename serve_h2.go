@@ -0,0 +1,295 @@
+package plow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oesand/plow/internal/h2"
+	"github.com/oesand/plow/internal/parsing"
+	"github.com/oesand/plow/internal/server_ops"
+	"github.com/oesand/plow/specs"
+)
+
+// h2cPrefacePrefix is the beginning of HTTP/2 client preface,
+// which is a valid HTTP/1 request line with "PRI" method.
+const h2cPrefacePrefix = "PRI * HTTP/2.0\r\n"
+
+// serveH2 serves HTTP/2 connection, each stream is handled by the handler.
+//
+// Reader is an optional reader of the connection with already buffered data,
+// upgrade is an optional HTTP/1.1 request upgraded to the stream 1.
+func (srv *Server) serveH2(ctx context.Context, conn net.Conn, reader io.Reader, upgrade *h2.UpgradeRequest, handler Handler) error {
+	config := h2.ServerConfig{
+		ReadTimeout:  srv.ReadTimeout,
+		WriteTimeout: srv.WriteTimeout,
+		IdleTimeout:  srv.IdleTimeout,
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = srv.ReadTimeout
+	}
+	if config.IdleTimeout < 0 {
+		config.IdleTimeout = 0
+	}
+	if srv.HeadMaxLength > 0 {
+		config.MaxHeaderListSize = uint32(min(srv.HeadMaxLength, math.MaxUint32))
+	}
+
+	// Connection errors are reported to the client by GOAWAY frame
+	h2.ServeConn(ctx, conn, reader, config, upgrade, func(st *h2.ServerStream) {
+		srv.handleH2Stream(st, handler)
+	})
+	return nil
+}
+
+// isH2CUpgrade reports whether the HTTP/1.1 request asks
+// to switch protocol to cleartext HTTP/2, RFC 7540 section 3.2.
+func isH2CUpgrade(header *specs.Header) bool {
+	if !hasHeaderToken(header.Get("Upgrade"), "h2c") || !header.Has("HTTP2-Settings") {
+		return false
+	}
+	connection := header.Get("Connection")
+	return hasHeaderToken(connection, "Upgrade") && hasHeaderToken(connection, "HTTP2-Settings")
+}
+
+// hasHeaderToken reports whether comma-separated header value contains the token.
+func hasHeaderToken(value, token string) bool {
+	for part := range strings.SplitSeq(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeH2C switches the connection to cleartext HTTP/2,
+// the request is served as the stream 1 of the new connection.
+func (srv *Server) upgradeH2C(ctx context.Context, conn net.Conn, reader io.Reader, req *server_ops.HttpRequest, handler Handler) error {
+	header := req.Header()
+
+	settings, err := h2.ParseSettingsHeader(header.Get("HTTP2-Settings"))
+	if err != nil {
+		return responseErrNotProcessable
+	}
+
+	// Body must be consumed before the connection preface
+	var body []byte
+	if req.BodyReader != nil {
+		if body, err = io.ReadAll(req.BodyReader); err != nil {
+			return err
+		}
+	}
+
+	respHeader := specs.NewHeader()
+	respHeader.Set("Connection", "Upgrade")
+	respHeader.Set("Upgrade", "h2c")
+	if _, err = server_ops.WriteResponseHead(conn, true, specs.StatusCodeSwitchingProtocols, respHeader); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	header.Del("Connection")
+	header.Del("Upgrade")
+	header.Del("HTTP2-Settings")
+
+	url := req.Url()
+	path := url.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if url.Query.Any() {
+		path += "?" + url.Query.String()
+	}
+
+	// Request line may refer to the reader buffer, which is reused by the connection
+	return srv.serveH2(ctx, conn, reader, &h2.UpgradeRequest{
+		Settings:  settings,
+		Method:    specs.HttpMethod(strings.Clone(string(req.Method()))),
+		Scheme:    "http",
+		Authority: header.Get("Host"),
+		Path:      strings.Clone(path),
+		Header:    header,
+		Body:      body,
+	}, handler)
+}
+
+func (srv *Server) handleH2Stream(st *h2.ServerStream, handler Handler) {
+	defer func() {
+		if err := recover(); err != nil {
+			srv.writeH2Error(st, responseInternalServerError)
+		}
+	}()
+
+	ctx := st.Context()
+	header := st.Header
+
+	var url *specs.Url
+	if st.Method == specs.HttpMethodConnect {
+		url = &specs.Url{Host: st.Authority}
+	} else {
+		var err error
+		if url, err = specs.ParseUrl(st.Path); err != nil {
+			srv.writeH2Error(st, &server_ops.ErrorResponse{
+				Code: specs.StatusCodeMisdirectedRequest,
+				Text: "http: invalid request url",
+			})
+			return
+		}
+	}
+
+	if !header.Has("Host") && st.Authority != "" {
+		header.Set("Host", st.Authority)
+	}
+
+	// RFC 7234, section 5.4: Should treat
+	if pragma, has := header.TryGet("Pragma"); has && pragma == "no-cache" {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	req := server_ops.NewHttpRequest(2, 0, st.RemoteAddr(), st.Method, url, header)
+
+	if body := st.Body(); body != nil && req.Method().IsPostable() {
+		_, contentLength, err := parsing.ParseContentLength(header)
+		if err != nil {
+			if errors.Is(err, parsing.ErrParsing) {
+				srv.writeH2Error(st, responseInvalidContentLength)
+			} else if errors.Is(err, parsing.ErrConflictingContentLength) {
+				srv.writeH2Error(st, responseConflictingContentLength)
+			} else {
+				srv.writeH2Error(st, responseUnsupportedTransferEncoding)
+			}
+			return
+		}
+
+		if srv.MaxBodySize > 0 {
+			if contentLength > srv.MaxBodySize {
+				srv.writeH2Error(st, responseErrBodyTooLarge)
+				return
+			}
+			body = io.LimitReader(body, srv.MaxBodySize)
+		}
+
+		req.BodyReader = body
+	}
+
+	selectedEncoding := selectContentEncoding(header)
+
+	resp := handler.Handle(ctx, req)
+	var respHeader *specs.Header
+	var code specs.StatusCode
+	var writable BodyWriter
+	if resp != nil {
+		respHeader = resp.Header()
+		code = resp.StatusCode()
+		writable, _ = resp.(BodyWriter)
+	}
+	if respHeader == nil {
+		respHeader = specs.NewHeader()
+	}
+
+	if srv.ServerName != "" {
+		respHeader.Set("Server", srv.ServerName)
+	} else {
+		respHeader.Set("Server", DefaultServerName)
+	}
+
+	respHeader.Set("Date", time.Now().Format(specs.TimeFormat))
+
+	// Response body is already encoded or is a part of the content
+	if respHeader.Has("Content-Encoding") || code == specs.StatusCodePartialContent {
+		selectedEncoding = ""
+	}
+
+	if !code.IsValid() {
+		if !req.Method().IsReplyable() || writable == nil {
+			code = specs.StatusCodeNoContent
+		} else {
+			code = specs.StatusCodeOK
+		}
+	}
+
+	var encodedContent []byte
+	mustResponseBody := req.Method().IsReplyable() && code.IsReplyable() && writable != nil
+	if mustResponseBody {
+		// Body is always streamed with DATA frames
+		streaming := respHeader.Get("Transfer-Encoding") == "chunked"
+		respHeader.Del("Transfer-Encoding")
+
+		maxEncodingSize := DefaultMaxEncodingSize
+		if srv.MaxEncodingSize > 0 {
+			maxEncodingSize = srv.MaxEncodingSize
+		}
+		contentLength := writable.ContentLength()
+
+		if selectedEncoding != "" && !streaming && contentLength <= maxEncodingSize {
+			var cachedBody bytes.Buffer
+			if err := srv.writeBody(writable, &cachedBody, false, selectedEncoding); err != nil {
+				srv.writeH2Error(st, responseInternalServerError)
+				return
+			}
+			encodedContent = cachedBody.Bytes()
+			respHeader.Set("Content-Length", strconv.Itoa(len(encodedContent)))
+		} else if !streaming {
+			selectedEncoding = ""
+			if contentLength > 0 {
+				respHeader.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+			}
+		}
+	} else {
+		selectedEncoding = ""
+	}
+
+	if selectedEncoding != "" {
+		respHeader.Set("Content-Encoding", selectedEncoding)
+	}
+
+	if err := st.WriteHeaders(code, respHeader, !mustResponseBody); err != nil || !mustResponseBody {
+		return
+	}
+
+	bw := bufio.NewWriterSize(st, 16<<10)
+	var err error
+	if encodedContent != nil {
+		_, err = bw.Write(encodedContent)
+	} else {
+		err = srv.writeBody(writable, bw, false, selectedEncoding)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+
+	if err != nil {
+		st.Reset()
+		return
+	}
+	st.Close()
+}
+
+// writeH2Error writes error response to the stream
+// or resets it if headers are already written.
+func (srv *Server) writeH2Error(st *h2.ServerStream, resp *server_ops.ErrorResponse) {
+	header := specs.NewHeader()
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(resp.Text)))
+
+	code := resp.Code
+	if code == 0 {
+		code = specs.StatusCodeInternalServerError
+	}
+
+	if err := st.WriteHeaders(code, header, resp.Text == ""); err != nil {
+		st.Reset()
+		return
+	}
+	if resp.Text != "" {
+		st.Write([]byte(resp.Text))
+		st.Close()
+	}
+}
@@ -0,0 +1,204 @@
+package plow
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/oesand/plow/internal/testing_ops"
+	"github.com/oesand/plow/specs"
+)
+
+func serveHTTP2Test(t *testing.T, server *Server) string {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLSRaw(listener, testing_ops.NewTlsCert())
+	t.Cleanup(server.Shutdown)
+
+	return "https://" + listener.Addr().String()
+}
+
+func newHTTP2TestClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}}
+}
+
+func TestServer_HTTP2GetRequest(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if major, _ := request.ProtoVersion(); major != 2 {
+			t.Errorf("expected HTTP/2 request, got %d", major)
+		}
+		if request.Url().Path != "/path" || request.Url().Query.Get("key") != "value" {
+			t.Errorf("unexpected request url %s", request.Url())
+		}
+		if request.Header().Get("Host") == "" {
+			t.Error("not found host header")
+		}
+		if state := TLSConnectionState(ctx); state == nil || state.NegotiatedProtocol != "h2" {
+			t.Errorf("unexpected tls connection state %+v", state)
+		}
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "okay", func(resp Response) {
+			resp.Header().Set("x-hello-world", "xyz-123")
+		})
+	}))
+	server.EnableHTTP2 = true
+	url := serveHTTP2Test(t, server)
+
+	resp, err := newHTTP2TestClient().Get(url + "/path?key=value")
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+	if resp.Header.Get("X-Hello-World") != "xyz-123" ||
+		resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("not found expected headers, %+v", resp.Header)
+	}
+	if !resp.Uncompressed {
+		t.Error("expected encoded response")
+	}
+
+	checkHttpResponseBody(t, resp, []byte("okay"))
+}
+
+func TestServer_HTTP2PostLargeRequest(t *testing.T) {
+	// Larger than initial windows, so flow control must be respected by both sides
+	requestBody := make([]byte, 3<<20)
+	rand.Read(requestBody)
+
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		body, err := io.ReadAll(request.Body())
+		if err != nil {
+			t.Error("read:", err)
+		}
+		return BufferResponse(specs.StatusCodeOK, specs.ContentTypeRaw, body)
+	}))
+	server.EnableHTTP2 = true
+	url := serveHTTP2Test(t, server)
+
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(requestBody))
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := newHTTP2TestClient().Do(req)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+	if resp.Header.Get("Content-Length") != strconv.Itoa(len(requestBody)) {
+		t.Errorf("unexpected content length %s", resp.Header.Get("Content-Length"))
+	}
+
+	checkHttpResponseBody(t, resp, requestBody)
+}
+
+func TestServer_HTTP2RequestBodyTooLarge(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		t.Error("handler must not be called")
+		return nil
+	}))
+	server.EnableHTTP2 = true
+	server.MaxBodySize = 10
+	url := serveHTTP2Test(t, server)
+
+	resp, err := newHTTP2TestClient().Post(url, specs.ContentTypePlain, bytes.NewReader(make([]byte, 100)))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_HTTP2ConcurrentStreams(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, request.Url().Query.Get("n"))
+	}))
+	server.EnableHTTP2 = true
+	url := serveHTTP2Test(t, server)
+
+	client := newHTTP2TestClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n string) {
+			defer wg.Done()
+
+			resp, err := client.Get(url + "?n=" + n)
+			if err != nil {
+				t.Error("req:", err)
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.ProtoMajor != 2 || string(body) != n {
+				t.Errorf("expected HTTP/2 response %s, got %s %s", n, resp.Proto, body)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+}
+
+func TestServer_HTTP2WithTransport(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if major, _ := request.ProtoVersion(); major != 2 {
+			t.Errorf("expected HTTP/2 request, got %d", major)
+		}
+		body, _ := io.ReadAll(request.Body())
+		return BufferResponse(specs.StatusCodeOK, specs.ContentTypeRaw, body)
+	}))
+	server.EnableHTTP2 = true
+	url := serveHTTP2Test(t, server)
+
+	transport := newHTTP2TestTransport()
+	defer transport.CloseIdleConnections()
+
+	req := TextRequest(specs.HttpMethodPost, specs.MustParseUrl(url), specs.ContentTypePlain, "ping")
+	resp, err := transport.RoundTrip(
+		context.Background(), req.Method(), req.Url(), req.Header(), req.(BodyWriter))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	checkResponseBody(t, resp, []byte("ping"))
+}
+
+func TestServer_HTTP2Disabled(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "okay")
+	}))
+	url := serveHTTP2Test(t, server)
+
+	client := newHTTP2TestClient()
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.ProtoMajor != 1 {
+		t.Errorf("expected HTTP/1.1 response, got %s", resp.Proto)
+	}
+	checkHttpResponseBody(t, resp, []byte("okay"))
+}
@@ -0,0 +1,166 @@
+package plow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow/specs"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func serveH2CTest(t *testing.T, server *Server) string {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Shutdown)
+
+	return listener.Addr().String()
+}
+
+func newH2CTestClient() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+func TestServer_H2CPriorKnowledge(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if major, _ := request.ProtoVersion(); major != 2 {
+			t.Errorf("expected HTTP/2 request, got %d", major)
+		}
+		body, _ := io.ReadAll(request.Body())
+		return BufferResponse(specs.StatusCodeOK, specs.ContentTypeRaw, body)
+	}))
+	server.EnableH2C = true
+	addr := serveH2CTest(t, server)
+
+	resp, err := newH2CTestClient().Post("http://"+addr, specs.ContentTypeRaw, bytes.NewReader([]byte("ping")))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+	checkHttpResponseBody(t, resp, []byte("ping"))
+}
+
+func TestServer_H2CUpgrade(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if major, _ := request.ProtoVersion(); major != 2 {
+			t.Errorf("expected HTTP/2 request, got %d", major)
+		}
+		if request.Url().Path != "/path" || request.Url().Query.Get("key") != "value" {
+			t.Errorf("unexpected request url %s", request.Url())
+		}
+		if request.Header().Has("HTTP2-Settings") || request.Header().Has("Upgrade") {
+			t.Errorf("upgrade headers must be removed, %+v", request.Header())
+		}
+		body, _ := io.ReadAll(request.Body())
+		return BufferResponse(specs.StatusCodeOK, specs.ContentTypeRaw, body)
+	}))
+	server.EnableH2C = true
+	addr := serveH2CTest(t, server)
+
+	conn, err := net.Dial("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("POST /path?key=value HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\n" +
+		"HTTP2-Settings: AAMAAABkAAQAAP__\r\n" +
+		"Content-Length: 4\r\n\r\nping"))
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal("read upgrade response:", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "h2c" {
+		t.Fatalf("expected switching protocols, got %s %+v", resp.Status, resp.Header)
+	}
+
+	conn.Write([]byte(http2.ClientPreface))
+	framer := http2.NewFramer(conn, reader)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	framer.WriteSettings()
+
+	var status string
+	var body []byte
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatal("read frame:", err)
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				framer.WriteSettingsAck()
+			}
+			continue
+		case *http2.MetaHeadersFrame:
+			if f.StreamID != 1 {
+				t.Fatalf("expected response on stream 1, got %d", f.StreamID)
+			}
+			status = f.PseudoValue("status")
+			if !f.StreamEnded() {
+				continue
+			}
+		case *http2.DataFrame:
+			body = append(body, f.Data()...)
+			if !f.StreamEnded() {
+				continue
+			}
+		default:
+			continue
+		}
+		break
+	}
+
+	if status != "200" {
+		t.Errorf("expected status 200, got %s", status)
+	}
+	if string(body) != "ping" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestServer_H2CDisabled(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if major, _ := request.ProtoVersion(); major != 1 {
+			t.Errorf("expected HTTP/1 request, got %d", major)
+		}
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "okay")
+	}))
+	addr := serveH2CTest(t, server)
+
+	req, _ := http.NewRequest("GET", "http://"+addr, nil)
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", "AAMAAABkAAQAAP__")
+	req.Close = true
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	checkHttpResponseBody(t, resp, []byte("okay"))
+}
@@ -1,13 +1,19 @@
 package plow
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
+	"io/fs"
 	"net"
+	"os"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/h2"
 	"github.com/oesand/plow/specs"
 )
 
@@ -127,6 +133,25 @@ type Server struct {
 	// By default, keep-alive are always enabled.
 	DisableKeepAlive bool
 
+	// EnableHTTP2 enables serving of HTTP/2 over TLS connections,
+	// "h2" protocol is negotiated with ALPN before "http/1.1".
+	//
+	// Each stream is handled by [Server.Handler] with the same
+	// limits, timeouts and content encoding as HTTP/1.x requests.
+	// Streams failed on handling are reset, [Server.ErrorHandler] is not called,
+	// hijacking of HTTP/2 requests is not supported and ignored.
+	//
+	// If a handler for "h2" is specified with [Server.TLSNextProto], it takes precedence.
+	EnableHTTP2 bool
+
+	// EnableH2C enables serving of cleartext HTTP/2 (h2c) over plain connections,
+	// both with HTTP/1.1 "Upgrade: h2c" handshake and with prior knowledge,
+	// when the connection starts with HTTP/2 client preface.
+	//
+	// Streams are handled the same way as with [Server.EnableHTTP2].
+	// Body of the upgrade request is read into memory before switching protocols.
+	EnableH2C bool
+
 	tlsNextProtos map[string]NextProtoHandler
 
 	listenerTrack sync.WaitGroup
@@ -169,7 +194,7 @@ func (srv *Server) TLSHasNextProto(proto string) bool {
 // handle HTTP requests. The connection is automatically closed
 // when the function returns.
 //
-// HTTP/2 support is not enabled automatically.
+// HTTP/2 support is not enabled automatically, see [Server.EnableHTTP2].
 func (srv *Server) TLSNextProto(proto string, handler NextProtoHandler) {
 	srv.mutex.Lock()
 	defer srv.mutex.Unlock()
@@ -190,6 +215,8 @@ func (srv *Server) TLSNextProto(proto string, handler NextProtoHandler) {
 // calls [Server.Serve] to handle requests on incoming connections.
 //
 // If addr is blank, ":http" is used.
+// TCP address without host such as ":8080" listens on both IPv4 and IPv6,
+// address in form "unix:///path/to/socket" listens on the Unix domain socket.
 //
 // ListenAndServe always returns a non-nil error.
 // After [Server.Shutdown], the returned error is [specs.ErrClosed].
@@ -206,7 +233,7 @@ func (srv *Server) ListenAndServe(addr string) error {
 	} else if addr == "" {
 		addr = ":http"
 	}
-	lst, err := net.Listen("tcp4", addr)
+	lst, err := listen(addr)
 	if err != nil {
 		return err
 	}
@@ -220,6 +247,7 @@ func (srv *Server) ListenAndServe(addr string) error {
 // server must be provided.
 //
 // If addr is blank, ":http" is used.
+// Address formats are the same as for [Server.ListenAndServe].
 //
 // ListenAndServeTLS always returns a non-nil error.
 // After [Server.Shutdown], the returned error is [specs.ErrClosed].
@@ -229,7 +257,7 @@ func (srv *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
 	} else if addr == "" {
 		addr = ":http"
 	}
-	lst, err := net.Listen("tcp4", addr)
+	lst, err := listen(addr)
 	if err != nil {
 		return err
 	}
@@ -242,6 +270,7 @@ func (srv *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
 // Certificate and matching private key for the server must be provided.
 //
 // If addr is blank, ":http" is used.
+// Address formats are the same as for [Server.ListenAndServe].
 //
 // ListenAndServeTLSRaw always returns a non-nil error.
 // After [Server.Shutdown], the returned error is [specs.ErrClosed].
@@ -251,13 +280,40 @@ func (srv *Server) ListenAndServeTLSRaw(addr string, cert tls.Certificate) error
 	} else if addr == "" {
 		addr = ":http"
 	}
-	lst, err := net.Listen("tcp4", addr)
+	lst, err := listen(addr)
 	if err != nil {
 		return err
 	}
 	return srv.serveTLSRaw(lst, &cert)
 }
 
+// UnixAddrPrefix is the prefix of the address of the Unix domain socket
+// which can be passed to [Server.ListenAndServe].
+const UnixAddrPrefix = "unix://"
+
+// listen announces on the Unix domain socket if the address has [UnixAddrPrefix],
+// otherwise on the dual-stack TCP address.
+//
+// Stale socket file left by the previous process is removed before listening.
+func listen(addr string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(addr, UnixAddrPrefix)
+	if !isUnix {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, errors.New("plow: empty unix socket path")
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+		} else {
+			os.Remove(path)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
 // ServeTLS accepts incoming connections on the [net.Listener], creating a
 // new service goroutine for each. The service goroutines perform TLS
 // setup and then read requests, calling [Server.Handler] to reply to them.
@@ -304,6 +360,10 @@ func (srv *Server) serveTLSRaw(lst net.Listener, cert *tls.Certificate) error {
 		config = &tls.Config{}
 	}
 
+	if srv.EnableHTTP2 && !slices.Contains(config.NextProtos, h2.NextProtoTLS) {
+		config.NextProtos = append([]string{h2.NextProtoTLS}, config.NextProtos...)
+	}
+
 	if !slices.Contains(config.NextProtos, httpV1NextProtoTLS) {
 		config.NextProtos = append(config.NextProtos, httpV1NextProtoTLS)
 	}
@@ -358,3 +418,14 @@ func (srv *Server) Shutdown() {
 
 	srv.listenerTrack.Wait()
 }
+
+var tlsStateKey = internal.FlagKey{Key: "server.tls.state.key"}
+
+// TLSConnectionState returns the state of TLS connection
+// on which the request of the handler context was received by the [Server].
+//
+// Returns nil if the request was received over plaintext connection.
+func TLSConnectionState(ctx context.Context) *tls.ConnectionState {
+	state, _ := ctx.Value(tlsStateKey).(*tls.ConnectionState)
+	return state
+}
@@ -16,6 +16,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"sync/atomic"
 	"testing"
@@ -24,6 +25,9 @@ import (
 
 func TestServer_GetRequest(t *testing.T) {
 	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if TLSConnectionState(ctx) != nil {
+			t.Error("unexpected tls connection state")
+		}
 		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "okay", func(resp Response) {
 			resp.Header().Set("x-hello-world", "xyz-123")
 		})
@@ -985,6 +989,39 @@ func TestServer_RequestBodyTooLarge(t *testing.T) {
 	}
 }
 
+func TestServer_ConflictingContentLength(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "ok")
+	}))
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\n" +
+		"Content-Length: 5\r\nContent-Length: 10\r\n\r\nhello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != int(specs.StatusCodeBadRequest) {
+		t.Errorf("expected status code 400, got %d", resp.StatusCode)
+	}
+}
+
 // TestContinue
 
 func TestServer_Expect1OOContinue(t *testing.T) {
@@ -1229,3 +1266,88 @@ func TestServer_IdleConn(t *testing.T) {
 		}
 	}
 }
+
+func TestServer_UnixSocket(t *testing.T) {
+	server := DefaultServer(HandlerFunc(func(ctx context.Context, request Request) Response {
+		if request.RemoteAddr().Network() != "unix" {
+			t.Errorf("unexpected remote addr network %s", request.RemoteAddr().Network())
+		}
+		return TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, request.Header().Get("Host"))
+	}))
+	defer server.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "plow.sock")
+
+	// Stale socket file of the previous process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	go server.ListenAndServe(UnixAddrPrefix + path)
+
+	transport := DefaultTransport()
+	transport.Dialer = UnixSocketDialer(path)
+	transport.Proxy = func(url *specs.Url) (*specs.Url, error) {
+		t.Error("proxy must be ignored with unix socket dialer")
+		return specs.MustParseUrl("socks5://proxy.test:1080"), nil
+	}
+	defer transport.CloseIdleConnections()
+	client := Client{Transport: transport}
+
+	var resp ClientResponse
+	for i := 0; i < 50; i++ {
+		resp, err = client.Make(EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl("http://docker/version")))
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	defer resp.Body().Close()
+
+	body, _ := io.ReadAll(resp.Body())
+	if resp.StatusCode() != specs.StatusCodeOK || string(body) != "docker" {
+		t.Errorf("unexpected response %d %q", resp.StatusCode(), body)
+	}
+}
+
+func TestServer_DualStack(t *testing.T) {
+	listener, err := listen(":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	if conn, err := net.Dial("tcp4", "127.0.0.1:"+port); err != nil {
+		t.Errorf("expected IPv4 connection: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	if ipv6, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Log("IPv6 is not available")
+	} else {
+		ipv6.Close()
+		if conn, err := net.Dial("tcp6", "[::1]:"+port); err != nil {
+			t.Errorf("expected IPv6 connection: %v", err)
+		} else {
+			conn.Close()
+		}
+	}
+}
@@ -26,6 +26,9 @@ const (
 	ContentTypePDF        = "application/pdf"
 	ContentTypeJavaScript = "text/javascript"
 	ContentTypeFontTTF    = "font/ttf"
+	ContentTypeFontWOFF   = "font/woff"
+	ContentTypeFontWOFF2  = "font/woff2"
+	ContentTypeWASM       = "application/wasm"
 
 	ContentTypeAVI  = "video/x-msvideo"
 	ContentTypeWAV  = "audio/wav"
@@ -42,14 +45,17 @@ const (
 	ContentTypePNG  = "image/png"
 	ContentTypeWEBP = "image/webp"
 	ContentTypeSVG  = "image/svg+xml"
+	ContentTypeICO  = "image/x-icon"
 
-	ContentTypeJson           = "application/json"
-	ContentTypeXml            = "application/xml"
-	ContentTypeMsgpack        = "application/msgpack"
-	ContentTypeProtobuf       = "application/x-protobuf"
-	ContentTypeForm           = "application/x-www-form-urlencoded"
-	ContentTypeMultipart      = "multipart/form-data"
-	ContentTypeMultipartMixed = "multipart/mixed"
+	ContentTypeJson                = "application/json"
+	ContentTypeXml                 = "application/xml"
+	ContentTypeMsgpack             = "application/msgpack"
+	ContentTypeProtobuf            = "application/x-protobuf"
+	ContentTypeForm                = "application/x-www-form-urlencoded"
+	ContentTypeMultipart           = "multipart/form-data"
+	ContentTypeMultipartMixed      = "multipart/mixed"
+	ContentTypeMultipartByteranges = "multipart/byteranges"
+	ContentTypeEventStream         = "text/event-stream"
 )
 
 // MatchContentType reports whether the Content-Type header value of h
@@ -67,3 +73,55 @@ func MatchContentType(header *Header, expected string) bool {
 	mediaType := strings.TrimSpace(strings.ToLower(base))
 	return expected == mediaType
 }
+
+var extensionContentTypes = map[string]string{
+	".txt":   ContentTypePlain,
+	".rtf":   ContentTypeRichText,
+	".md":    ContentTypeMarkdown,
+	".html":  ContentTypeHTML,
+	".htm":   ContentTypeHTML,
+	".csv":   ContentTypeCSV,
+	".css":   ContentTypeCSS,
+	".pdf":   ContentTypePDF,
+	".js":    ContentTypeJavaScript,
+	".mjs":   ContentTypeJavaScript,
+	".ttf":   ContentTypeFontTTF,
+	".woff":  ContentTypeFontWOFF,
+	".woff2": ContentTypeFontWOFF2,
+	".wasm":  ContentTypeWASM,
+
+	".avi":  ContentTypeAVI,
+	".wav":  ContentTypeWAV,
+	".mp3":  ContentTypeMP3,
+	".mp4":  ContentTypeMP4,
+	".mpeg": ContentTypeMPEG,
+	".mpg":  ContentTypeMPEG,
+	".mpv":  ContentTypeMPV,
+	".mkv":  ContentTypeMKV,
+
+	".avif": ContentTypeAVIF,
+	".bmp":  ContentTypeBMP,
+	".gif":  ContentTypeGIF,
+	".jpeg": ContentTypeJPEG,
+	".jpg":  ContentTypeJPEG,
+	".png":  ContentTypePNG,
+	".webp": ContentTypeWEBP,
+	".svg":  ContentTypeSVG,
+	".ico":  ContentTypeICO,
+
+	".json":    ContentTypeJson,
+	".map":     ContentTypeJson,
+	".xml":     ContentTypeXml,
+	".msgpack": ContentTypeMsgpack,
+}
+
+// ContentTypeByExtension returns the content type of a file by its extension
+// with leading dot, such as ".html", the extension is case-insensitive.
+//
+// If the extension is unknown, [ContentTypeRaw] is returned.
+func ContentTypeByExtension(ext string) string {
+	if contentType, ok := extensionContentTypes[strings.ToLower(ext)]; ok {
+		return contentType
+	}
+	return ContentTypeRaw
+}
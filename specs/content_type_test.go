@@ -71,3 +71,21 @@ func TestMatchContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestContentTypeByExtension(t *testing.T) {
+	tests := map[string]string{
+		".html":  ContentTypeHTML,
+		".CSS":   ContentTypeCSS,
+		".js":    ContentTypeJavaScript,
+		".jpg":   ContentTypeJPEG,
+		".woff2": ContentTypeFontWOFF2,
+		".json":  ContentTypeJson,
+		".bin":   ContentTypeRaw,
+		"":       ContentTypeRaw,
+	}
+	for ext, expected := range tests {
+		if got := ContentTypeByExtension(ext); got != expected {
+			t.Errorf("ContentTypeByExtension(%q) = %q, want %q", ext, got, expected)
+		}
+	}
+}
@@ -1,101 +1,177 @@
 package specs
 
 import (
-	"github.com/oesand/plow/internal"
-	"github.com/oesand/plow/internal/plain"
-	"golang.org/x/net/publicsuffix"
+	"cmp"
 	"iter"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Default limits of the [CookieJar] recommended by RFC 6265.
+const (
+	DefaultMaxCookieSize       = 4096
+	DefaultMaxCookiesPerDomain = 50
+	DefaultMaxCookies          = 3000
 )
 
 // NewCookieJar creates a new CookieJar instance.
 func NewCookieJar() *CookieJar {
 	return &CookieJar{
-		cookies: make(map[string]map[string]*Cookie),
+		cookies: make(map[string]map[string]*StoredCookie),
 	}
 }
 
-// CookieJar is a thread-safe cookie storage that allows storing and retrieving cookies
-// based on the host they are associated with. It uses the Effective TLD Plus One (eTLD+1) rule
-// to determine the host for which the cookies are valid.
+// CookieJar is a thread-safe cookie storage which follows the rules of RFC 6265.
+//
+// Cookies are stored by name, domain and path, and are sent only to urls
+// matched by "Domain" and "Path" attributes, cookies without "Domain" attribute
+// are sent only to the exact host which set them.
+// Cookies with "Secure" attribute are accepted from and sent over secure schemes only,
+// "Domain" attribute of a public suffix such as "com" is rejected.
+//
+// Cookies can be persisted between sessions with [CookieJar.Save] and [CookieJar.Load].
 type CookieJar struct {
-	cookies map[string]map[string]*Cookie
+	// MaxCookieSize maximum size in bytes of the cookie name and value,
+	// larger cookies are ignored.
+	// if not specified is used [DefaultMaxCookieSize]
+	MaxCookieSize int
+
+	// MaxCookiesPerDomain maximum number of cookies for each registrable domain,
+	// the least recently used cookies are removed on exceeding.
+	// if not specified is used [DefaultMaxCookiesPerDomain]
+	MaxCookiesPerDomain int
+
+	// MaxCookies maximum number of cookies in the jar,
+	// the least recently used cookies are removed on exceeding.
+	// if not specified is used [DefaultMaxCookies]
+	MaxCookies int
+
+	// SaveSessionCookies allows [CookieJar.Save] to persist cookies without expiration,
+	// which are otherwise discarded at the end of the session.
+	SaveSessionCookies bool
+
+	cookies map[string]map[string]*StoredCookie
+	count   int
 
 	mu sync.RWMutex
 }
 
-// GetCookie retrieves a cookie by its host and name.
+// StoredCookie is a cookie of the [CookieJar] with the state
+// required to match and restore it.
+type StoredCookie struct {
+	Cookie
+
+	// HostOnly specifies the cookie is sent only to the host of its domain.
+	HostOnly bool
+
+	// Creation is the time when the cookie was created.
+	Creation time.Time
+
+	// LastAccess is the time when the cookie was last sent or updated.
+	LastAccess time.Time
+}
+
+// GetCookie retrieves a cookie by its host and case-insensitive name
+// from cookies which domain matches the host,
+// the cookie with exactly the same name is preferred.
 //
-// If the cookie is expired, it will be removed from the jar.
 // If the host is not valid or the cookie does not exist, it returns nil.
 func (jar *CookieJar) GetCookie(host string, name string) *Cookie {
-	jar.mu.RLock()
-	defer jar.mu.RUnlock()
-	if jar.cookies == nil || len(jar.cookies) == 0 {
-		return nil
+	var found *Cookie
+	for cookie := range jar.Cookies(host) {
+		if cookie.Name == name {
+			return &cookie
+		}
+		if found == nil && strings.EqualFold(cookie.Name, name) {
+			found = &cookie
+		}
 	}
+	return found
+}
 
-	host, err := publicsuffix.EffectiveTLDPlusOne(host)
-	if err != nil {
-		return nil
+// Cookies returns an iterator over all cookies which domain matches the given host
+// regardless of the path and security.
+//
+// Use [CookieJar.CookiesForUrl] to get cookies to be sent with the request.
+func (jar *CookieJar) Cookies(host string) iter.Seq[Cookie] {
+	return func(yield func(Cookie) bool) {
+		for _, stored := range jar.matchCookies(host, func(*StoredCookie) bool { return true }) {
+			if !yield(stored.Cookie) {
+				break
+			}
+		}
 	}
+}
 
-	sub, has := jar.cookies[host]
-	if !has {
-		return nil
+// CookiesForUrl returns an iterator over cookies to be sent with the request to the url,
+// cookies with longer paths are listed first.
+func (jar *CookieJar) CookiesForUrl(url *Url) iter.Seq[Cookie] {
+	secure := isSecureScheme(url.Scheme)
+	path := url.Path
+	if path == "" {
+		path = "/"
 	}
 
-	name = plain.TitleCase(name)
-	value, has := sub[name]
-	if has {
-		if value.IsExpired(time.Now()) {
-			delete(sub, name)
-		} else {
-			copied := *value
-			return &copied
+	return func(yield func(Cookie) bool) {
+		matched := jar.matchCookies(url.Host, func(stored *StoredCookie) bool {
+			return (secure || !stored.Secure) && pathMatch(path, stored.Path)
+		})
+		for _, stored := range matched {
+			if !yield(stored.Cookie) {
+				break
+			}
 		}
 	}
-	return nil
 }
 
-// Cookies returns an iterator over all cookies associated with the given host.
-func (jar *CookieJar) Cookies(host string) iter.Seq[Cookie] {
-	jar.mu.RLock()
-	defer jar.mu.RUnlock()
-	if jar.cookies == nil || len(jar.cookies) == 0 {
-		return internal.EmptyIterSeq[Cookie]()
+// matchCookies returns copies of not expired cookies which domain matches the host and the filter,
+// and updates their last access time.
+func (jar *CookieJar) matchCookies(host string, filter func(*StoredCookie) bool) []StoredCookie {
+	host = canonicalCookieHost(host)
+	if host == "" {
+		return nil
 	}
 
-	tdl, err := publicsuffix.EffectiveTLDPlusOne(host)
-	if err != nil {
-		return internal.EmptyIterSeq[Cookie]()
-	}
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
 
-	sub, has := jar.cookies[tdl]
-	if !has {
-		return internal.EmptyIterSeq[Cookie]()
+	sub := jar.cookies[cookieJarKey(host)]
+	if len(sub) == 0 {
+		return nil
 	}
 
-	return func(yield func(Cookie) bool) {
-		jar.mu.RLock()
-		defer jar.mu.RUnlock()
-
-		now := time.Now()
-		var expired []string
-		for subKey, cookie := range internal.IterMapSorted(sub) {
-			if cookie.IsExpired(now) {
-				expired = append(expired, subKey)
-				continue
-			}
-			if !yield(*cookie) {
-				break
-			}
+	now := time.Now()
+	var matched []StoredCookie
+	for id, stored := range sub {
+		if stored.IsExpired(now) {
+			jar.remove(sub, id)
+			continue
+		}
+		if stored.HostOnly && host != stored.Domain || !stored.HostOnly && !domainMatch(host, stored.Domain) {
+			continue
 		}
-		for _, key := range expired {
-			delete(sub, key)
+		if !filter(stored) {
+			continue
 		}
+		stored.LastAccess = now
+		matched = append(matched, *stored)
 	}
+
+	slices.SortFunc(matched, func(a, b StoredCookie) int {
+		if c := cmp.Compare(len(b.Path), len(a.Path)); c != 0 {
+			return c
+		}
+		if c := a.Creation.Compare(b.Creation); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return matched
 }
 
 // SetCookie sets a single cookie for the specified host.
@@ -107,41 +183,217 @@ func (jar *CookieJar) SetCookie(host string, cookie Cookie) {
 
 // SetCookies sets multiple cookies for the specified host.
 func (jar *CookieJar) SetCookies(host string, cookies []Cookie) {
-	jar.SetCookiesIter(host, func(yield func(Cookie) bool) {
-		for _, cookie := range cookies {
-			if !yield(cookie) {
-				break
-			}
-		}
-	})
+	jar.SetCookiesIter(host, slices.Values(cookies))
 }
 
-// SetCookiesIter sets multiple cookies for the specified host using an iterator.
+// SetCookiesIter sets multiple cookies for the specified host using an iterator,
+// the cookies are handled as received over secure connection from the root path of the host.
 func (jar *CookieJar) SetCookiesIter(host string, cookies iter.Seq[Cookie]) {
-	jar.mu.Lock()
-	defer jar.mu.Unlock()
+	jar.SetCookiesForUrl(&Url{Scheme: "https", Host: host, Path: "/"}, cookies)
+}
 
-	tdl, err := publicsuffix.EffectiveTLDPlusOne(host)
-	if err != nil {
+// SetCookiesForUrl sets cookies received in the response to the request to the url.
+//
+// Cookies with invalid "Domain" attribute, larger than [CookieJar.MaxCookieSize]
+// or with "Secure" attribute received over insecure scheme are ignored,
+// expired cookies remove the stored cookies with the same name, domain and path.
+func (jar *CookieJar) SetCookiesForUrl(url *Url, cookies iter.Seq[Cookie]) {
+	host := canonicalCookieHost(url.Host)
+	if host == "" {
 		return
 	}
+	secure := isSecureScheme(url.Scheme)
 
-	if jar.cookies == nil {
-		jar.cookies = make(map[string]map[string]*Cookie)
+	maxSize := jar.MaxCookieSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxCookieSize
 	}
 
-	sub, has := jar.cookies[tdl]
-	if !has {
-		sub = map[string]*Cookie{}
-		jar.cookies[tdl] = sub
-	}
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
 
 	now := time.Now()
 	for cookie := range cookies {
-		if cookie.IsExpired(now) {
+		if cookie.Name == "" || len(cookie.Name)+len(cookie.Value) > maxSize {
+			continue
+		}
+		if cookie.Secure && !secure {
+			continue
+		}
+
+		stored := &StoredCookie{
+			Cookie:     cookie,
+			Creation:   now,
+			LastAccess: now,
+		}
+
+		domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+		if domain != "" && isPublicSuffix(domain) {
+			if domain != host {
+				continue
+			}
+			domain = ""
+		}
+		if domain == "" {
+			stored.HostOnly = true
+			stored.Domain = host
+		} else if domainMatch(host, domain) {
+			stored.Domain = domain
+		} else {
 			continue
 		}
 
-		sub[plain.TitleCase(cookie.Name)] = &cookie
+		if stored.Path == "" || stored.Path[0] != '/' {
+			stored.Path = defaultCookiePath(url.Path)
+		}
+
+		jar.store(stored, now)
+	}
+}
+
+// store replaces the cookie with the same name, domain and path
+// or removes it if the cookie is expired.
+func (jar *CookieJar) store(stored *StoredCookie, now time.Time) {
+	key := cookieJarKey(stored.Domain)
+	id := stored.Name + ";" + stored.Domain + ";" + stored.Path
+
+	if jar.cookies == nil {
+		jar.cookies = make(map[string]map[string]*StoredCookie)
+	}
+	sub, has := jar.cookies[key]
+	if !has {
+		sub = map[string]*StoredCookie{}
+		jar.cookies[key] = sub
+	}
+
+	old, has := sub[id]
+	if stored.IsExpired(now) {
+		if has {
+			jar.remove(sub, id)
+		}
+		return
+	}
+
+	if has {
+		stored.Creation = old.Creation
+	} else {
+		jar.count++
+	}
+	sub[id] = stored
+
+	jar.evict(sub, now)
+}
+
+// evict removes expired and the least recently used cookies
+// exceeding the limits of the jar.
+func (jar *CookieJar) evict(sub map[string]*StoredCookie, now time.Time) {
+	maxPerDomain := jar.MaxCookiesPerDomain
+	if maxPerDomain <= 0 {
+		maxPerDomain = DefaultMaxCookiesPerDomain
+	}
+	maxCookies := jar.MaxCookies
+	if maxCookies <= 0 {
+		maxCookies = DefaultMaxCookies
+	}
+
+	if len(sub) > maxPerDomain {
+		for id, stored := range sub {
+			if stored.IsExpired(now) {
+				jar.remove(sub, id)
+			}
+		}
+		for len(sub) > maxPerDomain {
+			jar.remove(sub, leastRecentlyUsed(sub))
+		}
+	}
+
+	for jar.count > maxCookies {
+		var oldestSub map[string]*StoredCookie
+		var oldestId string
+		for _, other := range jar.cookies {
+			if id := leastRecentlyUsed(other); id != "" &&
+				(oldestSub == nil || other[id].LastAccess.Before(oldestSub[oldestId].LastAccess)) {
+				oldestSub, oldestId = other, id
+			}
+		}
+		jar.remove(oldestSub, oldestId)
+	}
+}
+
+func (jar *CookieJar) remove(sub map[string]*StoredCookie, id string) {
+	if _, has := sub[id]; has {
+		delete(sub, id)
+		jar.count--
+	}
+}
+
+func leastRecentlyUsed(sub map[string]*StoredCookie) string {
+	var oldest string
+	for id, stored := range sub {
+		if oldest == "" || stored.LastAccess.Before(sub[oldest].LastAccess) {
+			oldest = id
+		}
+	}
+	return oldest
+}
+
+// canonicalCookieHost returns lower-cased host without brackets of IPv6 address.
+func canonicalCookieHost(host string) string {
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// cookieJarKey returns the registrable domain (eTLD+1) of the host
+// or the host itself for IP addresses and public suffixes.
+func cookieJarKey(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	key, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return key
+}
+
+func isPublicSuffix(domain string) bool {
+	if net.ParseIP(domain) != nil {
+		return false
+	}
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+func isSecureScheme(scheme string) bool {
+	return scheme == "https" || scheme == "wss"
+}
+
+// domainMatch checks the host is the domain or its subdomain, as defined in RFC 6265 section 5.1.3.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, domain) && host[len(host)-len(domain)-1] == '.' &&
+		net.ParseIP(host) == nil
+}
+
+// pathMatch checks the request path matches the cookie path, as defined in RFC 6265 section 5.1.4.
+func pathMatch(path, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+	return strings.HasPrefix(path, cookiePath) &&
+		(strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/')
+}
+
+// defaultCookiePath returns the directory of the request path, as defined in RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
 	}
+	return path[:i]
 }
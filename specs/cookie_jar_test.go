@@ -1,6 +1,10 @@
 package specs
 
 import (
+	"iter"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -36,6 +40,28 @@ func TestSetAndGetCookie(t *testing.T) {
 	}
 }
 
+func TestGetCookieCaseInsensitive(t *testing.T) {
+	jar := &CookieJar{}
+	jar.SetCookie("www.example.com", makeCookie("Session", false))
+
+	got := jar.GetCookie("www.example.com", "session")
+	if got == nil || got.Name != "Session" {
+		t.Fatalf("expected cookie Session by case-insensitive name, got %+v", got)
+	}
+
+	exact := makeCookie("session", false)
+	exact.Value = "exact"
+	jar.SetCookie("www.example.com", exact)
+
+	got = jar.GetCookie("www.example.com", "session")
+	if got == nil || got.Value != "exact" {
+		t.Errorf("expected cookie with exactly the same name, got %+v", got)
+	}
+	if got = jar.GetCookie("www.example.com", "Session"); got == nil || got.Name != "Session" {
+		t.Errorf("expected cookie Session, got %+v", got)
+	}
+}
+
 func TestGetCookieNilMap(t *testing.T) {
 	jar := &CookieJar{} // cookies map is nil
 
@@ -180,3 +206,175 @@ func TestIsExpiredWithMaxAge(t *testing.T) {
 		t.Error("Expires not updated correctly")
 	}
 }
+
+func collectCookieNames(cookies iter.Seq[Cookie]) []string {
+	var names []string
+	for cookie := range cookies {
+		names = append(names, cookie.Name)
+	}
+	return names
+}
+
+func TestCookieJar_Domain(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookiesForUrl(MustParseUrl("https://www.example.com/"), slices.Values([]Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "1", Domain: ".example.com"},
+		{Name: "other", Value: "1", Domain: "other.com"},
+		{Name: "suffix", Value: "1", Domain: "com"},
+		{Name: "sub", Value: "1", Domain: "api.www.example.com"},
+	}))
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://www.example.com/", []string{"domain", "host"}},
+		{"https://example.com/", []string{"domain"}},
+		{"https://api.example.com/", []string{"domain"}},
+		{"https://api.www.example.com/", []string{"domain"}},
+		{"https://other.com/", nil},
+	}
+	for _, tt := range tests {
+		if got := collectCookieNames(jar.CookiesForUrl(MustParseUrl(tt.url))); !slices.Equal(got, tt.want) {
+			t.Errorf("CookiesForUrl(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCookieJar_Path(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookiesForUrl(MustParseUrl("https://example.com/api/users/1"), slices.Values([]Cookie{
+		{Name: "default", Value: "1"},
+		{Name: "root", Value: "1", Path: "/"},
+		{Name: "admin", Value: "1", Path: "/admin"},
+	}))
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/users", []string{"default", "root"}},
+		{"/api/users/2", []string{"default", "root"}},
+		{"/api/usersx", []string{"root"}},
+		{"/admin/panel", []string{"admin", "root"}},
+		{"/", []string{"root"}},
+	}
+	for _, tt := range tests {
+		if got := collectCookieNames(jar.CookiesForUrl(MustParseUrl("https://example.com" + tt.path))); !slices.Equal(got, tt.want) {
+			t.Errorf("CookiesForUrl(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCookieJar_Secure(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookiesForUrl(MustParseUrl("http://example.com/"), slices.Values([]Cookie{
+		{Name: "insecure-secure", Value: "1", Secure: true},
+	}))
+	jar.SetCookiesForUrl(MustParseUrl("https://example.com/"), slices.Values([]Cookie{
+		{Name: "plain", Value: "1"},
+		{Name: "secure", Value: "1", Secure: true},
+	}))
+
+	if got := collectCookieNames(jar.CookiesForUrl(MustParseUrl("http://example.com/"))); !slices.Equal(got, []string{"plain"}) {
+		t.Errorf("expected secure cookie not sent over http, got %v", got)
+	}
+	if got := collectCookieNames(jar.CookiesForUrl(MustParseUrl("https://example.com/"))); !slices.Equal(got, []string{"plain", "secure"}) {
+		t.Errorf("expected secure cookie sent over https, got %v", got)
+	}
+}
+
+func TestCookieJar_Replace(t *testing.T) {
+	jar := NewCookieJar()
+	url := MustParseUrl("https://example.com/")
+	jar.SetCookiesForUrl(url, slices.Values([]Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "Session", Value: "2"},
+		{Name: "session", Value: "3", Path: "/api"},
+	}))
+	jar.SetCookiesForUrl(url, slices.Values([]Cookie{{Name: "session", Value: "4"}}))
+
+	if got := jar.GetCookie("example.com", "session"); got == nil || got.Value != "3" {
+		t.Errorf("expected cookie with longer path first, got %+v", got)
+	}
+
+	var values []string
+	for cookie := range jar.CookiesForUrl(MustParseUrl("https://example.com/api")) {
+		values = append(values, cookie.Name+"="+cookie.Value)
+	}
+	if len(values) != 3 || values[0] != "session=3" || !slices.Contains(values, "session=4") || !slices.Contains(values, "Session=2") {
+		t.Errorf("unexpected cookies %v", values)
+	}
+
+	jar.SetCookiesForUrl(url, slices.Values([]Cookie{{Name: "session", Value: "", Expires: time.Unix(0, 0)}}))
+	if got := collectCookieNames(jar.CookiesForUrl(url)); !slices.Equal(got, []string{"Session"}) {
+		t.Errorf("expected cookie removed by expired cookie, got %v", got)
+	}
+}
+
+func TestCookieJar_Limits(t *testing.T) {
+	jar := &CookieJar{MaxCookieSize: 16, MaxCookiesPerDomain: 2, MaxCookies: 3}
+	url := MustParseUrl("https://example.com/")
+
+	jar.SetCookiesForUrl(url, slices.Values([]Cookie{{Name: "large", Value: strings.Repeat("x", 16)}}))
+	if jar.GetCookie("example.com", "large") != nil {
+		t.Error("expected large cookie ignored")
+	}
+
+	for _, name := range []string{"a", "b"} {
+		jar.SetCookiesForUrl(url, slices.Values([]Cookie{{Name: name, Value: "1", Path: "/" + name}}))
+		time.Sleep(time.Millisecond)
+	}
+	collectCookieNames(jar.CookiesForUrl(MustParseUrl("https://example.com/a")))
+	time.Sleep(time.Millisecond)
+	jar.SetCookiesForUrl(url, slices.Values([]Cookie{{Name: "c", Value: "1", Path: "/other"}}))
+	if got := collectCookieNames(jar.Cookies("example.com")); !slices.Equal(got, []string{"c", "a"}) {
+		t.Errorf("expected least recently used cookie evicted by domain limit, got %v", got)
+	}
+
+	time.Sleep(time.Millisecond)
+	jar.SetCookiesForUrl(MustParseUrl("https://other.com/"), slices.Values([]Cookie{{Name: "d", Value: "1"}, {Name: "e", Value: "1"}}))
+	if got := collectCookieNames(jar.Cookies("example.com")); len(got) != 1 {
+		t.Errorf("expected least recently used cookie evicted by total limit, got %v", got)
+	}
+}
+
+func TestCookieJar_Persistence(t *testing.T) {
+	store := NewFileCookieStore(filepath.Join(t.TempDir(), "cookies.json"))
+
+	jar := NewCookieJar()
+	if err := jar.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookiesForUrl(MustParseUrl("https://www.example.com/api/"), slices.Values([]Cookie{
+		{Name: "persistent", Value: "1", MaxAge: 3600},
+		{Name: "domain", Value: "1", Domain: "example.com", Expires: time.Now().Add(time.Hour)},
+		{Name: "session", Value: "1"},
+	}))
+	if err := jar.Save(store); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewCookieJar()
+	if err := loaded.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	if got := collectCookieNames(loaded.CookiesForUrl(MustParseUrl("https://www.example.com/api/"))); !slices.Equal(got, []string{"domain", "persistent"}) {
+		t.Errorf("unexpected loaded cookies %v", got)
+	}
+	if got := collectCookieNames(loaded.CookiesForUrl(MustParseUrl("https://example.com/api/"))); !slices.Equal(got, []string{"domain"}) {
+		t.Errorf("expected host-only cookie restored, got %v", got)
+	}
+
+	jar.SaveSessionCookies = true
+	if err := jar.Save(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Load(store); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GetCookie("www.example.com", "session") == nil {
+		t.Error("expected session cookie saved")
+	}
+}
@@ -0,0 +1,124 @@
+package specs
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CookieStore is an interface representing persistent storage
+// of the [CookieJar] cookies between sessions.
+type CookieStore interface {
+	// LoadCookies returns the stored cookies.
+	LoadCookies() ([]StoredCookie, error)
+
+	// SaveCookies replaces the stored cookies.
+	SaveCookies(cookies []StoredCookie) error
+}
+
+// NewFileCookieStore returns a [CookieStore] which keeps cookies
+// in the file at the path in JSON format.
+//
+// Missing file is loaded as empty, the file is written
+// with owner only permissions since it contains credentials.
+func NewFileCookieStore(path string) CookieStore {
+	if path == "" {
+		panic("plow: empty cookie store path")
+	}
+	return fileCookieStore(path)
+}
+
+type fileCookieStore string
+
+func (path fileCookieStore) LoadCookies() ([]StoredCookie, error) {
+	data, err := os.ReadFile(string(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cookies []StoredCookie
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+func (path fileCookieStore) SaveCookies(cookies []StoredCookie) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(string(path)), filepath.Base(string(path))+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), string(path))
+}
+
+// Load adds the cookies of the store to the jar,
+// expired cookies are skipped.
+func (jar *CookieJar) Load(store CookieStore) error {
+	if store == nil {
+		panic("plow: nil cookie store")
+	}
+
+	cookies, err := store.LoadCookies()
+	if err != nil {
+		return err
+	}
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		if cookie.Name == "" || cookie.Domain == "" || cookie.Path == "" {
+			continue
+		}
+		stored := cookie
+		jar.store(&stored, now)
+	}
+	return nil
+}
+
+// Save replaces the cookies of the store with not expired cookies of the jar.
+//
+// Cookies without expiration are saved only if [CookieJar.SaveSessionCookies] is set.
+func (jar *CookieJar) Save(store CookieStore) error {
+	if store == nil {
+		panic("plow: nil cookie store")
+	}
+
+	jar.mu.RLock()
+	now := time.Now()
+	var cookies []StoredCookie
+	for _, sub := range jar.cookies {
+		for _, stored := range sub {
+			if stored.Expires.IsZero() && !jar.SaveSessionCookies {
+				continue
+			}
+			if !stored.Expires.IsZero() && now.After(stored.Expires) {
+				continue
+			}
+			cookies = append(cookies, *stored)
+		}
+	}
+	jar.mu.RUnlock()
+
+	return store.SaveCookies(cookies)
+}
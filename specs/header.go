@@ -3,6 +3,7 @@ package specs
 import (
 	"iter"
 	"maps"
+	"slices"
 
 	"github.com/oesand/plow/internal"
 	"github.com/oesand/plow/internal/plain"
@@ -21,15 +22,24 @@ func NewHeader(configure ...func(header *Header)) *Header {
 
 // Header represents a collection of HTTP headers and cookies.
 // It provides methods to set, get, and manipulate headers and cookies.
+//
+// A header may have multiple values, which are kept in the order they were added.
 type Header struct {
-	headers map[string]string
+	headers map[string][]string
 	cookies map[string]*Cookie
 }
 
 // Clone creates a deep copy of the Header instance.
 func (header *Header) Clone() *Header {
+	var headers map[string][]string
+	if header.headers != nil {
+		headers = make(map[string][]string, len(header.headers))
+		for name, values := range header.headers {
+			headers[name] = slices.Clone(values)
+		}
+	}
 	return &Header{
-		headers: maps.Clone(header.headers),
+		headers: headers,
 		cookies: maps.Clone(header.cookies),
 	}
 }
@@ -39,21 +49,30 @@ func (header *Header) Any() bool {
 	return header.headers != nil && len(header.headers) > 0
 }
 
-// Get retrieves the value of a header by its name.
+// Get retrieves the first value of a header by its name.
 func (header *Header) Get(name string) string {
 	value, _ := header.TryGet(name)
 	return value
 }
 
-// TryGet attempts to retrieve the value of a header by its name.
+// TryGet attempts to retrieve the first value of a header by its name.
 func (header *Header) TryGet(name string) (string, bool) {
 	if header.Any() {
-		value, has := header.headers[plain.TitleCase(name)]
-		return value, has
+		if values, has := header.headers[plain.TitleCase(name)]; has {
+			return values[0], true
+		}
 	}
 	return "", false
 }
 
+// Values retrieves all values of a header by its name in the order they were added.
+func (header *Header) Values(name string) []string {
+	if header.Any() {
+		return slices.Clone(header.headers[plain.TitleCase(name)])
+	}
+	return nil
+}
+
 // Has checks if a header with the specified name exists.
 func (header *Header) Has(name string) bool {
 	if header.Any() {
@@ -63,18 +82,31 @@ func (header *Header) Has(name string) bool {
 	return false
 }
 
-// Set adds or updates a header with the specified name and value.
+// Set adds or updates a header with the specified name and value,
+// replacing any existing values.
 func (header *Header) Set(name, value string) {
+	name = header.prepareName(name)
+	header.headers[name] = []string{value}
+}
+
+// Add appends the value to the header with the specified name,
+// keeping any existing values.
+func (header *Header) Add(name, value string) {
+	name = header.prepareName(name)
+	header.headers[name] = append(header.headers[name], value)
+}
+
+func (header *Header) prepareName(name string) string {
 	name = plain.TitleCase(name)
 	if name == "Set-Cookie" || name == "Cookie" {
 		panic("plow: header not support direct set cookie, use method 'SetCookie'")
 	} else if header.headers == nil {
-		header.headers = map[string]string{}
+		header.headers = map[string][]string{}
 	}
-	header.headers[name] = value
+	return name
 }
 
-// Del removes a header by its name.
+// Del removes all values of a header by its name.
 func (header *Header) Del(name string) {
 	if header.Any() {
 		delete(header.headers, plain.TitleCase(name))
@@ -82,11 +114,22 @@ func (header *Header) Del(name string) {
 }
 
 // All returns an iterator over all headers in the Header instance.
+//
+// Every value of a header with multiple values is yielded separately
+// in the order they were added.
 func (header *Header) All() iter.Seq2[string, string] {
 	if !header.Any() {
 		return internal.EmptyIterSeq2[string, string]()
 	}
-	return internal.IterMapSorted(header.headers)
+	return func(yield func(string, string) bool) {
+		for name, values := range internal.IterMapSorted(header.headers) {
+			for _, value := range values {
+				if !yield(name, value) {
+					return
+				}
+			}
+		}
+	}
 }
 
 // AnyCookies checks if the Header contains any cookies.
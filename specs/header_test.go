@@ -0,0 +1,55 @@
+package specs
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHeader_MultipleValues(t *testing.T) {
+	header := NewHeader()
+	header.Add("Vary", "Accept-Encoding")
+	header.Add("vary", "Origin")
+	header.Add("Vary", "Origin")
+
+	if value := header.Get("Vary"); value != "Accept-Encoding" {
+		t.Errorf("expected first value, got %q", value)
+	}
+	expected := []string{"Accept-Encoding", "Origin", "Origin"}
+	if values := header.Values("VARY"); !slices.Equal(values, expected) {
+		t.Errorf("expected values %v, got %v", expected, values)
+	}
+
+	var all []string
+	for name, value := range header.All() {
+		all = append(all, name+": "+value)
+	}
+	expected = []string{"Vary: Accept-Encoding", "Vary: Origin", "Vary: Origin"}
+	if !slices.Equal(all, expected) {
+		t.Errorf("expected all %v, got %v", expected, all)
+	}
+
+	header.Set("Vary", "*")
+	if values := header.Values("Vary"); !slices.Equal(values, []string{"*"}) {
+		t.Errorf("expected replaced values, got %v", values)
+	}
+
+	header.Del("Vary")
+	if header.Has("Vary") || header.Values("Vary") != nil {
+		t.Error("expected deleted header")
+	}
+}
+
+func TestHeader_CloneValues(t *testing.T) {
+	header := NewHeader()
+	header.Add("Via", "1.1 first")
+
+	clone := header.Clone()
+	clone.Add("Via", "1.1 second")
+
+	if values := header.Values("Via"); !slices.Equal(values, []string{"1.1 first"}) {
+		t.Errorf("expected original values untouched, got %v", values)
+	}
+	if values := clone.Values("Via"); !slices.Equal(values, []string{"1.1 first", "1.1 second"}) {
+		t.Errorf("expected cloned values, got %v", values)
+	}
+}
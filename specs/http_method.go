@@ -41,3 +41,11 @@ func (method HttpMethod) IsPostable() bool {
 func (method HttpMethod) IsReplyable() bool {
 	return !(method == HttpMethodHead || method == HttpMethodConnect || method == HttpMethodOptions)
 }
+
+// IsIdempotent checks if the HttpMethod is idempotent as defined in RFC 9110,
+// so the request can be repeated without changing the result.
+func (method HttpMethod) IsIdempotent() bool {
+	return method == HttpMethodGet || method == HttpMethodHead ||
+		method == HttpMethodPut || method == HttpMethodDelete ||
+		method == HttpMethodOptions || method == HttpMethodTrace
+}
@@ -1,15 +1,20 @@
 package specs
 
 import (
-	"github.com/oesand/plow/internal"
-	"github.com/oesand/plow/internal/plain"
+	"maps"
+	"slices"
 	"strings"
+
+	"github.com/oesand/plow/internal/plain"
 )
 
 // Query represents a parsed query string from a URL.
-type Query map[string]string
+//
+// A key may have multiple values, which are kept in the order they were added.
+type Query map[string][]string
 
 // ParseQuery parses a query string into a Query map.
+// All values of repeated keys are kept in the order they appear.
 func ParseQuery(query string) Query {
 	q := make(Query)
 	if query == "" {
@@ -27,16 +32,13 @@ func ParseQuery(query string) Query {
 		if err != nil {
 			continue
 		}
-		if _, has := q[decodedKey]; has {
-			continue
-		}
 
 		decodedValue, err := plain.UnEscapeUrl(value, plain.EscapingQueryComponent)
 		if err != nil {
 			continue
 		}
 
-		q[decodedKey] = decodedValue
+		q.Add(decodedKey, decodedValue)
 	}
 
 	return q
@@ -47,19 +49,65 @@ func (q Query) Any() bool {
 	return q != nil && len(q) > 0
 }
 
+// Get retrieves the first value of the key.
+func (q Query) Get(key string) string {
+	value, _ := q.TryGet(key)
+	return value
+}
+
+// TryGet attempts to retrieve the first value of the key.
+func (q Query) TryGet(key string) (string, bool) {
+	if values := q[key]; len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// Values retrieves a copy of all values of the key in the order they were added.
+func (q Query) Values(key string) []string {
+	return slices.Clone(q[key])
+}
+
+// Has checks if the key exists.
+func (q Query) Has(key string) bool {
+	_, has := q[key]
+	return has
+}
+
+// Set sets the key to the value, replacing any existing values.
+func (q Query) Set(key, value string) {
+	q[key] = []string{value}
+}
+
+// Add appends the value to the key, keeping any existing values.
+func (q Query) Add(key, value string) {
+	q[key] = append(q[key], value)
+}
+
+// Del removes all values of the key.
+func (q Query) Del(key string) {
+	delete(q, key)
+}
+
 // String returns the query string representation of the Query.
+//
+// Keys are encoded in sorted order, values of each key
+// in the order they were added.
 func (q Query) String() string {
 	if q == nil || len(q) == 0 {
 		return ""
 	}
 	var buf strings.Builder
-	for k, v := range internal.IterMapSorted(q) {
-		if buf.Len() > 0 {
-			buf.WriteByte('&')
+	for _, k := range slices.Sorted(maps.Keys(q)) {
+		escapedKey := plain.EscapeUrl(k, plain.EscapingQueryComponent)
+		for _, v := range q[k] {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(escapedKey)
+			buf.WriteByte('=')
+			buf.WriteString(plain.EscapeUrl(v, plain.EscapingQueryComponent))
 		}
-		buf.WriteString(plain.EscapeUrl(k, plain.EscapingQueryComponent))
-		buf.WriteByte('=')
-		buf.WriteString(plain.EscapeUrl(v, plain.EscapingQueryComponent))
 	}
 	return buf.String()
 }
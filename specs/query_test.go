@@ -15,22 +15,22 @@ func TestParseQuery(t *testing.T) {
 			name:  "Single key-value pair",
 			query: "key=value",
 			expected: Query{
-				"key": "value",
+				"key": {"value"},
 			},
 		},
 		{
 			name:  "Multiple key-value pairs",
 			query: "key1=value1&key2=value2",
 			expected: Query{
-				"key1": "value1",
-				"key2": "value2",
+				"key1": {"value1"},
+				"key2": {"value2"},
 			},
 		},
 		{
 			name:  "Key with multiple values",
 			query: "key=value1&key=value2",
 			expected: Query{
-				"key": "value1",
+				"key": {"value1", "value2"},
 			},
 		},
 		{
@@ -42,15 +42,15 @@ func TestParseQuery(t *testing.T) {
 			name:  "Key without value",
 			query: "key=",
 			expected: Query{
-				"key": "",
+				"key": {""},
 			},
 		},
 		{
 			name:  "Query with special characters",
 			query: "key=val%20ue&anotherKey=hello%20world",
 			expected: Query{
-				"key":        "val ue",
-				"anotherKey": "hello world",
+				"key":        {"val ue"},
+				"anotherKey": {"hello world"},
 			},
 		},
 		{
@@ -62,23 +62,23 @@ func TestParseQuery(t *testing.T) {
 			name:  "Query with spaces",
 			query: "key=value with spaces",
 			expected: Query{
-				"key": "value with spaces",
+				"key": {"value with spaces"},
 			},
 		},
 		{
 			name:  "Query with encoded special characters",
 			query: "key=hello%2Cworld",
 			expected: Query{
-				"key": "hello,world",
+				"key": {"hello,world"},
 			},
 		},
 		{
 			name:  "Complex query with multiple params",
 			query: "user=alice&age=30&hobbies=reading&hobbies=swimming",
 			expected: Query{
-				"user":    "alice",
-				"age":     "30",
-				"hobbies": "reading",
+				"user":    {"alice"},
+				"age":     {"30"},
+				"hobbies": {"reading", "swimming"},
 			},
 		},
 	}
@@ -102,14 +102,14 @@ func TestQuery_String(t *testing.T) {
 	}{
 		{
 			name:     "Single key-value pair",
-			query:    Query{"key": "value"},
+			query:    Query{"key": {"value"}},
 			expected: "key=value",
 		},
 		{
 			name: "Multiple key-value pairs",
 			query: Query{
-				"key1": "value1",
-				"key2": "value2",
+				"key1": {"value1"},
+				"key2": {"value2"},
 			},
 			expected: "key1=value1&key2=value2",
 		},
@@ -120,30 +120,38 @@ func TestQuery_String(t *testing.T) {
 		},
 		{
 			name:     "Key with empty value",
-			query:    Query{"key": ""},
+			query:    Query{"key": {""}},
 			expected: "key=",
 		},
 		{
 			name: "Query with special characters",
 			query: Query{
-				"key":        "value with spaces",
-				"anotherKey": "hello, world",
+				"key":        {"value with spaces"},
+				"anotherKey": {"hello, world"},
 			},
 			expected: "anotherKey=hello%2C+world&key=value+with+spaces",
 		},
 		{
 			name: "Query with URL-encoded values",
 			query: Query{
-				"key":        "value%20with%20spaces",
-				"anotherKey": "hello%2Cworld",
+				"key":        {"value%20with%20spaces"},
+				"anotherKey": {"hello%2Cworld"},
 			},
 			expected: "anotherKey=hello%252Cworld&key=value%2520with%2520spaces",
 		},
+		{
+			name: "Key with multiple values",
+			query: Query{
+				"tag": {"b", "a"},
+				"id":  {"1"},
+			},
+			expected: "id=1&tag=b&tag=a",
+		},
 		{
 			name: "Query with empty string values",
 			query: Query{
-				"key1": "",
-				"key2": "",
+				"key1": {""},
+				"key2": {""},
 			},
 			expected: "key1=&key2=",
 		},
@@ -157,3 +165,33 @@ func TestQuery_String(t *testing.T) {
 		})
 	}
 }
+
+func TestQuery_Values(t *testing.T) {
+	query := Query{}
+	query.Add("tag", "a")
+	query.Add("tag", "b")
+
+	if value := query.Get("tag"); value != "a" {
+		t.Errorf("expected first value, got %q", value)
+	}
+	if values := query.Values("tag"); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("expected all values, got %v", values)
+	}
+
+	values := query.Values("tag")
+	values[0] = "changed"
+	_ = append(values[:1], "appended")
+	if values := query.Values("tag"); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("expected values not changed by the copy, got %v", values)
+	}
+
+	query.Set("tag", "c")
+	if values := query.Values("tag"); !reflect.DeepEqual(values, []string{"c"}) {
+		t.Errorf("expected replaced values, got %v", values)
+	}
+
+	query.Del("tag")
+	if query.Has("tag") || query.Get("tag") != "" {
+		t.Error("expected deleted key")
+	}
+}
@@ -123,7 +123,7 @@ func TestParseUrl(t *testing.T) {
 		{
 			name: "Only query",
 			raw:  "?key=value",
-			want: &Url{Query: Query{"key": "value"}},
+			want: &Url{Query: Query{"key": {"value"}}},
 		},
 
 		// Combined parts
@@ -230,7 +230,7 @@ func TestParseUrl(t *testing.T) {
 			want: &Url{
 				Path: "/user",
 				Query: Query{
-					"id": "120",
+					"id": {"120"},
 				},
 			},
 		},
@@ -329,8 +329,8 @@ func TestParseUrl(t *testing.T) {
 				Scheme: "https",
 				Host:   "example.com",
 				Query: Query{
-					"q":    "test",
-					"lang": "en",
+					"q":    {"test"},
+					"lang": {"en"},
 				},
 			},
 		},
@@ -343,8 +343,8 @@ func TestParseUrl(t *testing.T) {
 				Port:   8080,
 				Path:   "/search",
 				Query: Query{
-					"q":    "test",
-					"lang": "en",
+					"q":    {"test"},
+					"lang": {"en"},
 				},
 			},
 		},
@@ -355,7 +355,7 @@ func TestParseUrl(t *testing.T) {
 				Scheme: "https",
 				Host:   "example.com",
 				Path:   "/search",
-				Query:  Query{"q": "hello world"},
+				Query:  Query{"q": {"hello world"}},
 			},
 		},
 		{
@@ -365,7 +365,7 @@ func TestParseUrl(t *testing.T) {
 				Scheme: "http",
 				Host:   "[2001:db8::1]",
 				Path:   "/file/a b",
-				Query:  Query{"query": "value"},
+				Query:  Query{"query": {"value"}},
 			},
 		},
 		{
@@ -389,7 +389,7 @@ func TestParseUrl(t *testing.T) {
 				Port:     8443,
 				Path:     "/api/v1",
 				Fragment: "anchor",
-				Query:    Query{"key": "value"},
+				Query:    Query{"key": {"value"}},
 			},
 		},
 	}
@@ -522,7 +522,7 @@ func TestUrl_String(t *testing.T) {
 			url: &Url{
 				Scheme: "http",
 				Host:   "example.com",
-				Query:  Query{"a b": "c=d&"},
+				Query:  Query{"a b": {"c=d&"}},
 			},
 			want: "http://example.com/?a+b=c%3Dd%26",
 		},
@@ -590,7 +590,7 @@ func TestUrl_String(t *testing.T) {
 				Password: "pass",
 				Host:     "example.com",
 				Path:     "/search results",
-				Query:    Query{"q": "golang & rust", "lang": "en"},
+				Query:    Query{"q": {"golang & rust"}, "lang": {"en"}},
 				Fragment: "section 1",
 				Port:     8443,
 			},
@@ -611,7 +611,7 @@ func TestUrl_String(t *testing.T) {
 			url: &Url{
 				Scheme: "https",
 				Host:   "example.com",
-				Query:  Query{"": ""},
+				Query:  Query{"": {""}},
 			},
 			want: "https://example.com/?=",
 		},
@@ -0,0 +1,58 @@
+package sse
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single message of the event stream.
+type Event struct {
+	// ID sets the last event ID of the stream,
+	// which is sent by the client in "Last-Event-ID" header on reconnection.
+	ID string
+
+	// Event is the type of the event, empty value means "message" type.
+	Event string
+
+	// Data is the payload of the event, multiline data is sent as multiple "data" fields.
+	Data string
+
+	// Retry is the reconnection time the client should use, zero value is not sent.
+	Retry time.Duration
+}
+
+// WriteTo writes the event in the event stream format, implements [io.WriterTo].
+func (event *Event) WriteTo(writer io.Writer) (int64, error) {
+	var buf strings.Builder
+	if event.ID != "" {
+		writeField(&buf, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeField(&buf, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeField(&buf, "retry", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+	// Data is always sent, so the client dispatches the event even if data is empty,
+	// trailing line break is kept as empty "data" field
+	data := strings.ReplaceAll(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\r", "\n")
+	for line := range strings.SplitSeq(data, "\n") {
+		writeField(&buf, "data", line)
+	}
+	buf.WriteByte('\n')
+
+	n, err := io.WriteString(writer, buf.String())
+	return int64(n), err
+}
+
+// Line breaks would split the field, so they are dropped from values
+var newlineReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+func writeField(buf *strings.Builder, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(newlineReplacer.Replace(value))
+	buf.WriteByte('\n')
+}
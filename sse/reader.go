@@ -0,0 +1,106 @@
+package sse
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oesand/plow/internal/stream"
+)
+
+// DefaultMaxLineLength is the maximum length of a single line of the event stream.
+const DefaultMaxLineLength = 64 << 10
+
+// NewReader creates a [Reader] of the event stream,
+// usually the reader is [plow.ClientResponse.Body].
+func NewReader(reader io.Reader) *Reader {
+	if reader == nil {
+		panic("plow: passed nil reader")
+	}
+	return &Reader{
+		reader:        bufio.NewReader(reader),
+		MaxLineLength: DefaultMaxLineLength,
+	}
+}
+
+// Reader parses the event stream by the rules of the HTML Living Standard,
+// it tracks the last event ID and the reconnection time sent by the server.
+type Reader struct {
+	reader *bufio.Reader
+
+	// MaxLineLength is the maximum length of a single line,
+	// [specs.ErrTooLarge] is returned for longer lines.
+	// If zero there is no limit.
+	MaxLineLength int64
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// LastEventID returns ID of the last received event,
+// which is sent by the client in "Last-Event-ID" header on reconnection.
+func (reader *Reader) LastEventID() string {
+	return reader.lastEventID
+}
+
+// Retry returns the last reconnection time sent by the server, zero if none.
+func (reader *Reader) Retry() time.Duration {
+	return reader.retry
+}
+
+// Next reads the next event of the stream, it returns [io.EOF]
+// when the stream ends, the incomplete last event is discarded.
+func (reader *Reader) Next() (Event, error) {
+	var event Event
+	var data strings.Builder
+	var hasData bool
+	for {
+		line, err := stream.ReadBufferLine(reader.reader, reader.MaxLineLength)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return Event{}, err
+		}
+
+		if len(line) == 0 {
+			// Event without data is not dispatched
+			if !hasData {
+				event = Event{}
+				continue
+			}
+			event.ID = reader.lastEventID
+			event.Data = data.String()
+			return event, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		name, value, _ := strings.Cut(string(line), ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch name {
+		case "event":
+			event.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				reader.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				reader.retry = time.Duration(ms) * time.Millisecond
+				event.Retry = reader.retry
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow/specs"
+)
+
+func TestReader_Next(t *testing.T) {
+	reader := NewReader(strings.NewReader(
+		": comment\n" +
+			"retry: 1500\n" +
+			"\n" +
+			"id: 1\n" +
+			"event: progress\n" +
+			"data: first\n" +
+			"data:second\n" +
+			"\n" +
+			"data: no id\r\n" +
+			"\r\n" +
+			"id: 3\n" +
+			"data: incomplete",
+	))
+
+	event, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Event{ID: "1", Event: "progress", Data: "first\nsecond"}
+	if event != expected {
+		t.Errorf("expected event %+v, got %+v", expected, event)
+	}
+	if reader.Retry() != 1500*time.Millisecond {
+		t.Errorf("expected retry 1.5s, got %s", reader.Retry())
+	}
+
+	event, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = Event{ID: "1", Data: "no id"}
+	if event != expected {
+		t.Errorf("expected event %+v, got %+v", expected, event)
+	}
+
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if reader.LastEventID() != "3" {
+		t.Errorf("expected last event id 3, got %q", reader.LastEventID())
+	}
+}
+
+func TestReader_TooLongLine(t *testing.T) {
+	reader := NewReader(strings.NewReader("data: " + strings.Repeat("x", 100) + "\n\n"))
+	reader.MaxLineLength = 50
+
+	if _, err := reader.Next(); !errors.Is(err, specs.ErrTooLarge) {
+		t.Errorf("expected too large error, got %v", err)
+	}
+}
+
+func TestEvent_WriteTo(t *testing.T) {
+	var buf bytes.Buffer
+	event := Event{ID: "7", Event: "update", Data: "line1\nline2", Retry: 2 * time.Second}
+	if _, err := event.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id: 7\nevent: update\nretry: 2000\ndata: line1\ndata: line2\n\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	parsed, err := NewReader(&buf).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != event {
+		t.Errorf("expected parsed event %+v, got %+v", event, parsed)
+	}
+}
+
+func TestEvent_WriteToEmptyData(t *testing.T) {
+	tests := []struct {
+		event    Event
+		expected string
+	}{
+		{Event{ID: "1"}, "id: 1\ndata: \n\n"},
+		{Event{Retry: time.Second}, "retry: 1000\ndata: \n\n"},
+		{Event{Data: "line\n"}, "data: line\ndata: \n\n"},
+		{Event{Data: "a\r\nb"}, "data: a\ndata: b\n\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if _, err := tt.event.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, buf.String())
+		}
+
+		parsed, err := NewReader(&buf).Next()
+		if err != nil {
+			t.Fatalf("expected event %q to be dispatched: %v", tt.expected, err)
+		}
+		if want := strings.ReplaceAll(tt.event.Data, "\r\n", "\n"); parsed.Data != want {
+			t.Errorf("expected parsed data %q, got %q", want, parsed.Data)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sse
+
+import (
+	"context"
+	"io"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+)
+
+// Response is implementation for the [plow.Response] and [plow.BodyWriter]
+// that streams events to the client with chunked transfer.
+//
+// Events are sent as they are received from the channel and flushed
+// one by one, the stream ends when the channel is closed or ctx is done,
+// usually ctx is the context of the handler.
+//
+// Long-lived streams are interrupted by [plow.Server.WriteTimeout] if it is set.
+func Response(ctx context.Context, events <-chan Event, configure ...func(plow.Response)) plow.Response {
+	if ctx == nil {
+		panic("plow: nil context pointer")
+	}
+	if events == nil {
+		panic("plow: passed nil events channel")
+	}
+
+	resp := &eventStreamResponse{
+		Response: plow.EmptyResponse(specs.StatusCodeOK, configure...),
+		ctx:      ctx,
+		events:   events,
+	}
+
+	resp.Header().Set("Content-Type", specs.ContentTypeEventStream)
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Transfer-Encoding", "chunked")
+
+	return resp
+}
+
+type eventStreamResponse struct {
+	plow.Response
+	ctx    context.Context
+	events <-chan Event
+}
+
+func (resp *eventStreamResponse) WriteBody(writer io.Writer) error {
+	flusher, _ := writer.(plow.Flusher)
+	for {
+		select {
+		case <-resp.ctx.Done():
+			return nil
+		case event, ok := <-resp.events:
+			if !ok {
+				return nil
+			}
+			if _, err := event.WriteTo(writer); err != nil {
+				return err
+			}
+			if flusher != nil {
+				if err := flusher.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (resp *eventStreamResponse) ContentLength() int64 {
+	return -1
+}
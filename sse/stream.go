@@ -0,0 +1,211 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+)
+
+// DefaultRetry is the reconnection time used until the server sends one.
+const DefaultRetry = 3 * time.Second
+
+// ErrStopped is returned when the server asks the client
+// to stop reconnecting with [specs.StatusCodeNoContent].
+var ErrStopped = specs.NewOpError("sse", "stream is stopped by the server")
+
+// Connect opens the event stream of the url using the provided client and context.
+//
+// Configure is applied to every request of the stream, including reconnections.
+func Connect(ctx context.Context, client *plow.Client, url *specs.Url, configure ...func(plow.ClientRequest)) (*Stream, error) {
+	if ctx == nil {
+		panic("plow: nil Context pointer")
+	}
+	if client == nil {
+		panic("plow: nil Client pointer")
+	}
+	if url == nil {
+		panic("plow: passed nil url")
+	}
+
+	stream := &Stream{
+		ctx:       ctx,
+		client:    client,
+		url:       url,
+		configure: configure,
+		retry:     DefaultRetry,
+	}
+	if err := stream.open(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Stream is the client of the event stream, which reconnects
+// when the connection is lost or the stream ends and resumes it
+// from the last received event with "Last-Event-ID" header.
+type Stream struct {
+	ctx       context.Context
+	client    *plow.Client
+	url       *specs.Url
+	configure []func(plow.ClientRequest)
+
+	mu          sync.Mutex
+	body        io.ReadCloser
+	reader      *Reader
+	lastEventID string
+
+	retry  time.Duration
+	closed atomic.Bool
+}
+
+// LastEventID returns ID of the last received event.
+func (stream *Stream) LastEventID() string {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return stream.lastEventID
+}
+
+// Next reads the next event of the stream, reconnecting if necessary.
+//
+// It returns an error when ctx is done, the stream is closed,
+// or reconnection is refused by the server with unexpected response.
+func (stream *Stream) Next() (Event, error) {
+	for {
+		if stream.reader == nil {
+			if err := stream.reconnect(); err != nil {
+				return Event{}, err
+			}
+		}
+
+		event, err := stream.reader.Next()
+		if err == nil {
+			stream.mu.Lock()
+			stream.lastEventID = stream.reader.LastEventID()
+			stream.mu.Unlock()
+			return event, nil
+		}
+
+		if stream.reader.Retry() > 0 {
+			stream.retry = stream.reader.Retry()
+		}
+		stream.mu.Lock()
+		stream.lastEventID = stream.reader.LastEventID()
+		stream.body.Close()
+		stream.body, stream.reader = nil, nil
+		stream.mu.Unlock()
+
+		if stream.closed.Load() {
+			return Event{}, specs.ErrClosed
+		}
+		if errors.Is(err, specs.ErrTooLarge) {
+			return Event{}, err
+		}
+	}
+}
+
+// Close closes the stream, the blocked [Stream.Next] returns [specs.ErrClosed].
+func (stream *Stream) Close() error {
+	if stream.closed.Swap(true) {
+		return nil
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.body != nil {
+		return stream.body.Close()
+	}
+	return nil
+}
+
+// reconnect opens the stream after the reconnection time,
+// retrying while the connection cannot be established.
+func (stream *Stream) reconnect() error {
+	for {
+		timer := time.NewTimer(stream.retry)
+		select {
+		case <-stream.ctx.Done():
+			timer.Stop()
+			return stream.ctx.Err()
+		case <-timer.C:
+		}
+
+		if stream.closed.Load() {
+			return specs.ErrClosed
+		}
+
+		err := stream.open()
+		if err == nil {
+			return nil
+		}
+
+		var refused *refusedError
+		if errors.As(err, &refused) || errors.Is(err, specs.ErrClosed) || stream.ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// refusedError is returned when the server responded,
+// but the response is not the event stream.
+type refusedError struct {
+	err error
+}
+
+func (err *refusedError) Error() string {
+	return err.err.Error()
+}
+
+func (err *refusedError) Unwrap() error {
+	return err.err
+}
+
+func (stream *Stream) open() error {
+	req := plow.EmptyRequest(specs.HttpMethodGet, stream.url)
+	for _, conf := range stream.configure {
+		conf(req)
+	}
+
+	req.Header().Set("Accept", specs.ContentTypeEventStream)
+	req.Header().Set("Cache-Control", "no-cache")
+	if stream.lastEventID != "" {
+		req.Header().Set("Last-Event-ID", stream.lastEventID)
+	}
+
+	resp, err := stream.client.MakeContext(stream.ctx, req)
+	if err != nil {
+		return err
+	}
+
+	body := resp.Body()
+	code := resp.StatusCode()
+	if code != specs.StatusCodeOK || body == nil ||
+		!specs.MatchContentType(resp.Header(), specs.ContentTypeEventStream) {
+		if body != nil {
+			body.Close()
+		}
+		if code == specs.StatusCodeNoContent {
+			return &refusedError{ErrStopped}
+		} else if code != specs.StatusCodeOK {
+			return &refusedError{specs.NewOpError("sse", "invalid status code %d", code)}
+		}
+		return &refusedError{specs.NewOpError("sse", "response is not an event stream")}
+	}
+
+	reader := NewReader(body)
+	reader.lastEventID = stream.lastEventID
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed.Load() {
+		body.Close()
+		return specs.ErrClosed
+	}
+	stream.body, stream.reader = body, reader
+	return nil
+}
@@ -0,0 +1,195 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oesand/plow"
+	"github.com/oesand/plow/specs"
+)
+
+func serveTest(t *testing.T, handler plow.HandlerFunc) (*specs.Url, *plow.Client) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := plow.DefaultServer(handler)
+	go server.Serve(listener)
+	t.Cleanup(server.Shutdown)
+
+	transport := plow.DefaultTransport()
+	t.Cleanup(transport.CloseIdleConnections)
+
+	url := specs.MustParseUrl("http://" + listener.Addr().String() + "/events")
+	return url, &plow.Client{Transport: transport}
+}
+
+func TestStream_Reconnect(t *testing.T) {
+	var connects atomic.Int32
+	url, client := serveTest(t, func(ctx context.Context, request plow.Request) plow.Response {
+		if request.Header().Get("Accept") != specs.ContentTypeEventStream {
+			t.Errorf("unexpected accept header %q", request.Header().Get("Accept"))
+		}
+
+		events := make(chan Event, 2)
+		switch connects.Add(1) {
+		case 1:
+			if request.Header().Has("Last-Event-ID") {
+				t.Error("unexpected Last-Event-ID on first connection")
+			}
+			events <- Event{ID: "1", Data: "first", Retry: 10 * time.Millisecond}
+			events <- Event{ID: "2", Event: "progress", Data: "second"}
+		default:
+			if id := request.Header().Get("Last-Event-ID"); id != "2" {
+				t.Errorf("expected Last-Event-ID 2, got %q", id)
+			}
+			events <- Event{ID: "3", Data: "third"}
+		}
+		close(events)
+		return Response(ctx, events)
+	})
+
+	stream, err := Connect(context.Background(), client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// LastEventID is safe to call concurrently with Next
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				stream.LastEventID()
+			}
+		}
+	}()
+
+	for _, expected := range []Event{
+		{ID: "1", Data: "first", Retry: 10 * time.Millisecond},
+		{ID: "2", Event: "progress", Data: "second"},
+		{ID: "3", Data: "third"},
+	} {
+		event, err := stream.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event != expected {
+			t.Errorf("expected event %+v, got %+v", expected, event)
+		}
+	}
+
+	if stream.LastEventID() != "3" {
+		t.Errorf("expected last event id 3, got %q", stream.LastEventID())
+	}
+	if connects.Load() != 2 {
+		t.Errorf("expected 2 connections, got %d", connects.Load())
+	}
+}
+
+func TestStream_StoppedByServer(t *testing.T) {
+	var connects atomic.Int32
+	url, client := serveTest(t, func(ctx context.Context, request plow.Request) plow.Response {
+		if connects.Add(1) > 1 {
+			return plow.EmptyResponse(specs.StatusCodeNoContent)
+		}
+		events := make(chan Event, 1)
+		events <- Event{Data: "only", Retry: time.Millisecond}
+		close(events)
+		return Response(ctx, events)
+	})
+
+	stream, err := Connect(context.Background(), client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if event, err := stream.Next(); err != nil || event.Data != "only" {
+		t.Fatalf("unexpected event %+v, err %v", event, err)
+	}
+	if _, err = stream.Next(); !errors.Is(err, ErrStopped) {
+		t.Errorf("expected stopped error, got %v", err)
+	}
+}
+
+func TestStream_NotEventStream(t *testing.T) {
+	url, client := serveTest(t, func(ctx context.Context, request plow.Request) plow.Response {
+		return plow.TextResponse(specs.StatusCodeOK, specs.ContentTypePlain, "hello")
+	})
+
+	if _, err := Connect(context.Background(), client, url); err == nil {
+		t.Error("expected error for plain text response")
+	}
+}
+
+func TestResponse_StopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := Response(ctx, make(chan Event))
+
+	if resp.Header().Get("Content-Type") != specs.ContentTypeEventStream ||
+		resp.Header().Get("Transfer-Encoding") != "chunked" {
+		t.Errorf("unexpected headers %+v", resp.Header())
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- resp.(plow.BodyWriter).WriteBody(nil)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("response is not stopped by context")
+	}
+}
+
+func TestStream_EventsAreFlushed(t *testing.T) {
+	received := make(chan struct{})
+	url, client := serveTest(t, func(ctx context.Context, request plow.Request) plow.Response {
+		events := make(chan Event)
+		go func() {
+			defer close(events)
+			events <- Event{Data: "first"}
+			<-received
+			events <- Event{Data: "second"}
+		}()
+		return Response(ctx, events)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := Connect(ctx, client, url, func(req plow.ClientRequest) {
+		req.Header().Set("Accept-Encoding", specs.ContentEncodingGzip)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, expected := range []string{"first", "second"} {
+		event, err := stream.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Data != expected {
+			t.Errorf("expected data %q, got %q", expected, event.Data)
+		}
+		if expected == "first" {
+			close(received)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/oesand/plow/internal/catch"
 	"github.com/oesand/plow/internal/client_ops"
 	"github.com/oesand/plow/internal/encoding"
+	"github.com/oesand/plow/internal/h2"
 	"github.com/oesand/plow/internal/parsing"
 	"github.com/oesand/plow/internal/proxy"
 	"github.com/oesand/plow/internal/stream"
@@ -17,9 +18,13 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+const transportMaxDrainSize int64 = 64 << 10 // 64 kb
+
 // DefaultTransport factory for creating [Transport]
 // with optimal parameters for perfomance and safety
 //
@@ -32,6 +37,9 @@ func DefaultTransport() *Transport {
 		WriteTimeout:        10 * time.Second,
 		ProxyDialTimeout:    10 * time.Second,
 		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
 	}
 }
 
@@ -61,6 +69,7 @@ type Transport struct {
 	// in a `Proxy-Authorization` header.
 	//
 	// If Proxy is nil or returns a nil *specs.Url, no proxy is used.
+	// Proxy is not called if Dialer is created by [UnixSocketDialer].
 	Proxy func(url *specs.Url) (*specs.Url, error)
 
 	// ProxyDialTimeout specifies the maximum amount of time to
@@ -82,7 +91,8 @@ type Transport struct {
 	// to use with tls.Client.
 	//
 	// If nil, the default configuration is used.
-	// If non-nil, HTTP/2 support may not be enabled by default.
+	// If NextProtos of the configuration is set,
+	// it is used as is and HTTP/2 is negotiated only if listed.
 	TLSConfig *tls.Config
 
 	// TLSHandshakeTimeout specifies the maximum amount of time to
@@ -126,6 +136,52 @@ type Transport struct {
 	//
 	// By default, response body size is unlimited.
 	MaxBodySize int64
+
+	// DisableKeepAlive, if true, disables HTTP keep-alive and
+	// will only use the connection to the server for a single request.
+	//
+	// By default, connections are kept in the idle pool after
+	// the response body is fully read and closed,
+	// unless client or server sends 'Connection: close' header.
+	DisableKeepAlive bool
+
+	// MaxIdleConns controls the maximum number of idle (keep-alive)
+	// connections across all hosts. The oldest idle connection
+	// is closed when the limit is reached.
+	//
+	// If zero there is no limit
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost controls the maximum number of idle (keep-alive)
+	// connections to keep per scheme, host, port and proxy.
+	//
+	// If zero, [DefaultMaxIdleConnsPerHost] is used.
+	// If negative, idle connections are not kept.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is the maximum amount of time an idle
+	// (keep-alive) connection will remain idle before closing itself.
+	//
+	// If zero there is no timeout
+	IdleConnTimeout time.Duration
+
+	// DisableHTTP2, if true, disables negotiation of HTTP/2
+	// over TLS connections with ALPN.
+	//
+	// By default, HTTP/2 is used for HTTPS requests when the server supports it,
+	// except requests with [TransportHijacker] or when DisableKeepAlive is set.
+	// A single HTTP/2 connection is shared by concurrent requests to the same host.
+	DisableHTTP2 bool
+
+	// EnableH2C, if true, enables cleartext HTTP/2 (h2c) with prior knowledge
+	// for plain HTTP requests, the server must support HTTP/2 without upgrade.
+	//
+	// It is not used for requests via plain http proxy
+	// and with the same restrictions as HTTP/2 over TLS.
+	EnableH2C bool
+
+	pool   client_ops.ConnPool
+	h2pool h2ConnPool
 }
 
 // RoundTrip implements the [RoundTripper] interface.
@@ -157,7 +213,7 @@ func (transport *Transport) RoundTrip(ctx context.Context, method specs.HttpMeth
 
 	var err error
 	var proxyUrl *specs.Url
-	if transport.Proxy != nil {
+	if _, unix := transport.Dialer.(unixSocketDialer); !unix && transport.Proxy != nil {
 		proxyUrl, err = transport.Proxy(url)
 		if err != nil {
 			return nil, err
@@ -208,7 +264,7 @@ func (transport *Transport) RoundTrip(ctx context.Context, method specs.HttpMeth
 		header.Set("Authorization", specs.BasicAuthHeader(url.Username, url.Password))
 	}
 
-	if !header.Has("Connection") {
+	if transport.DisableKeepAlive && !header.Has("Connection") {
 		header.Set("Connection", "close")
 	}
 
@@ -231,7 +287,62 @@ func (transport *Transport) RoundTrip(ctx context.Context, method specs.HttpMeth
 		}
 	}
 
+	key := client_ops.ConnKey{Scheme: url.Scheme, Host: host, Port: url.Port}
+	if proxyUrl != nil {
+		key.Proxy = proxyUrl.String()
+	}
+
+	allowH2 := transport.isHTTP2Allowed(ctx, key, proxyUrl)
+	if allowH2 {
+		if cc := transport.h2pool.Get(key); cc != nil {
+			resp, err := transport.roundTripH2(ctx, cc, method, url, header, writer, mustWriteBody)
+			if err == nil || !errors.Is(err, h2.ErrRetryable) {
+				return resp, err
+			}
+			// Connection is going away, try again with a new one
+		}
+	}
+
+	if !transport.DisableKeepAlive {
+		if pc := transport.pool.Get(key); pc != nil {
+			resp, retryable, err := transport.exchange(ctx, pc, method, url, header, writer, isChunked, mustWriteBody)
+			if err == nil || !retryable {
+				return resp, err
+			}
+			// Idle connection was closed by the server, try again with a new one
+		}
+	}
+
+	pc, err := transport.connect(ctx, key, proxyUrl, host, url.Port, allowH2)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowH2 && (key.Scheme == "http" || isNegotiatedH2(pc.Conn)) {
+		cc, err := transport.newH2Conn(pc)
+		if err != nil {
+			return nil, err
+		}
+		transport.h2pool.Add(key, cc)
+		return transport.roundTripH2(ctx, cc, method, url, header, writer, mustWriteBody)
+	}
+
+	resp, _, err := transport.exchange(ctx, pc, method, url, header, writer, isChunked, mustWriteBody)
+	return resp, err
+}
+
+// CloseIdleConnections closes any connections which were previously
+// connected from previous requests but are now sitting idle in
+// a "keep-alive" state. It does not interrupt any connections currently
+// in use.
+func (transport *Transport) CloseIdleConnections() {
+	transport.pool.CloseIdle()
+	transport.h2pool.CloseIdle()
+}
+
+func (transport *Transport) connect(ctx context.Context, key client_ops.ConnKey, proxyUrl *specs.Url, host string, port uint16, allowH2 bool) (*client_ops.PersistConn, error) {
 	var conn net.Conn
+	var err error
 	if proxyUrl != nil {
 		conn, err = transport.dial(ctx, proxyUrl.Host, proxyUrl.Port)
 		if err != nil {
@@ -243,32 +354,51 @@ func (transport *Transport) RoundTrip(ctx context.Context, method specs.HttpMeth
 			proxyCreds = &proxy.Creds{Username: proxyUrl.Username, Password: proxyUrl.Password}
 		}
 
-		err = transport.dialProxy(ctx, conn, proxyUrl.Scheme, host, url.Port, proxyCreds)
+		err = transport.dialProxy(ctx, conn, proxyUrl.Scheme, host, port, proxyCreds)
 		if err != nil {
 			conn.Close()
 			return nil, catch.TryWrapOpErr("proxy", err)
 		}
 	} else {
-		conn, err = transport.dial(ctx, host, url.Port)
+		conn, err = transport.dial(ctx, host, port)
 		if err != nil {
 			return nil, catch.TryWrapOpErr("dial", err)
 		}
 	}
 
-	closeConn, includeClose, cancelCloseConn := internal.CancellableDefer(func() {
-		conn.Close()
-	})
-	defer closeConn()
-
-	if url.Scheme == "https" {
+	if key.Scheme == "https" {
 		var tlsConn net.Conn
-		tlsConn, err = transport.dialTls(ctx, conn, host)
+		tlsConn, err = transport.dialTls(ctx, conn, host, allowH2)
 		if err != nil {
+			conn.Close()
 			return nil, catch.TryWrapOpErr("tls", err)
 		}
 		conn = tlsConn
 	}
 
+	return &client_ops.PersistConn{
+		Key:    key,
+		Conn:   conn,
+		Reader: stream.DefaultBufioReaderPool.Get(conn),
+	}, nil
+}
+
+// exchange writes request and reads response over the connection.
+//
+// Returns retryable flag when the connection turned out to be broken
+// before the request could have been processed by the server.
+func (transport *Transport) exchange(
+	ctx context.Context, pc *client_ops.PersistConn,
+	method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter,
+	isChunked, mustWriteBody bool,
+) (ClientResponse, bool, error) {
+	conn := pc.Conn
+	closeConn, _, cancelCloseConn := internal.CancellableDefer(func() {
+		pc.Close()
+	})
+	defer closeConn()
+
+	var err error
 	if transport.WriteTimeout > 0 {
 		conn.SetWriteDeadline(time.Now().Add(transport.WriteTimeout))
 		defer conn.SetWriteDeadline(time.Time{})
@@ -280,7 +410,7 @@ func (transport *Transport) RoundTrip(ctx context.Context, method specs.HttpMeth
 		err = ctx.Err()
 	}
 	if err = catch.CatchCommonErr(err); err != nil {
-		return nil, catch.TryWrapOpErr("write", err)
+		return nil, isBrokenConnErr(err), catch.TryWrapOpErr("write", err)
 	}
 
 	// Expect 100 Continue support
@@ -303,7 +433,7 @@ writeBody:
 			err = ctx.Err()
 		}
 		if err != nil {
-			return nil, catch.CatchCommonErr(err)
+			return nil, false, catch.CatchCommonErr(err)
 		}
 	}
 
@@ -312,18 +442,13 @@ reading:
 		conn.SetReadDeadline(time.Now().Add(transport.ReadTimeout))
 	}
 
-	bufioReader := stream.DefaultBufioReaderPool.Get(conn)
-	includeClose(func() {
-		stream.DefaultBufioReaderPool.Put(bufioReader)
-	})
-
-	resp, err := client_ops.ReadResponse(ctx, bufioReader, transport.ReadLineMaxLength, transport.HeadMaxLength)
+	resp, err := client_ops.ReadResponse(ctx, pc.Reader, transport.ReadLineMaxLength, transport.HeadMaxLength)
 
 	if err == nil {
 		err = ctx.Err()
 	}
 	if err != nil {
-		return nil, catch.CatchCommonErr(err)
+		return nil, !mustWriteBody && isBrokenConnErr(err), catch.CatchCommonErr(err)
 	}
 
 	if expectContinue && resp.StatusCode() == specs.StatusCodeContinue {
@@ -333,15 +458,47 @@ reading:
 
 	hijacker, hasHijacker := ctx.Value(transportHijackerKey).(*TransportHijacker)
 
+	keepAlive := !transport.DisableKeepAlive && !hasHijacker &&
+		!strings.EqualFold(header.Get("Connection"), "close") &&
+		isResponseKeepAlive(resp)
+
+	// release returns connection to the pool after the response is fully read
+	release := func() {
+		if keepAlive {
+			conn.SetDeadline(time.Time{})
+			maxIdlePerHost := transport.MaxIdleConnsPerHost
+			if maxIdlePerHost == 0 {
+				maxIdlePerHost = DefaultMaxIdleConnsPerHost
+			}
+			if transport.pool.Put(pc, transport.MaxIdleConns, maxIdlePerHost, transport.IdleConnTimeout) {
+				return
+			}
+		}
+		pc.Close()
+	}
+
 	if !method.IsReplyable() || !resp.StatusCode().IsReplyable() {
 		if hasHijacker && !strings.EqualFold(header.Get("Connection"), "close") {
 			hijacker.Conn = conn
 			cancelCloseConn()
+		} else if keepAlive && method != specs.HttpMethodConnect && resp.StatusCode() >= 200 {
+			code := resp.StatusCode()
+			if method == specs.HttpMethodHead || code == specs.StatusCodeNoContent || code == specs.StatusCodeNotModified {
+				cancelCloseConn()
+				release()
+			} else if bodyChunked, contentLength, err := parsing.ParseContentLength(resp.Header()); err == nil &&
+				(bodyChunked || contentLength >= 0 && resp.Header().Has("Content-Length")) {
+				// Skip unread body, such as redirect page
+				if client_ops.NewBodyReader(pc.Reader, bodyChunked, contentLength).Drain(transportMaxDrainSize) {
+					cancelCloseConn()
+					release()
+				}
+			}
 		}
 	} else {
 		contentEncoding := resp.Header().Get("Content-Encoding")
 		if contentEncoding != "" && !encoding.IsKnownEncoding(contentEncoding) {
-			return nil, specs.ErrUnknownContentEncoding
+			return nil, false, specs.ErrUnknownContentEncoding
 		}
 
 		var contentLength int64
@@ -349,44 +506,51 @@ reading:
 		if err != nil {
 			if errors.Is(err, parsing.ErrParsing) {
 				// Fail to parse Content-Length
-				stream.DefaultBufioReaderPool.Put(bufioReader)
 				if hasHijacker {
-					return nil, errors.New("cannot parse Content-Length value")
+					return nil, false, errors.New("cannot parse Content-Length value")
 				}
 			} else {
-				return nil, err
+				return nil, false, err
 			}
 		} else if isChunked || contentLength > 0 {
-			if maxSize := transport.MaxBodySize; maxSize > 0 {
-				if isChunked {
-					contentLength = maxSize
-				} else if contentLength > maxSize {
-					return nil, specs.ErrTooLarge
-				}
+			if maxSize := transport.MaxBodySize; maxSize > 0 && !isChunked && contentLength > maxSize {
+				return nil, false, specs.ErrTooLarge
 			}
 
-			encodingReader, err := encoding.NewReader(isChunked, contentEncoding, bufioReader)
+			bodyReader := client_ops.NewBodyReader(pc.Reader, isChunked, contentLength)
+			decodingReader, err := encoding.NewDecodingReader(contentEncoding, bodyReader)
 			if err != nil {
-				return nil, err
+				return nil, false, err
 			}
 
-			var bodyReader io.Reader = encodingReader
-
-			if contentLength > 0 {
-				bodyReader = io.LimitReader(bodyReader, contentLength)
+			var reader io.Reader = decodingReader
+			if maxSize := transport.MaxBodySize; maxSize > 0 && isChunked {
+				reader = io.LimitReader(reader, maxSize)
 			}
 
+			var closed atomic.Bool
 			cancelCloseConn()
-			resp.Reader = internal.ReadCloser(bodyReader, internal.CloserFunc(func() error {
-				stream.DefaultBufioReaderPool.Put(bufioReader)
+			resp.Reader = internal.ReadCloser(reader, internal.CloserFunc(func() error {
+				if closed.Swap(true) {
+					return nil
+				}
 
-				err := encodingReader.Close()
-				err1 := conn.Close()
+				err := decodingReader.Close()
+				if err == nil && !hasHijacker && bodyReader.Drain(transportMaxDrainSize) {
+					release()
+					return nil
+				}
+
+				err1 := pc.Close()
 				if err != nil {
 					return err
 				}
 				return err1
 			}))
+		} else if keepAlive && resp.Header().Has("Content-Length") {
+			// Empty body with known length
+			cancelCloseConn()
+			release()
 		}
 
 		if hasHijacker {
@@ -395,7 +559,20 @@ reading:
 		}
 	}
 
-	return resp, nil
+	return resp, false, nil
+}
+
+func isResponseKeepAlive(resp *client_ops.HttpClientResponse) bool {
+	connHeader := resp.Header().Get("Connection")
+	if major, minor := resp.ProtoVersion(); major == 1 && minor == 0 {
+		return strings.EqualFold(connHeader, "keep-alive")
+	}
+	return !strings.EqualFold(connHeader, "close")
+}
+
+func isBrokenConnErr(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
 }
 
 func (transport *Transport) dial(ctx context.Context, host string, port uint16) (net.Conn, error) {
@@ -412,6 +589,31 @@ func (transport *Transport) dial(ctx context.Context, host string, port uint16)
 	return conn, catch.CatchCommonErr(err)
 }
 
+// UnixSocketDialer returns a [Dialer] which connects to the Unix domain socket
+// at the path regardless of the requested address, such as local API of the Docker daemon:
+//
+//	transport := plow.DefaultTransport()
+//	transport.Dialer = plow.UnixSocketDialer("/var/run/docker.sock")
+//	client := plow.Client{Transport: transport}
+//	resp, err := client.Make(plow.EmptyRequest(specs.HttpMethodGet, specs.MustParseUrl("http://docker/version")))
+//
+// The "Host" header of requests is taken from the request url as usual.
+// [Transport.Proxy] is ignored by the transport with this dialer,
+// since every connection goes to the socket.
+func UnixSocketDialer(path string) Dialer {
+	if path == "" {
+		panic("plow: empty unix socket path")
+	}
+	return unixSocketDialer(path)
+}
+
+type unixSocketDialer string
+
+// Dial implements the [Dialer] interface.
+func (path unixSocketDialer) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	return defaultDialer.DialContext(ctx, "unix", string(path))
+}
+
 func (transport *Transport) dialProxy(ctx context.Context, conn net.Conn, scheme, host string, port uint16, creds *proxy.Creds) error {
 	if scheme == "http" {
 		return nil
@@ -434,7 +636,7 @@ func (transport *Transport) dialProxy(ctx context.Context, conn net.Conn, scheme
 	})
 }
 
-func (transport *Transport) dialTls(ctx context.Context, conn net.Conn, host string) (net.Conn, error) {
+func (transport *Transport) dialTls(ctx context.Context, conn net.Conn, host string, allowH2 bool) (net.Conn, error) {
 	return catch.CallWithTimeoutContext(ctx, transport.TLSHandshakeTimeout, func(ctx context.Context) (net.Conn, error) {
 		if transport.TLSDialer != nil {
 			return transport.TLSDialer.Handshake(ctx, conn, host)
@@ -451,6 +653,10 @@ func (transport *Transport) dialTls(ctx context.Context, conn net.Conn, host str
 				tlsCfg.ServerName = host
 			}
 
+			if allowH2 && len(tlsCfg.NextProtos) == 0 {
+				tlsCfg.NextProtos = []string{h2.NextProtoTLS, "http/1.1"}
+			}
+
 			tlsConn := tls.Client(conn, tlsCfg)
 			err := tlsConn.HandshakeContext(ctx)
 			if err != nil {
@@ -0,0 +1,193 @@
+package plow
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/oesand/plow/internal"
+	"github.com/oesand/plow/internal/catch"
+	"github.com/oesand/plow/internal/client_ops"
+	"github.com/oesand/plow/internal/encoding"
+	"github.com/oesand/plow/internal/h2"
+	"github.com/oesand/plow/internal/parsing"
+	"github.com/oesand/plow/specs"
+)
+
+// isHTTP2Allowed reports whether HTTP/2 may be used for the request.
+func (transport *Transport) isHTTP2Allowed(ctx context.Context, key client_ops.ConnKey, proxyUrl *specs.Url) bool {
+	if transport.DisableKeepAlive {
+		return false
+	}
+	switch key.Scheme {
+	case "https":
+		if transport.DisableHTTP2 {
+			return false
+		}
+	case "http":
+		// Plain http proxy forwards requests as is, without tunneling
+		if !transport.EnableH2C || (proxyUrl != nil && proxyUrl.Scheme == "http") {
+			return false
+		}
+	default:
+		return false
+	}
+	_, hasHijacker := ctx.Value(transportHijackerKey).(*TransportHijacker)
+	return !hasHijacker
+}
+
+// newH2Conn starts HTTP/2 client connection over a connection
+// on which "h2" protocol was negotiated or over a plain connection with prior knowledge.
+func (transport *Transport) newH2Conn(pc *client_ops.PersistConn) (*h2.ClientConn, error) {
+	var maxHeaderListSize uint32
+	if transport.HeadMaxLength > 0 {
+		maxHeaderListSize = uint32(min(transport.HeadMaxLength, math.MaxUint32))
+	}
+
+	cc, err := h2.NewClientConn(pc.Conn, pc.Reader, maxHeaderListSize, transport.IdleConnTimeout)
+	if err != nil {
+		pc.Conn.Close()
+		return nil, catch.TryWrapOpErr("write", catch.CatchCommonErr(err))
+	}
+	return cc, nil
+}
+
+func (transport *Transport) roundTripH2(
+	ctx context.Context, cc *h2.ClientConn,
+	method specs.HttpMethod, url *specs.Url, header *specs.Header, writer BodyWriter,
+	mustWriteBody bool,
+) (ClientResponse, error) {
+	path := url.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if len(url.Query) > 0 {
+		path += "?" + url.Query.String()
+	}
+
+	req := &h2.Request{
+		Method:    method,
+		Scheme:    url.Scheme,
+		Authority: header.Get("Host"),
+		Path:      path,
+		Header:    header,
+		Timeout:   transport.ReadTimeout,
+	}
+	if mustWriteBody {
+		req.Body = writer.WriteBody
+	}
+
+	resp, err := cc.RoundTrip(ctx, req)
+	if err != nil {
+		return nil, catch.CatchCommonErr(err)
+	}
+
+	result := client_ops.NewHttpClientResponse(resp.StatusCode, resp.Header)
+	result.SetProtoVersion(2, 0)
+	if resp.Body == nil {
+		return result, nil
+	}
+
+	if !method.IsReplyable() || !resp.StatusCode.IsReplyable() {
+		resp.Body.Close()
+		return result, nil
+	}
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding != "" && !encoding.IsKnownEncoding(contentEncoding) {
+		resp.Body.Close()
+		return nil, specs.ErrUnknownContentEncoding
+	}
+
+	maxSize := transport.MaxBodySize
+	if _, contentLength, err := parsing.ParseContentLength(resp.Header); err == nil &&
+		maxSize > 0 && contentLength > maxSize {
+		resp.Body.Close()
+		return nil, specs.ErrTooLarge
+	}
+
+	decodingReader, err := encoding.NewDecodingReader(contentEncoding, resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	var reader io.Reader = decodingReader
+	if maxSize > 0 {
+		reader = io.LimitReader(reader, maxSize)
+	}
+
+	result.Reader = internal.ReadCloser(reader, internal.CloserFunc(func() error {
+		err := decodingReader.Close()
+		resp.Body.Close()
+		return err
+	}))
+	return result, nil
+}
+
+// isNegotiatedH2 reports whether "h2" protocol was negotiated by TLS handshake.
+func isNegotiatedH2(conn net.Conn) bool {
+	tlsConn, ok := conn.(interface {
+		ConnectionState() tls.ConnectionState
+	})
+	return ok && tlsConn.ConnectionState().NegotiatedProtocol == h2.NextProtoTLS
+}
+
+// h2ConnPool keeps HTTP/2 connections which are shared by concurrent requests.
+type h2ConnPool struct {
+	mu    sync.Mutex
+	conns map[client_ops.ConnKey][]*h2.ClientConn
+}
+
+// Get returns a connection which can take a new request
+// and forgets connections which cannot.
+func (pool *h2ConnPool) Get(key client_ops.ConnKey) *h2.ClientConn {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	conns := pool.conns[key]
+	for len(conns) > 0 {
+		cc := conns[len(conns)-1]
+		if cc.CanTakeNewRequest() {
+			pool.conns[key] = conns
+			return cc
+		}
+		conns = conns[:len(conns)-1]
+		cc.CloseIfIdle()
+	}
+	delete(pool.conns, key)
+	return nil
+}
+
+func (pool *h2ConnPool) Add(key client_ops.ConnKey, cc *h2.ClientConn) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.conns == nil {
+		pool.conns = make(map[client_ops.ConnKey][]*h2.ClientConn)
+	}
+	pool.conns[key] = append(pool.conns[key], cc)
+}
+
+// CloseIdle closes connections without active streams.
+func (pool *h2ConnPool) CloseIdle() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for key, conns := range pool.conns {
+		active := conns[:0]
+		for _, cc := range conns {
+			if !cc.CloseIfIdle() {
+				active = append(active, cc)
+			}
+		}
+		if len(active) == 0 {
+			delete(pool.conns, key)
+		} else {
+			pool.conns[key] = active
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package plow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oesand/plow/specs"
+)
+
+func newHTTP2TestServer(handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var newConns atomic.Int32
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	server.StartTLS()
+	return server, &newConns
+}
+
+func newHTTP2TestTransport() *Transport {
+	transport := DefaultTransport()
+	transport.TLSConfig = &tls.Config{
+		InsecureSkipVerify: true,
+	}
+	return transport
+}
+
+func TestTransport_HTTP2GetRequest(t *testing.T) {
+	server, _ := newHTTP2TestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 request, got %s", r.Proto)
+		}
+		if r.URL.RequestURI() != "/path/to?key=value" {
+			t.Errorf("unexpected request uri %s", r.URL.RequestURI())
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			t.Errorf("not found expected cookie, %+v", r.Header)
+		}
+
+		w.Header().Set("x-hello-world", "xyz-123")
+		w.Header().Set("Content-Encoding", "gzip")
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: "xyz"})
+
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte("OK"))
+		gw.Close()
+	})
+	defer server.Close()
+
+	transport := newHTTP2TestTransport()
+	defer transport.CloseIdleConnections()
+
+	header := specs.NewHeader()
+	header.SetCookieValue("session", "abc")
+
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+		specs.MustParseUrl(server.URL+"/path/to?key=value"), header, nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	if resp.StatusCode() != specs.StatusCodeOK ||
+		resp.Header().Get("X-Hello-World") != "xyz-123" {
+		t.Errorf("not found expected headers, %+v", resp.Header())
+	}
+	if cookie := resp.Header().GetCookie("token"); cookie == nil || cookie.Value != "xyz" {
+		t.Errorf("not found expected cookie, %+v", resp.Header())
+	}
+
+	checkResponseBody(t, resp, []byte("OK"))
+}
+
+func TestTransport_HTTP2LargeBodies(t *testing.T) {
+	// Larger than initial windows, so flow control must be respected by both sides
+	requestBody := make([]byte, 3<<20)
+	rand.Read(requestBody)
+
+	server, _ := newHTTP2TestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 request, got %s", r.Proto)
+		}
+		io.Copy(w, r.Body)
+	})
+	defer server.Close()
+
+	transport := newHTTP2TestTransport()
+	defer transport.CloseIdleConnections()
+
+	req := BufferRequest(specs.HttpMethodPost, specs.MustParseUrl(server.URL), specs.ContentTypeRaw, requestBody)
+	resp, err := transport.RoundTrip(
+		context.Background(), req.Method(), req.Url(), req.Header(), req.(BodyWriter))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	checkResponseBody(t, resp, requestBody)
+}
+
+func TestTransport_HTTP2Multiplexing(t *testing.T) {
+	server, newConns := newHTTP2TestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Query().Get("n")))
+	})
+	defer server.Close()
+
+	transport := newHTTP2TestTransport()
+	defer transport.CloseIdleConnections()
+
+	// Establish connection before concurrent requests
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+		specs.MustParseUrl(server.URL+"?n=0"), specs.NewHeader(), nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	checkResponseBody(t, resp, []byte("0"))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(n string) {
+			defer wg.Done()
+
+			resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+				specs.MustParseUrl(server.URL+"?n="+n), specs.NewHeader(), nil)
+			if err != nil {
+				t.Error("req:", err)
+				return
+			}
+			body, err := io.ReadAll(resp.Body())
+			resp.Body().Close()
+			if err != nil || string(body) != n {
+				t.Errorf("expected body %s, got %s: %v", n, body, err)
+			}
+		}(string(rune('0' + i%10)))
+	}
+	wg.Wait()
+
+	if count := newConns.Load(); count != 1 {
+		t.Errorf("expected 1 connection, got %d", count)
+	}
+}
+
+func TestTransport_HTTP2BodyClose(t *testing.T) {
+	server, newConns := newHTTP2TestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write(bytes.Repeat([]byte("x"), 4<<20))
+			return
+		}
+		w.Write([]byte("OK"))
+	})
+	defer server.Close()
+
+	transport := newHTTP2TestTransport()
+	defer transport.CloseIdleConnections()
+
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+		specs.MustParseUrl(server.URL+"/large"), specs.NewHeader(), nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	buf := make([]byte, 1024)
+	if _, err = io.ReadFull(resp.Body(), buf); err != nil {
+		t.Fatal("read:", err)
+	}
+	resp.Body().Close()
+
+	// Stream is reset, connection is still usable
+	resp, err = transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+		specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	checkResponseBody(t, resp, []byte("OK"))
+
+	if count := newConns.Load(); count != 1 {
+		t.Errorf("expected 1 connection, got %d", count)
+	}
+}
+
+func TestTransport_HTTP2Disabled(t *testing.T) {
+	server, _ := newHTTP2TestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 1 {
+			t.Errorf("expected HTTP/1.1 request, got %s", r.Proto)
+		}
+		w.Write([]byte("OK"))
+	})
+	defer server.Close()
+
+	transport := newHTTP2TestTransport()
+	transport.DisableHTTP2 = true
+	defer transport.CloseIdleConnections()
+
+	resp, err := transport.RoundTrip(context.Background(), specs.HttpMethodGet,
+		specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	checkResponseBody(t, resp, []byte("OK"))
+}
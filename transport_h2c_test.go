@@ -0,0 +1,64 @@
+package plow
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oesand/plow/specs"
+)
+
+func newH2CTestServer(handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	return server
+}
+
+func TestTransport_H2CPriorKnowledge(t *testing.T) {
+	server := newH2CTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 request, got %s", r.Proto)
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	defer server.Close()
+
+	transport := DefaultTransport()
+	transport.EnableH2C = true
+	defer transport.CloseIdleConnections()
+
+	req := TextRequest(specs.HttpMethodPost, specs.MustParseUrl(server.URL), specs.ContentTypePlain, "ping")
+	resp, err := transport.RoundTrip(
+		context.Background(), req.Method(), req.Url(), req.Header(), req.(BodyWriter))
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+
+	checkResponseBody(t, resp, []byte("ping"))
+}
+
+func TestTransport_H2CDisabledByDefault(t *testing.T) {
+	server := newH2CTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 1 {
+			t.Errorf("expected HTTP/1 request, got %s", r.Proto)
+		}
+		w.Write([]byte("okay"))
+	})
+	defer server.Close()
+
+	transport := DefaultTransport()
+	defer transport.CloseIdleConnections()
+
+	resp, err := transport.RoundTrip(
+		context.Background(), specs.HttpMethodGet, specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+	if err != nil {
+		t.Fatal("req:", err)
+	}
+	checkResponseBody(t, resp, []byte("okay"))
+}
@@ -1160,3 +1160,222 @@ func TestTransport_Hijack(t *testing.T) {
 		t.Errorf("unexpected pong response '%s'", buf)
 	}
 }
+
+// Test keep-alive
+
+func newConnCountingServer(handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var newConns atomic.Int32
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	server.Start()
+	return server, &newConns
+}
+
+func TestTransport_KeepAliveReuse(t *testing.T) {
+	server, newConns := newConnCountingServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	defer server.Close()
+
+	transport := DefaultTransport()
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 5; i++ {
+		resp, err := transport.RoundTrip(
+			context.Background(), specs.HttpMethodGet, specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+		if err != nil {
+			t.Fatal("req:", err)
+		}
+		checkResponseBody(t, resp, []byte("OK"))
+	}
+
+	if count := newConns.Load(); count != 1 {
+		t.Errorf("expected 1 connection, got %d", count)
+	}
+}
+
+func TestTransport_KeepAliveReuseTLS(t *testing.T) {
+	var newConns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte("OK"))
+		gw.Close()
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	transport := DefaultTransport()
+	transport.TLSConfig = &tls.Config{
+		InsecureSkipVerify: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		resp, err := transport.RoundTrip(
+			context.Background(), specs.HttpMethodGet, specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+		if err != nil {
+			t.Fatal("req:", err)
+		}
+		checkResponseBody(t, resp, []byte("OK"))
+	}
+
+	if count := newConns.Load(); count != 1 {
+		t.Errorf("expected 1 connection, got %d", count)
+	}
+}
+
+func TestTransport_KeepAliveNotReusedWithoutDrain(t *testing.T) {
+	largeBody := bytes.Repeat([]byte("x"), int(transportMaxDrainSize)*2)
+	server, newConns := newConnCountingServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(largeBody)
+	})
+	defer server.Close()
+
+	transport := DefaultTransport()
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		resp, err := transport.RoundTrip(
+			context.Background(), specs.HttpMethodGet, specs.MustParseUrl(server.URL), specs.NewHeader(), nil)
+		if err != nil {
+			t.Fatal("req:", err)
+		}
+
+		// Close without reading body
+		resp.Body().Close()
+	}
+
+	if count := newConns.Load(); count != 2 {
+		t.Errorf("expected 2 connections, got %d", count)
+	}
+}
+
+func TestTransport_KeepAliveConnectionClose(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(transport *Transport, header *specs.Header)
+		respClose bool
+	}{
+		{
+			name: "Request header",
+			configure: func(transport *Transport, header *specs.Header) {
+				header.Set("Connection", "close")
+			},
+		},
+		{
+			name: "Response header",
+			configure: func(transport *Transport, header *specs.Header) {
+			},
+			respClose: true,
+		},
+		{
+			name: "Disabled keep-alive",
+			configure: func(transport *Transport, header *specs.Header) {
+				transport.DisableKeepAlive = true
+			},
+		},
+		{
+			name: "Disabled pooling",
+			configure: func(transport *Transport, header *specs.Header) {
+				transport.MaxIdleConnsPerHost = -1
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, newConns := newConnCountingServer(func(w http.ResponseWriter, r *http.Request) {
+				if tt.respClose {
+					w.Header().Set("Connection", "close")
+				}
+				w.Write([]byte("OK"))
+			})
+			defer server.Close()
+
+			transport := DefaultTransport()
+			defer transport.CloseIdleConnections()
+
+			for i := 0; i < 2; i++ {
+				header := specs.NewHeader()
+				tt.configure(transport, header)
+
+				resp, err := transport.RoundTrip(
+					context.Background(), specs.HttpMethodGet, specs.MustParseUrl(server.URL), header, nil)
+				if err != nil {
+					t.Fatal("req:", err)
+				}
+				checkResponseBody(t, resp, []byte("OK"))
+			}
+
+			if count := newConns.Load(); count != 2 {
+				t.Errorf("expected 2 connections, got %d", count)
+			}
+		})
+	}
+}
+
+func TestTransport_KeepAliveRetryClosedIdleConn(t *testing.T) {
+	var newConns atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			newConns.Add(1)
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				// Answer single request and close connection
+				// without 'Connection: close' header
+				_, err := server_ops.ReadRequest(ctx, conn.RemoteAddr(), bufio.NewReader(conn), 1024, 8*1024)
+				if err != nil {
+					return
+				}
+				header := specs.NewHeader()
+				header.Set("Content-Length", "2")
+				server_ops.WriteResponseHead(conn, true, specs.StatusCodeOK, header)
+				conn.Write([]byte("OK"))
+			}(conn)
+		}
+	}()
+
+	url := specs.MustParseUrl("http://" + listener.Addr().String())
+	transport := DefaultTransport()
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		resp, err := transport.RoundTrip(ctx, specs.HttpMethodGet, url, specs.NewHeader(), nil)
+		if err != nil {
+			t.Fatal("req:", err)
+		}
+		checkResponseBody(t, resp, []byte("OK"))
+
+		// Wait for server closes connection
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if count := newConns.Load(); count != 2 {
+		t.Errorf("expected 2 connections, got %d", count)
+	}
+}